@@ -0,0 +1,607 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var alertNotifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "exporter_alert_notifications_total",
+	Help: "Number of alert notifications sent, by notifier and result (success or failure)",
+}, []string{"notifier", "result"})
+
+func init() {
+	addExporterMetrics(alertNotifications)
+}
+
+const (
+	severityWarning  = "warning"
+	severityCritical = "critical"
+
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// alert is a notification about a rule that started or stopped matching
+// for one node (or address).
+type alert struct {
+	Rule     string            `json:"rule"`
+	Severity string            `json:"severity"`
+	Status   string            `json:"status"`
+	Labels   map[string]string `json:"labels"`
+	Summary  string            `json:"summary"`
+	StartsAt time.Time         `json:"starts_at"`
+	EndsAt   *time.Time        `json:"ends_at,omitempty"`
+}
+
+// key identifies the alert across evaluations.
+func (a alert) key() string {
+	var b strings.Builder
+	b.WriteString(a.Rule)
+	for _, k := range sortedKeys(a.Labels) {
+		fmt.Fprintf(&b, ",%s=%s", k, a.Labels[k])
+	}
+	return b.String()
+}
+
+// text renders the alert for chat notifiers.
+func (a alert) text() string {
+	return fmt.Sprintf("[%s] %s (%s): %s", strings.ToUpper(a.Status), a.Rule, a.Severity, a.Summary)
+}
+
+type alertCondition struct {
+	labels  map[string]string
+	summary string
+}
+
+// alertRule matches the gathered metrics and the health of the targets. A
+// condition has to match for the duration of forDuration before it fires.
+type alertRule struct {
+	name        string
+	severity    string
+	forDuration time.Duration
+	eval        func(families []*dto.MetricFamily, health healthStatus) []alertCondition
+}
+
+// notifier delivers alerts to an external system.
+type notifier interface {
+	notify(ctx context.Context, a alert) error
+}
+
+// groupNotifier is a notifier that can deliver several alerts of a node in
+// a single notification, used with --alert.group-by-node.
+type groupNotifier interface {
+	notifyGroup(ctx context.Context, node string, alerts []alert) error
+}
+
+type namedNotifier struct {
+	name string
+	// minSeverity restricts the notifier to alerts of at least this
+	// severity, all alerts if empty.
+	minSeverity string
+	// skipResolved keeps the resolutions of alerts from the notifier.
+	skipResolved bool
+	notifier
+}
+
+var severityRanks = map[string]int{
+	severityWarning:  1,
+	severityCritical: 2,
+}
+
+type activeAlert struct {
+	alert
+	Fired bool `json:"fired"`
+}
+
+// alertEngineState is what the alert engine persists with
+// --alert.state-file: the active alerts, so a restart neither fires them
+// again nor forgets to resolve them, and when alerts last resolved, for
+// the cooldowns.
+type alertEngineState struct {
+	Active   map[string]*activeAlert `json:"active"`
+	Resolved map[string]time.Time    `json:"resolved,omitempty"`
+}
+
+// alertEngine evaluates the rules at a fixed interval and notifies when an
+// alert fires and when it resolves. An alert that resolved doesn't fire
+// again within the cooldown of its rule; with groupByNode the alerts of a
+// node firing or resolving in the same evaluation are sent as one
+// notification by the notifiers supporting it.
+type alertEngine struct {
+	gatherer    prometheus.Gatherer
+	health      *healthTracker
+	rules       []alertRule
+	notifiers   []namedNotifier
+	cooldowns   map[string]time.Duration
+	groupByNode bool
+	statePath   string
+
+	active   map[string]*activeAlert
+	resolved map[string]time.Time
+}
+
+func newAlertEngine(g prometheus.Gatherer, health *healthTracker, rules []alertRule, notifiers []namedNotifier, cooldowns map[string]time.Duration, groupByNode bool) (*alertEngine, error) {
+	for name := range cooldowns {
+		known := false
+		for _, rule := range rules {
+			known = known || rule.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("cooldown of %q, which is not a configured rule", name)
+		}
+	}
+	return &alertEngine{
+		gatherer:    g,
+		health:      health,
+		rules:       rules,
+		notifiers:   notifiers,
+		cooldowns:   cooldowns,
+		groupByNode: groupByNode,
+		active:      make(map[string]*activeAlert),
+		resolved:    make(map[string]time.Time),
+	}, nil
+}
+
+// load restores the state of the engine from the file at path, if it
+// exists, and saves it there after every evaluation from then on. Alerts
+// of rules that are no longer configured are dropped.
+func (e *alertEngine) load(path string) error {
+	e.statePath = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state alertEngineState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	rules := make(map[string]bool, len(e.rules))
+	for _, rule := range e.rules {
+		rules[rule.name] = true
+	}
+	for key, active := range state.Active {
+		if rules[active.Rule] {
+			e.active[key] = active
+		}
+	}
+	for key, at := range state.Resolved {
+		e.resolved[key] = at
+	}
+	return nil
+}
+
+func (e *alertEngine) save() error {
+	if e.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(alertEngineState{Active: e.active, Resolved: e.resolved}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(e.statePath, data)
+}
+
+// coolingDown reports whether the alert with key of rule resolved less
+// than the cooldown of rule ago.
+func (e *alertEngine) coolingDown(rule, key string, now time.Time) bool {
+	at, ok := e.resolved[key]
+	return ok && now.Sub(at) < e.cooldowns[rule]
+}
+
+func (e *alertEngine) run(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			evalCtx, cancel := context.WithTimeout(ctx, interval)
+			e.evaluate(evalCtx, time.Now())
+			cancel()
+		}
+	}()
+}
+
+func (e *alertEngine) evaluate(ctx context.Context, now time.Time) {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		log.Printf("Error gathering metrics for alerts: %v\n", err)
+	}
+	_, _, health := e.health.status()
+
+	var notifications []alert
+	for _, rule := range e.rules {
+		seen := make(map[string]bool)
+		for _, c := range rule.eval(families, health) {
+			a := alert{Rule: rule.name, Severity: rule.severity, Status: alertFiring, Labels: c.labels, Summary: c.summary}
+			key := a.key()
+			seen[key] = true
+
+			active, ok := e.active[key]
+			if !ok {
+				a.StartsAt = now
+				active = &activeAlert{alert: a}
+				e.active[key] = active
+			}
+			active.Summary = c.summary
+			// Alerts of nodes under maintenance fire once the maintenance
+			// ended, if they still match, and so do alerts within the
+			// cooldown once it is over.
+			if !active.Fired && now.Sub(active.StartsAt) >= rule.forDuration && !maintenance.silenced(c.labels["node"], now) && !e.coolingDown(rule.name, key, now) {
+				active.Fired = true
+				delete(e.resolved, key)
+				notifications = append(notifications, active.alert)
+			}
+		}
+
+		for key, active := range e.active {
+			if active.Rule != rule.name || seen[key] {
+				continue
+			}
+			delete(e.active, key)
+			if active.Fired {
+				resolved := active.alert
+				resolved.Status = alertResolved
+				resolved.EndsAt = &now
+				if e.cooldowns[rule.name] > 0 {
+					e.resolved[key] = now
+				}
+				notifications = append(notifications, resolved)
+			}
+		}
+	}
+	for key, at := range e.resolved {
+		rule, _, _ := strings.Cut(key, ",")
+		if now.Sub(at) >= e.cooldowns[rule] {
+			delete(e.resolved, key)
+		}
+	}
+
+	e.notify(ctx, notifications)
+	if err := e.save(); err != nil {
+		log.Printf("Error saving the alert state: %v\n", err)
+	}
+}
+
+// notify sends the notifications of an evaluation to the notifiers, with
+// groupByNode those of the same node together where the notifier supports
+// it.
+func (e *alertEngine) notify(ctx context.Context, alerts []alert) {
+	for _, a := range alerts {
+		log.Printf("Alert %s: %s\n", a.Status, a.text())
+	}
+	for _, n := range e.notifiers {
+		var selected []alert
+		for _, a := range alerts {
+			if severityRanks[a.Severity] < severityRanks[n.minSeverity] || (a.Status == alertResolved && n.skipResolved) {
+				continue
+			}
+			selected = append(selected, a)
+		}
+		gn, canGroup := n.notifier.(groupNotifier)
+		if !e.groupByNode || !canGroup {
+			for _, a := range selected {
+				e.send(n.name, n.notify(ctx, a))
+			}
+			continue
+		}
+		for _, group := range groupAlertsByNode(selected) {
+			if node := group[0].Labels["node"]; node != "" && len(group) > 1 {
+				e.send(n.name, gn.notifyGroup(ctx, node, group))
+				continue
+			}
+			for _, a := range group {
+				e.send(n.name, n.notify(ctx, a))
+			}
+		}
+	}
+}
+
+// send records the outcome of a notification by the notifier called name.
+func (e *alertEngine) send(name string, err error) {
+	if err != nil {
+		log.Printf("Error sending alert to %s: %v\n", name, err)
+		alertNotifications.WithLabelValues(name, "failure").Inc()
+		return
+	}
+	alertNotifications.WithLabelValues(name, "success").Inc()
+}
+
+// groupAlertsByNode splits alerts into groups of the same node, in the
+// order the nodes first appear. Alerts without a node, e.g. of a halted
+// network, form a group of their own.
+func groupAlertsByNode(alerts []alert) [][]alert {
+	var groups [][]alert
+	index := make(map[string]int)
+	for _, a := range alerts {
+		node := a.Labels["node"]
+		if i, ok := index[node]; ok && node != "" {
+			groups[i] = append(groups[i], a)
+			continue
+		}
+		index[node] = len(groups)
+		groups = append(groups, []alert{a})
+	}
+	return groups
+}
+
+// groupText renders the alerts of a node for chat notifiers.
+func groupText(node string, alerts []alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d alerts of node %s:", len(alerts), node)
+	for _, a := range alerts {
+		b.WriteString("\n- " + a.text())
+	}
+	return b.String()
+}
+
+// syncLagRule fires when a node is more than maxLag blocks behind the
+// network head.
+func syncLagRule(maxLag int) alertRule {
+	return alertRule{
+		name:     "sync_lag",
+		severity: severityWarning,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, mf := range families {
+				if !strings.HasSuffix(mf.GetName(), "_sync_lag_blocks") {
+					continue
+				}
+				for _, m := range mf.Metric {
+					lag := m.GetGauge().GetValue()
+					if lag <= float64(maxLag) {
+						continue
+					}
+					labels := metricLabels(m)
+					conds = append(conds, alertCondition{
+						labels:  labels,
+						summary: fmt.Sprintf("node %s is %.0f blocks behind the network head", labels["node"], lag),
+					})
+				}
+			}
+			return conds
+		},
+	}
+}
+
+// unreachableRule fires when every collector of a target has been failing
+// for the duration of forDuration.
+func unreachableRule(forDuration time.Duration) alertRule {
+	return alertRule{
+		name:        "unreachable",
+		severity:    severityCritical,
+		forDuration: forDuration,
+		eval: func(_ []*dto.MetricFamily, health healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, name := range sortedKeys(health.Targets) {
+				ts := health.Targets[name]
+				if len(ts.Collectors) == 0 {
+					continue
+				}
+				reachable := false
+				for _, cs := range ts.Collectors {
+					reachable = reachable || cs.ConsecutiveFailures == 0
+				}
+				if reachable {
+					continue
+				}
+				conds = append(conds, alertCondition{
+					labels:  map[string]string{"node": name},
+					summary: fmt.Sprintf("node %s is unreachable: %s", name, ts.LastError),
+				})
+			}
+			return conds
+		},
+	}
+}
+
+// balanceRule fires when a watched wallet holds less than minBalance utia.
+func balanceRule(minBalance float64) alertRule {
+	return alertRule{
+		name:     "low_balance",
+		severity: severityWarning,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, mf := range families {
+				if mf.GetName() != "celestia_wallet_balance_utia" {
+					continue
+				}
+				for _, m := range mf.Metric {
+					balance := m.GetGauge().GetValue()
+					if balance >= minBalance {
+						continue
+					}
+					labels := metricLabels(m)
+					conds = append(conds, alertCondition{
+						labels:  labels,
+						summary: fmt.Sprintf("balance of %s on node %s is %.0f utia, below %.0f utia", labels["address"], labels["node"], balance, minBalance),
+					})
+				}
+			}
+			return conds
+		},
+	}
+}
+
+// missedBlocksRule fires when a validator missed more than maxMissed of the
+// blocks in its missed blocks window.
+func missedBlocksRule(maxMissed int) alertRule {
+	return alertRule{
+		name:     "missed_blocks",
+		severity: severityCritical,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, mf := range families {
+				if mf.GetName() != "celestia_validator_window_missed_blocks" {
+					continue
+				}
+				for _, m := range mf.Metric {
+					missed := m.GetGauge().GetValue()
+					if missed <= float64(maxMissed) {
+						continue
+					}
+					labels := metricLabels(m)
+					conds = append(conds, alertCondition{
+						labels:  labels,
+						summary: fmt.Sprintf("validator %s missed %.0f of its recent blocks", labels["validator"], missed),
+					})
+				}
+			}
+			return conds
+		},
+	}
+}
+
+// jailedRule fires while a watched validator is jailed or tombstoned, which
+// follows a downtime or double signing slash.
+func jailedRule() alertRule {
+	return alertRule{
+		name:     "validator_jailed",
+		severity: severityCritical,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			// A tombstoned validator is jailed as well, so tombstoning
+			// takes precedence in the summary.
+			tombstoned := make(map[string]bool)
+			var conds []alertCondition
+			for _, name := range []string{"celestia_validator_tombstoned", "celestia_validator_jailed"} {
+				for _, mf := range families {
+					if mf.GetName() != name {
+						continue
+					}
+					for _, m := range mf.Metric {
+						if m.GetGauge().GetValue() != 1 {
+							continue
+						}
+						labels := metricLabels(m)
+						key := alert{Labels: labels}.key()
+						if tombstoned[key] {
+							continue
+						}
+						summary := fmt.Sprintf("validator %s is jailed", labels["validator"])
+						if name == "celestia_validator_tombstoned" {
+							tombstoned[key] = true
+							summary = fmt.Sprintf("validator %s is tombstoned", labels["validator"])
+						}
+						conds = append(conds, alertCondition{labels: labels, summary: summary})
+					}
+				}
+			}
+			return conds
+		},
+	}
+}
+
+// upgradeRule fires when a scheduled upgrade takes effect within the next
+// blocks blocks, so the nodes can be upgraded in time.
+func upgradeRule(blocks int) alertRule {
+	return alertRule{
+		name:     "upgrade_soon",
+		severity: severityWarning,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, mf := range families {
+				if mf.GetName() != "celestia_upgrade_blocks_remaining" {
+					continue
+				}
+				for _, m := range mf.Metric {
+					remaining := m.GetGauge().GetValue()
+					if remaining > float64(blocks) {
+						continue
+					}
+					labels := metricLabels(m)
+					conds = append(conds, alertCondition{
+						labels:  labels,
+						summary: fmt.Sprintf("upgrade %s takes effect in %.0f blocks on network %s", labels["upgrade"], remaining, labels["network"]),
+					})
+				}
+			}
+			return conds
+		},
+	}
+}
+
+// chainHaltedRule fires while a network is halted rather than just the
+// nodes being stuck, see haltDetector.
+func chainHaltedRule() alertRule {
+	return alertRule{
+		name:     "chain_halted",
+		severity: severityCritical,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, mf := range families {
+				if mf.GetName() != "celestia_chain_halted" {
+					continue
+				}
+				for _, m := range mf.Metric {
+					if m.GetGauge().GetValue() != 1 {
+						continue
+					}
+					labels := metricLabels(m)
+					conds = append(conds, alertCondition{
+						labels:  labels,
+						summary: fmt.Sprintf("network %s is halted, its head stopped advancing on all reachable nodes", labels["network"]),
+					})
+				}
+			}
+			return conds
+		},
+	}
+}
+
+// ibcExpiryRule fires when the light client of a watched IBC channel
+// expires within expiresWithin, or did expire.
+func ibcExpiryRule(expiresWithin time.Duration) alertRule {
+	return alertRule{
+		name:     "ibc_client_expiry",
+		severity: severityCritical,
+		eval: func(families []*dto.MetricFamily, _ healthStatus) []alertCondition {
+			var conds []alertCondition
+			for _, mf := range families {
+				if mf.GetName() != "celestia_ibc_client_expiry_timestamp_seconds" {
+					continue
+				}
+				for _, m := range mf.Metric {
+					left := time.Until(time.Unix(int64(m.GetGauge().GetValue()), 0))
+					if left > expiresWithin {
+						continue
+					}
+					labels := metricLabels(m)
+					summary := fmt.Sprintf("IBC client %s of channel %s to %s expires in %s", labels["client"], labels["channel"], labels["counterparty_chain"], left.Round(time.Minute))
+					if left <= 0 {
+						summary = fmt.Sprintf("IBC client %s of channel %s to %s expired", labels["client"], labels["channel"], labels["counterparty_chain"])
+					}
+					conds = append(conds, alertCondition{labels: labels, summary: summary})
+				}
+			}
+			return conds
+		},
+	}
+}
+
+func metricLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.Label))
+	for _, l := range m.Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
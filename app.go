@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	stakingBondedTokens = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_staking_bonded_tokens_utia",
+		Help: "Stake bonded to the validators of the network in utia",
+	}, targetLabels)
+	stakingNotBondedTokens = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_staking_not_bonded_tokens_utia",
+		Help: "Stake of unbonded and unbonding validators and delegations in utia",
+	}, targetLabels)
+	bankSupply = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_bank_supply_utia",
+		Help: "Total supply of utia",
+	}, targetLabels)
+	blobGasPerByte = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_blob_gas_per_blob_byte",
+		Help: "Gas charged per byte of blob data by the blob module",
+	}, targetLabels)
+	blobGovMaxSquareSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_blob_gov_max_square_size",
+		Help: "Maximum width of the data square set by governance",
+	}, targetLabels)
+	validatorTokens = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_tokens_utia",
+		Help: "Stake delegated to the validator in utia, including its self-delegation",
+	}, validatorLabels)
+	validatorStakeShare = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_stake_share",
+		Help: "Stake of the validator as a fraction of the bonded stake of the network",
+	}, validatorLabels)
+	validatorDelegators = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_delegators",
+		Help: "Number of delegations to the validator",
+	}, validatorLabels)
+	validatorUnbondingTokens = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_unbonding_tokens_utia",
+		Help: "Stake being unbonded from the validator in utia",
+	}, validatorLabels)
+	validatorOutstandingRewards = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_outstanding_rewards_utia",
+		Help: "Rewards of the validator and its delegators not withdrawn yet in utia",
+	}, validatorLabels)
+	validatorCommission = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_commission_utia",
+		Help: "Commission of the validator not withdrawn yet in utia",
+	}, validatorLabels)
+)
+
+func init() {
+	addTargetMetrics(stakingBondedTokens, stakingNotBondedTokens, bankSupply, blobGasPerByte, blobGovMaxSquareSize,
+		validatorTokens, validatorStakeShare, validatorDelegators, validatorUnbondingTokens, validatorOutstandingRewards, validatorCommission)
+	registerCollector("app", newAppCollector)
+}
+
+var (
+	appGRPCMu      sync.Mutex
+	appGRPCClients = make(map[string]*cometrpc.GRPCClient)
+)
+
+// consensusClient returns the client of the consensus endpoint of t, which
+// sends the Cosmos SDK queries to the app gRPC server if one is configured.
+// The gRPC connections are shared between targets and kept across reloads.
+func consensusClient(t target) *cometrpc.Client {
+	if t.AppGRPC == "" {
+		return cometrpc.New(t.ConsensusEndpoint)
+	}
+	appGRPCMu.Lock()
+	defer appGRPCMu.Unlock()
+	g, ok := appGRPCClients[t.AppGRPC]
+	if !ok {
+		var err error
+		// The address was checked when the target was configured.
+		if g, err = cometrpc.DialGRPC(t.AppGRPC); err != nil {
+			log.Printf("Error connecting to app gRPC %s of %s: %v\n", t.AppGRPC, t.Name, err)
+			return cometrpc.New(t.ConsensusEndpoint)
+		}
+		appGRPCClients[t.AppGRPC] = g
+	}
+	return cometrpc.New(t.ConsensusEndpoint, cometrpc.WithAppGRPC(g))
+}
+
+// appCollector exports the network-wide state of celestia-app: the stake,
+// the supply and the blob parameters, and the stake, delegations and
+// rewards of the validator paired with the target and of the further
+// validators to watch. It queries the app gRPC server or, without one, the
+// consensus node.
+type appCollector struct {
+	client *cometrpc.Client
+	target target
+}
+
+func newAppCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.AppGRPC == "" && t.ConsensusEndpoint == "" {
+		return nil
+	}
+	return &appCollector{client: consensusClient(t), target: t}
+}
+
+func (c *appCollector) Name() string { return "app" }
+
+func (c *appCollector) Collect(ctx context.Context) error {
+	t := c.target
+	var errs []error
+
+	pool, err := c.client.StakingPool(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		stakingBondedTokens.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(pool.BondedTokens)
+		stakingNotBondedTokens.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(pool.NotBondedTokens)
+	}
+
+	if supply, err := c.client.SupplyOf(ctx, "utia"); err != nil {
+		errs = append(errs, err)
+	} else {
+		bankSupply.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(supply)
+	}
+
+	if params, err := c.client.BlobParams(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		blobGasPerByte.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(params.GasPerBlobByte))
+		blobGovMaxSquareSize.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(params.GovMaxSquareSize))
+	}
+
+	for _, addr := range t.stakingValidators() {
+		if err := c.collectValidator(ctx, addr, pool); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// collectValidator exports the stake, delegations and rewards of the
+// validator with the operator address addr; pool is nil if it couldn't be
+// queried.
+func (c *appCollector) collectValidator(ctx context.Context, addr string, pool *cometrpc.StakingPool) error {
+	t := c.target
+	v, err := c.client.StakingValidator(ctx, addr)
+	if err != nil {
+		return err
+	}
+	tokens, err := strconv.ParseFloat(v.Tokens, 64)
+	if err != nil {
+		return fmt.Errorf("parsing tokens of validator %s: %w", addr, err)
+	}
+	validatorTokens.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(tokens)
+	// Only bonded validators count towards the bonded stake.
+	if pool != nil && pool.BondedTokens > 0 && v.Status == 3 {
+		validatorStakeShare.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(tokens / pool.BondedTokens)
+	} else {
+		validatorStakeShare.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(0)
+	}
+
+	var errs []error
+	if n, err := c.client.DelegatorCount(ctx, addr); err != nil {
+		errs = append(errs, err)
+	} else {
+		validatorDelegators.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(float64(n))
+	}
+	if unbonding, err := c.client.UnbondingTokens(ctx, addr); err != nil {
+		errs = append(errs, err)
+	} else {
+		validatorUnbondingTokens.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(unbonding)
+	}
+	if rewards, err := c.client.ValidatorRewards(ctx, addr); err != nil {
+		errs = append(errs, err)
+	} else {
+		validatorOutstandingRewards.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(rewards.Outstanding)
+		validatorCommission.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(rewards.Commission)
+	}
+	return joinErrors(errs)
+}
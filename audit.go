@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// auditRatioWindow is the number of most recent checks of a kind the
+// success ratio is computed over.
+const auditRatioWindow = 100
+
+// Kinds of audit checks.
+const (
+	auditKindEDS  = "eds"
+	auditKindBlob = "blob"
+)
+
+var (
+	auditChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_audit_checks_total",
+		Help: "Number of retrievability checks of historical heights, by kind (eds or blob) and result (success or failure)",
+	}, append(targetLabels, "kind", "result"))
+	auditSuccessRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_audit_success_ratio",
+		Help: "Share of the last 100 retrievability checks of a kind that succeeded",
+	}, append(targetLabels, "kind"))
+	auditDuration = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "celestia_audit_duration_seconds",
+		Help:       "Duration of the successful retrievability checks of historical heights, by kind",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	}, append(targetLabels, "kind"))
+)
+
+func init() {
+	addTargetMetrics(auditChecks, auditSuccessRatio, auditDuration)
+	registerCollector("audit", newAuditCollector)
+}
+
+// auditCollector checks that bridge and full nodes actually serve the data
+// of historical heights: every run picks random heights up to the local
+// head, fetches their extended data square with share.GetEDS and, with
+// audit namespaces, their blobs under these namespaces with blob.GetAll.
+type auditCollector struct {
+	client     *celestiarpc.Client
+	target     target
+	namespaces [][]byte
+	rand       *rand.Rand
+
+	results map[string][]bool
+}
+
+func newAuditCollector(client *celestiarpc.Client, t target) Collector {
+	if !t.collectsHeaders() || t.AuditSamples == 0 {
+		return nil
+	}
+	c := &auditCollector{
+		client:  client,
+		target:  t,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		results: make(map[string][]bool),
+	}
+	for _, s := range t.AuditNamespaces {
+		// The namespaces were validated when the targets were loaded.
+		ns, _ := parseNamespace(s)
+		c.namespaces = append(c.namespaces, ns)
+	}
+	return c
+}
+
+func (c *auditCollector) Name() string { return "audit" }
+
+func (c *auditCollector) Collect(ctx context.Context) error {
+	head, err := c.client.LocalHead(ctx)
+	if err != nil {
+		return err
+	}
+	// Audit the heights of the window below the head, or all of them.
+	from := uint64(1)
+	if w := uint64(c.target.AuditWindow); w > 0 && head.Height() > w {
+		from = head.Height() - w + 1
+	}
+	for i := 0; i < c.target.AuditSamples; i++ {
+		height := from + uint64(c.rand.Int63n(int64(head.Height()-from+1)))
+		if err := c.check(ctx, auditKindEDS, height, c.checkEDS); err != nil {
+			return err
+		}
+		if len(c.namespaces) > 0 {
+			if err := c.check(ctx, auditKindBlob, height, c.checkBlobs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check runs one check of kind at height. fetch reports whether the node
+// served the data; errors the node answers with count as failed checks,
+// only failures to reach the node are returned.
+func (c *auditCollector) check(ctx context.Context, kind string, height uint64, fetch func(context.Context, uint64) (bool, error)) error {
+	t := c.target
+	start := time.Now()
+	ok, err := fetch(ctx, height)
+	if err != nil {
+		return err
+	}
+	if ok {
+		auditDuration.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, kind).Observe(time.Since(start).Seconds())
+		auditChecks.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, kind, "success").Inc()
+	} else {
+		auditChecks.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, kind, "failure").Inc()
+	}
+
+	results := append(c.results[kind], ok)
+	if len(results) > auditRatioWindow {
+		results = results[len(results)-auditRatioWindow:]
+	}
+	c.results[kind] = results
+	succeeded := 0
+	for _, r := range results {
+		if r {
+			succeeded++
+		}
+	}
+	auditSuccessRatio.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, kind).Set(float64(succeeded) / float64(len(results)))
+	return nil
+}
+
+func (c *auditCollector) checkEDS(ctx context.Context, height uint64) (bool, error) {
+	eds, err := c.client.GetEDS(ctx, height)
+	if err != nil {
+		if nodeError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(eds.DataSquare) > 0, nil
+}
+
+// checkBlobs fetches the blobs under the audit namespaces. Heights without
+// such blobs are answered with "blob: not found", which proves the node
+// could look them up as well.
+func (c *auditCollector) checkBlobs(ctx context.Context, height uint64) (bool, error) {
+	_, err := c.client.GetAllBlobs(ctx, height, c.namespaces)
+	if err != nil {
+		if nodeError(err) {
+			return strings.Contains(err.Error(), "not found"), nil
+		}
+		return false, err
+	}
+	return true, nil
+}
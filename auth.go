@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// authTokenEnv is the environment variable consulted for targets without a
+// configured token or token file.
+const authTokenEnv = "CELESTIA_NODE_AUTH_TOKEN"
+
+var authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "exporter_auth_failures_total",
+	Help: "Number of RPC requests rejected by the node because of the auth token",
+}, targetLabels)
+
+func init() {
+	addTargetMetrics(authFailures)
+}
+
+// fileToken reads the auth token from a file and re-reads it whenever the
+// file changes, so rotated tokens are picked up without a restart.
+type fileToken struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	token   string
+}
+
+func (f *fileToken) Token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if f.token != "" && token != f.token {
+		log.Printf("Reloaded auth token from %s\n", f.path)
+	}
+	f.token = token
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return f.token, nil
+}
+
+// Refresh makes the next Token call re-read the file.
+func (f *fileToken) Refresh() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.modTime = time.Time{}
+}
+
+// envToken reads the auth token from an environment variable, so a token
+// injected into the environment of the exporter can differ per target.
+type envToken string
+
+func (e envToken) Token(ctx context.Context) (string, error) {
+	token := strings.TrimSpace(os.Getenv(string(e)))
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return token, nil
+}
+
+// commandToken runs a command that prints the auth token, like celestia
+// bridge auth admin. The token is kept until the node rejects it or, with a
+// TTL, until it expires. Failed attempts are retried at most once a minute.
+type commandToken struct {
+	args []string
+	ttl  time.Duration
+
+	mu          sync.Mutex
+	token       string
+	fetched     time.Time
+	lastFailure time.Time
+}
+
+// Token runs the command if there is no token. The command is killed when
+// ctx is done; a cancelled run doesn't count as a failed attempt.
+func (c *commandToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.ttl > 0 && time.Since(c.fetched) >= c.ttl {
+		c.token = ""
+	}
+	if c.token == "" && time.Since(c.lastFailure) >= time.Minute {
+		cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+		out, err := cmd.CombinedOutput()
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return "", fmt.Errorf("running %s: %w", c.args[0], ctxErr)
+		}
+		if err != nil {
+			c.lastFailure = time.Now()
+			log.Printf("Error getting auth token: %v, output: %s\n", err, strings.TrimSpace(string(out)))
+		} else {
+			c.token = strings.TrimSpace(string(out))
+			c.fetched = time.Now()
+		}
+	}
+	return c.token, nil
+}
+
+// Refresh drops the rejected token so the next Token call runs the command
+// again.
+func (c *commandToken) Refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" {
+		log.Printf("Auth token of %q was rejected, regenerating\n", strings.Join(c.args, " "))
+	}
+	c.token = ""
+}
+
+// jwtSecretKey is the file of the JWT secret in the keystore of a node
+// store, the key name jwt-secret.jwt in unpadded base32.
+const jwtSecretKey = "keys/NJ3XILLTMVRXEZLUFZVHO5A"
+
+// scopePermissions are the permissions of the tokens of celestia auth admin
+// and celestia auth read, by auth scope.
+var scopePermissions = map[string][]string{
+	authScopeAdmin: {"public", "read", "write", "admin"},
+	authScopeRead:  {"public", "read"},
+}
+
+// storeToken mints tokens in-process with the JWT secret of the node store,
+// like celestia auth admin and read do, so the exporter only needs the keystore
+// mounted, not the celestia binary. It re-reads the secret whenever the
+// token expires or the node rejects it.
+type storeToken struct {
+	secretFile  string
+	permissions []string
+	ttl         time.Duration
+
+	mu      sync.Mutex
+	token   string
+	fetched time.Time
+}
+
+func (s *storeToken) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.ttl == 0 || time.Since(s.fetched) < s.ttl) {
+		return s.token, nil
+	}
+	secret, err := readJWTSecret(s.secretFile)
+	if err != nil {
+		return "", err
+	}
+	s.token, err = mintJWT(secret, s.permissions)
+	if err != nil {
+		return "", err
+	}
+	s.fetched = time.Now()
+	return s.token, nil
+}
+
+// Refresh makes the next Token call mint a token with the secret read
+// again.
+func (s *storeToken) Refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" {
+		log.Printf("Auth token minted with %s was rejected, minting a new one\n", s.secretFile)
+	}
+	s.token = ""
+}
+
+// readJWTSecret reads a key of the node keystore, stored as JSON with the
+// base64 encoded key in body.
+func readJWTSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWT secret: %w", err)
+	}
+	var key struct {
+		Body []byte `json:"body"`
+	}
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("parsing JWT secret %s: %w", path, err)
+	}
+	if len(key.Body) == 0 {
+		return nil, fmt.Errorf("JWT secret %s is empty", path)
+	}
+	return key.Body, nil
+}
+
+// mintJWT returns an HS256 token granting permissions, in the format the
+// node verifies.
+func mintJWT(secret []byte, permissions []string) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims, err := json.Marshal(struct {
+		Allow []string `json:"Allow"`
+	}{permissions})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// tokenCommandData is what a token command template can refer to, e.g.
+// {{.NodeType}}.
+type tokenCommandData struct {
+	Name, Endpoint, NodeType, Network, NodeStore string
+}
+
+// tokenCommandArgs expands the --auth.token-command template of t. It is
+// split into arguments at spaces before the expansion, without a shell, so
+// values with spaces stay one argument. Without a command template, the
+// token is generated with the celestia binary from the node store.
+func tokenCommandArgs(t target) ([]string, error) {
+	nodeType := t.NodeType
+	if nodeType == nodeTypeAuto {
+		nodeType = nodeTypeBridge
+	}
+	if t.AuthTokenCommand == "" {
+		scope := authScopeAdmin
+		if t.AuthScope == authScopeRead {
+			scope = authScopeRead
+		}
+		return []string{"celestia", nodeType, "auth", scope, "--p2p.network", t.P2PNetwork, "--node.store", t.NodeStore}, nil
+	}
+
+	data := tokenCommandData{Name: t.Name, Endpoint: t.Endpoint, NodeType: nodeType, Network: t.P2PNetwork, NodeStore: t.NodeStore}
+	var args []string
+	for _, field := range strings.Fields(t.AuthTokenCommand) {
+		tmpl, err := template.New("").Option("missingkey=error").Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth token command: %w", err)
+		}
+		var arg strings.Builder
+		if err := tmpl.Execute(&arg, data); err != nil {
+			return nil, fmt.Errorf("invalid auth token command: %w", err)
+		}
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty auth token command")
+	}
+	return args, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var walletBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "celestia_wallet_balance_utia",
+	Help: "Balance of a wallet in utia",
+}, append(targetLabels, "address"))
+
+func init() {
+	addTargetMetrics(walletBalance)
+	registerCollector("state", newStateCollector)
+}
+
+// stateCollector exports the balance of the node's default account and of
+// the target's additional watched addresses.
+type stateCollector struct {
+	client *celestiarpc.Client
+	target target
+}
+
+func newStateCollector(client *celestiarpc.Client, t target) Collector {
+	return &stateCollector{client: client, target: t}
+}
+
+func (c *stateCollector) Name() string { return "state" }
+
+func (c *stateCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	var errs []error
+	addr, err := client.AccountAddress(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	} else if balance, err := client.Balance(ctx); err != nil {
+		errs = append(errs, err)
+	} else if err := setBalance(t, addr, balance); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, addr := range t.BalanceAddresses {
+		balance, err := client.BalanceForAddress(ctx, addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w (address %s)", err, addr))
+			continue
+		}
+		if err := setBalance(t, addr, balance); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+func setBalance(t target, addr string, balance *celestiarpc.Balance) error {
+	if balance.Denom != "" && balance.Denom != "utia" {
+		return fmt.Errorf("unexpected denom %q in balance of %s", balance.Denom, addr)
+	}
+	amount, err := strconv.ParseFloat(balance.Amount, 64)
+	if err != nil {
+		return fmt.Errorf("parsing balance of %s: %w", addr, err)
+	}
+	walletBalance.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr).Set(amount)
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+// maxBlocksPerRun caps how many blocks one collection walks, so a
+// collector that fell behind catches up with the chain head instead of
+// walking old blocks.
+const maxBlocksPerRun = 100
+
+var (
+	validatorBlocksProposed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_validator_blocks_proposed_total",
+		Help: "Number of walked blocks proposed by the validator",
+	}, validatorLabels)
+	validatorBlocksSigned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_validator_blocks_signed_total",
+		Help: "Number of walked blocks whose commit contains the validator's signature",
+	}, validatorLabels)
+	validatorBlocksMissed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_validator_blocks_missed_total",
+		Help: "Number of walked blocks whose commit lacks the validator's signature",
+	}, validatorLabels)
+	validatorConsecutiveMissed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_consecutive_missed_blocks",
+		Help: "Number of blocks the validator missed in a row up to the latest walked block",
+	}, validatorLabels)
+	validatorWindowMissed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_window_missed_blocks",
+		Help: "Number of blocks the validator missed among the last missed_blocks_window walked blocks",
+	}, validatorLabels)
+)
+
+func init() {
+	addTargetMetrics(validatorBlocksProposed, validatorBlocksSigned, validatorBlocksMissed,
+		validatorConsecutiveMissed, validatorWindowMissed)
+	registerCollector("blocks", newBlocksCollector)
+}
+
+// blocksCollector walks the new blocks of the consensus node and counts the
+// blocks the target's validator proposed, signed and missed.
+type blocksCollector struct {
+	client *cometrpc.Client
+	target target
+
+	consAddr    string
+	lastHeight  int64
+	consecutive int
+	// window holds whether each of the last walked blocks was missed.
+	window    []bool
+	windowPos int
+	missed    int
+}
+
+func newBlocksCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.ConsensusEndpoint == "" || t.ValidatorAddress == "" {
+		return nil
+	}
+	return &blocksCollector{
+		client: consensusClient(t),
+		target: t,
+		window: make([]bool, 0, t.MissedBlocksWindow),
+	}
+}
+
+func (c *blocksCollector) Name() string { return "blocks" }
+
+func (c *blocksCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	labels := []string{t.Name, t.Endpoint, t.P2PNetwork, t.ValidatorAddress}
+
+	if c.consAddr == "" {
+		v, err := client.StakingValidator(ctx, t.ValidatorAddress)
+		if err != nil {
+			return err
+		}
+		hexAddr, _, err := cometrpc.ConsensusAddress(v.ConsensusPubKey, consensusPrefix(t.ValidatorAddress))
+		if err != nil {
+			return fmt.Errorf("deriving consensus address of %s: %w", t.ValidatorAddress, err)
+		}
+		c.consAddr = hexAddr
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	latest := status.SyncInfo.LatestBlockHeight
+	if c.lastHeight == 0 || latest-c.lastHeight > maxBlocksPerRun {
+		c.lastHeight = latest - 1
+	}
+
+	for h := c.lastHeight + 1; h <= latest; h++ {
+		b, err := client.Block(ctx, h)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(b.Header.ProposerAddress, c.consAddr) {
+			validatorBlocksProposed.WithLabelValues(labels...).Inc()
+		}
+		// The commit in block h holds the signatures for block h-1.
+		if b.LastCommit.Height > 0 {
+			signed := b.Signed(c.consAddr)
+			if signed {
+				validatorBlocksSigned.WithLabelValues(labels...).Inc()
+				c.consecutive = 0
+			} else {
+				validatorBlocksMissed.WithLabelValues(labels...).Inc()
+				c.consecutive++
+			}
+			c.record(!signed)
+		}
+		c.lastHeight = h
+	}
+
+	validatorConsecutiveMissed.WithLabelValues(labels...).Set(float64(c.consecutive))
+	validatorWindowMissed.WithLabelValues(labels...).Set(float64(c.missed))
+	return nil
+}
+
+// record adds a block to the window of the last MissedBlocksWindow blocks.
+func (c *blocksCollector) record(missed bool) {
+	size := cap(c.window)
+	if size == 0 {
+		return
+	}
+	if len(c.window) < size {
+		c.window = append(c.window, missed)
+	} else {
+		if c.window[c.windowPos] {
+			c.missed--
+		}
+		c.window[c.windowPos] = missed
+		c.windowPos = (c.windowPos + 1) % size
+	}
+	if missed {
+		c.missed++
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// blockTimeWeight is the weight of a new observation in the moving average
+// of the block time.
+const blockTimeWeight = 0.2
+
+var (
+	networkBlockTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_network_block_time_seconds",
+		Help: "Block time of the network as observed by the exporter, a moving average over the last blocks",
+	}, targetLabels)
+	collectorInterval = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exporter_collector_interval_seconds",
+		Help: "Interval the header and das collectors run at, half the block time with --scrape.adaptive, by collector",
+	}, append(targetLabels, "collector"))
+)
+
+func init() {
+	addTargetMetrics(networkBlockTime, collectorInterval)
+}
+
+// blockTimeCollectors are the collectors whose interval follows the block
+// time with --scrape.adaptive: they poll the heights, which only change
+// once a block.
+var blockTimeCollectors = map[string]bool{"header": true, "das": true}
+
+// blockTimeTracker keeps the moving average of the block time per target.
+type blockTimeTracker struct {
+	mu    sync.Mutex
+	nodes map[string]*blockTimeState
+}
+
+type blockTimeState struct {
+	height  uint64
+	at      time.Time
+	average time.Duration
+}
+
+var blockTimes = &blockTimeTracker{nodes: make(map[string]*blockTimeState)}
+
+// observe records that the network head of t was at height at the time at,
+// the header time or, without one, the time it was seen.
+func (b *blockTimeTracker) observe(t target, height uint64, at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := stateKey(t)
+	s, ok := b.nodes[key]
+	// A node that went back, e.g. after a failover, starts over.
+	if !ok || height < s.height {
+		b.nodes[key] = &blockTimeState{height: height, at: at}
+		return
+	}
+	if height == s.height || !at.After(s.at) {
+		return
+	}
+	observed := at.Sub(s.at) / time.Duration(height-s.height)
+	if s.average == 0 {
+		s.average = observed
+	} else {
+		s.average += time.Duration(blockTimeWeight * float64(observed-s.average))
+	}
+	s.height, s.at = height, at
+	networkBlockTime.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(s.average.Seconds())
+}
+
+// get returns the average block time of t, false before it was observed
+// over a block.
+func (b *blockTimeTracker) get(t target) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.nodes[stateKey(t)]
+	if !ok || s.average == 0 {
+		return 0, false
+	}
+	return s.average, true
+}
+
+// forget drops the block time of a removed target.
+func (b *blockTimeTracker) forget(t target) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.nodes, stateKey(t))
+}
+
+// pollInterval returns the interval the collector called name runs at for
+// t: with an adaptive interval half the block time within the bounds of t,
+// so a new height is seen within half a block, otherwise, or while the
+// block time is unknown, the collector's interval.
+func pollInterval(t target, name string) time.Duration {
+	if !t.AdaptiveInterval || !blockTimeCollectors[name] {
+		return t.collectorInterval(name)
+	}
+	blockTime, ok := blockTimes.get(t)
+	if !ok {
+		return t.collectorInterval(name)
+	}
+	interval := blockTime / 2
+	if interval < t.AdaptiveMin {
+		interval = t.AdaptiveMin
+	}
+	if interval > t.AdaptiveMax {
+		interval = t.AdaptiveMax
+	}
+	return interval
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Build information, set at build time via
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+var buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "exporter_build_info",
+	Help: "Build information of the exporter, always 1",
+}, []string{"version", "commit", "build_date", "goversion"})
+
+func init() {
+	// Without ldflags, fall back to what the go toolchain recorded.
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && commit == "":
+				commit = s.Value
+			case s.Key == "vcs.time" && buildDate == "":
+				buildDate = s.Value
+			}
+		}
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if buildDate == "" {
+		buildDate = "unknown"
+	}
+
+	addExporterMetrics(buildInfo)
+	buildInfo.WithLabelValues(version, commit, buildDate, runtime.Version()).Set(1)
+}
+
+func versionString() string {
+	return fmt.Sprintf("celbridge_export version %s (commit %s, built %s, %s)", version, commit, buildDate, runtime.Version())
+}
+
+var exporterMetrics []prometheus.Collector
+
+// addExporterMetrics remembers metrics of the exporter itself, like
+// exporter_build_info, so that they get registered by
+// registerExporterMetrics.
+func addExporterMetrics(metrics ...prometheus.Collector) {
+	exporterMetrics = append(exporterMetrics, metrics...)
+}
+
+// registerExporterMetrics registers the metrics of the exporter itself and,
+// with withRuntime, the Go runtime and process collectors.
+func registerExporterMetrics(reg prometheus.Registerer, withRuntime bool) {
+	if withRuntime {
+		reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	for _, m := range exporterMetrics {
+		reg.MustRegister(m)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	canaryRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_canary_runs_total",
+		Help: "Number of blob canary runs, by result (success or failure)",
+	}, append(targetLabels, "result"))
+	canaryRoundTrip = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "celestia_canary_round_trip_seconds",
+		Help:    "Time from submitting the canary blob until it was retrieved back from the node",
+		Buckets: []float64{2, 5, 10, 15, 20, 30, 45, 60, 90, 120},
+	}, targetLabels)
+	canaryGasUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_canary_gas_used",
+		Help: "Gas of the last canary PayForBlobs transaction, as estimated by the node for its blob size",
+	}, targetLabels)
+	canaryLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_canary_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful canary run",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(canaryRuns, canaryRoundTrip, canaryGasUsed, canaryLastSuccess)
+	registerCollector("canary", newCanaryCollector)
+	requirePermission("canary", "write")
+}
+
+// canaryCollector submits a small blob under the target's canary namespace
+// and reads it back, proving end to end that the node can post and serve
+// data. Every run pays fees, so it runs at --canary.interval.
+type canaryCollector struct {
+	client    *celestiarpc.Client
+	target    target
+	namespace []byte
+}
+
+func newCanaryCollector(client *celestiarpc.Client, t target) Collector {
+	if t.CanaryNamespace == "" {
+		return nil
+	}
+	// The namespace was validated when the targets were loaded.
+	ns, _ := parseNamespace(t.CanaryNamespace)
+	return &canaryCollector{client: client, target: t, namespace: ns}
+}
+
+func (c *canaryCollector) Name() string { return "canary" }
+
+func (c *canaryCollector) Collect(ctx context.Context) error {
+	t := c.target
+	start := time.Now()
+	err := c.roundTrip(ctx, start)
+	if err != nil {
+		canaryRuns.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "failure").Inc()
+		exporterState.canaryRun(t, false, time.Now())
+		return err
+	}
+	canaryRoundTrip.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Observe(time.Since(start).Seconds())
+	now := time.Now()
+	canaryRuns.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "success").Inc()
+	canaryLastSuccess.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(now.Unix()))
+	exporterState.canaryRun(t, true, now)
+	return nil
+}
+
+func (c *canaryCollector) roundTrip(ctx context.Context, start time.Time) error {
+	t, client := c.target, c.client
+	data := []byte(fmt.Sprintf("celestia exporter canary %s %d", t.Name, start.UnixNano()))
+
+	blob, err := celestiarpc.NewBlob(c.namespace, data)
+	if err != nil {
+		return err
+	}
+	height, err := client.SubmitBlob(ctx, []*celestiarpc.Blob{blob})
+	if err != nil {
+		return err
+	}
+	// blob.Submit only returns the height, the gas is the estimate its
+	// default options pay for.
+	canaryGasUsed.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(celestiarpc.EstimateGas(blob)))
+
+	b, err := client.GetBlob(ctx, height, c.namespace, blob.Commitment)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(b.Data, data) {
+		return fmt.Errorf("canary blob at height %d returned different data", height)
+	}
+	return nil
+}
+
+// parseNamespace parses a hex encoded version 0 namespace ID of up to 10
+// bytes into the full 29 byte namespace.
+func parseNamespace(s string) ([]byte, error) {
+	id, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace %q: %w", s, err)
+	}
+	if len(id) == 0 || len(id) > 10 {
+		return nil, fmt.Errorf("invalid namespace %q: must be 1 to 10 bytes", s)
+	}
+	ns := make([]byte, 29)
+	copy(ns[29-len(id):], id)
+	return ns, nil
+}
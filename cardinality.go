@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// otherLabelValue replaces the label values of the series a metric has
+// beyond the --metrics.max-series limit.
+const otherLabelValue = "other"
+
+var seriesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "exporter_series_dropped_total",
+	Help: "Number of series folded into the other series of a metric because the metric had more than --metrics.max-series series, counted on every scrape and push",
+}, []string{"metric"})
+
+func init() {
+	addExporterMetrics(seriesDropped)
+}
+
+// cardinalityGatherer keeps metrics labelled by namespace, peer or the like
+// from exploding: of a metric with more than limit series, the series
+// beyond the first limit are summed up into one series per node whose other
+// labels are set to "other". The labels in keep, the node labels and the
+// constant labels, are left as they are.
+type cardinalityGatherer struct {
+	gatherer prometheus.Gatherer
+	limit    int
+	keep     map[string]bool
+}
+
+func newCardinalityGatherer(g prometheus.Gatherer, limit int, constLabels prometheus.Labels) cardinalityGatherer {
+	keep := make(map[string]bool)
+	for name := range reservedLabels {
+		keep[name] = true
+	}
+	for name := range constLabels {
+		keep[name] = true
+	}
+	return cardinalityGatherer{gatherer: g, limit: limit, keep: keep}
+}
+
+func (g cardinalityGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if g.limit <= 0 {
+		return families, err
+	}
+	for _, mf := range families {
+		if len(mf.Metric) <= g.limit {
+			continue
+		}
+		overflow := mf.Metric[g.limit:]
+		mf.Metric = mf.Metric[:g.limit]
+		others := make(map[string]*dto.Metric)
+		var keys []string
+		dropped := 0
+		for _, m := range overflow {
+			key, labels, rewritten := g.otherLabels(m)
+			// A series whose labels are all kept, e.g. one labelled only
+			// by node, stays as it is.
+			if rewritten {
+				dropped++
+			}
+			other, ok := others[key]
+			if !ok {
+				other = &dto.Metric{Label: labels}
+				others[key] = other
+				keys = append(keys, key)
+			}
+			mergeMetric(mf.GetType(), other, m)
+		}
+		for _, key := range keys {
+			mf.Metric = append(mf.Metric, others[key])
+		}
+		if dropped > 0 {
+			seriesDropped.WithLabelValues(mf.GetName()).Add(float64(dropped))
+		}
+	}
+	return families, err
+}
+
+// otherLabels returns the labels of the other series m is folded into, a
+// key identifying them and whether any label value of m was rewritten.
+func (g cardinalityGatherer) otherLabels(m *dto.Metric) (string, []*dto.LabelPair, bool) {
+	rewritten := false
+	var key strings.Builder
+	labels := make([]*dto.LabelPair, 0, len(m.Label))
+	for _, lp := range m.Label {
+		name, value := lp.GetName(), lp.GetValue()
+		if !g.keep[name] && value != otherLabelValue {
+			value = otherLabelValue
+			rewritten = true
+		}
+		key.WriteString(name + "=" + value + "\xff")
+		labels = append(labels, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return key.String(), labels, rewritten
+}
+
+// mergeMetric adds the value of m to other. Summaries only keep their count
+// and sum, quantiles can't be added up.
+func mergeMetric(t dto.MetricType, other, m *dto.Metric) {
+	switch t {
+	case dto.MetricType_COUNTER:
+		if other.Counter == nil {
+			other.Counter = &dto.Counter{Value: new(float64)}
+		}
+		*other.Counter.Value += m.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		if other.Gauge == nil {
+			other.Gauge = &dto.Gauge{Value: new(float64)}
+		}
+		*other.Gauge.Value += m.GetGauge().GetValue()
+	case dto.MetricType_UNTYPED:
+		if other.Untyped == nil {
+			other.Untyped = &dto.Untyped{Value: new(float64)}
+		}
+		*other.Untyped.Value += m.GetUntyped().GetValue()
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		if other.Histogram == nil {
+			other.Histogram = &dto.Histogram{SampleCount: new(uint64), SampleSum: new(float64)}
+			for _, b := range h.GetBucket() {
+				upper := b.GetUpperBound()
+				other.Histogram.Bucket = append(other.Histogram.Bucket, &dto.Bucket{CumulativeCount: new(uint64), UpperBound: &upper})
+			}
+		}
+		*other.Histogram.SampleCount += h.GetSampleCount()
+		*other.Histogram.SampleSum += h.GetSampleSum()
+		// The series of a histogram share their buckets.
+		for i, b := range h.GetBucket() {
+			if i < len(other.Histogram.Bucket) {
+				*other.Histogram.Bucket[i].CumulativeCount += b.GetCumulativeCount()
+			}
+		}
+	case dto.MetricType_SUMMARY:
+		if other.Summary == nil {
+			other.Summary = &dto.Summary{SampleCount: new(uint64), SampleSum: new(float64)}
+		}
+		*other.Summary.SampleCount += m.GetSummary().GetSampleCount()
+		*other.Summary.SampleSum += m.GetSummary().GetSampleSum()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// catalogAPI serves /api/v1/metrics, the catalog of the landing page: the
+// monitored targets and every exported metric with its help, type and
+// current values. It reads the exported metrics, under the names /metrics
+// serves them, so the catalog shows what can be queried in Prometheus. The
+// per-target metrics without series yet, e.g. of collectors that don't
+// apply to the nodes, are listed without values.
+type catalogAPI struct {
+	gatherer prometheus.Gatherer
+	health   *healthTracker
+	exporter *exporter
+	naming   metricNaming
+}
+
+type catalog struct {
+	Targets []catalogTarget `json:"targets"`
+	Metrics []catalogMetric `json:"metrics"`
+}
+
+type catalogTarget struct {
+	Name       string   `json:"name"`
+	Endpoint   string   `json:"endpoint"`
+	Network    string   `json:"network"`
+	NodeType   string   `json:"node_type"`
+	Collectors []string `json:"collectors"`
+}
+
+type catalogMetric struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Help   string          `json:"help"`
+	Series []catalogSeries `json:"series"`
+}
+
+// catalogSeries is a series of a metric. Histograms and summaries have a
+// count and sum instead of a value.
+type catalogSeries struct {
+	Labels map[string]string `json:"labels"`
+	Value  *float64          `json:"value,omitempty"`
+	Count  *uint64           `json:"count,omitempty"`
+	Sum    *float64          `json:"sum,omitempty"`
+}
+
+func (a *catalogAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	families, err := a.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _, health := a.health.status()
+
+	c := catalog{Targets: []catalogTarget{}, Metrics: make([]catalogMetric, 0, len(families))}
+	for _, t := range a.exporter.targets() {
+		ct := catalogTarget{Name: t.Name, Endpoint: t.Endpoint, Network: t.P2PNetwork, NodeType: t.NodeType, Collectors: []string{}}
+		// The collectors that apply to the node are only known once it
+		// was queried, the health tracker has them from then on.
+		if ts, ok := health.Targets[t.Name]; ok {
+			ct.Collectors = sortedKeys(ts.Collectors)
+		}
+		c.Targets = append(c.Targets, ct)
+	}
+	for _, mf := range families {
+		m := catalogMetric{
+			Name:   mf.GetName(),
+			Type:   strings.ToLower(mf.GetType().String()),
+			Help:   mf.GetHelp(),
+			Series: make([]catalogSeries, 0, len(mf.Metric)),
+		}
+		for _, dm := range mf.Metric {
+			s := catalogSeries{Labels: metricLabels(dm)}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				s.Value = dm.Counter.Value
+			case dto.MetricType_GAUGE:
+				s.Value = dm.Gauge.Value
+			case dto.MetricType_UNTYPED:
+				s.Value = dm.Untyped.Value
+			case dto.MetricType_HISTOGRAM:
+				s.Count, s.Sum = dm.Histogram.SampleCount, dm.Histogram.SampleSum
+			case dto.MetricType_SUMMARY:
+				s.Count, s.Sum = dm.Summary.SampleCount, dm.Summary.SampleSum
+			}
+			m.Series = append(m.Series, s)
+		}
+		c.Metrics = append(c.Metrics, m)
+	}
+	if described, err := describeTargetMetrics(); err == nil {
+		seen := make(map[string]bool, len(c.Metrics))
+		for _, m := range c.Metrics {
+			seen[m.Name] = true
+		}
+		for _, m := range a.naming.describe(described) {
+			if !seen[m.name] {
+				c.Metrics = append(c.Metrics, catalogMetric{Name: m.name, Type: m.kind, Help: m.help, Series: []catalogSeries{}})
+			}
+		}
+	}
+	sort.Slice(c.Metrics, func(i, j int) bool { return c.Metrics[i].Name < c.Metrics[j].Name })
+	writeJSON(w, c)
+}
@@ -1,156 +1,888 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"flag"
+	"context"
+	"crypto/tls"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
-	"os/exec"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-var (
-	localHeight = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "bridge_local_height",
-		Help: "Local height of the Celestia node",
-	})
+	"github.com/spf13/cobra"
 
-	networkHeight = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "bridge_network_height",
-		Help: "Network height of the Celestia node",
-	})
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/s3"
 )
 
-func init() {
-	prometheus.MustRegister(localHeight)
-	prometheus.MustRegister(networkHeight)
-}
+var targetLabels = []string{"node", "endpoint", "network"}
 
 func main() {
-	listenPort := flag.String("listen.port", "8380", "port to listen on")
-	endpoint := flag.String("endpoint", "http://localhost:26658", "endpoint to connect to")
-	p2pNetwork := flag.String("p2p.network", "blockspacerace", "network to use")
-	nodeStorePath := flag.String("node.store", "/default/path", "custom node store path")
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
 
-	flag.Parse()
+// newExportCommand returns the command running the exporter. It is both the
+// root command and the export subcommand, so running the binary without a
+// subcommand keeps working as before.
+func newExportCommand(use string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: "Export metrics of Celestia nodes to Prometheus",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	listenPort := fs.String("listen.port", "8380", "port to listen on")
+	listenAddress := fs.String("listen.address", "", "comma-separated list of addresses to listen on instead of all addresses on --listen.port, e.g. 127.0.0.1:8380,[::1]:8380")
+	listenFamily := fs.String("listen.family", "any", "IP version of the listeners: any (dual-stack), ipv4 or ipv6 (IPv6 only)")
+	listenSocket := fs.String("listen.socket", "", "Unix domain socket to listen on instead of --listen.port, e.g. /run/celbridge/metrics.sock")
+	listenSocketMode := fs.String("listen.socket-mode", "0660", "file mode of --listen.socket, in octal")
+	endpoint := fs.String("endpoint", "http://localhost:26658", "endpoint to connect to")
+	endpoints := fs.String("endpoints", "", "comma-separated list of endpoints (optionally name=endpoint) to connect to, overrides --endpoint")
+	p2pNetwork := fs.String("p2p.network", "blockspacerace", "network to use")
+	nodeStorePath := fs.String("node.store", "/default/path", "custom node store path")
+	dataDir := fs.String("node.data-dir", "", "data directory of the node to report the disk usage of, e.g. ~/.celestia-bridge-mocha-4; requires the exporter to run on the node's host")
+	pidFile := fs.String("node.pid-file", "", "pid file of the node process to export the CPU, memory and file descriptor usage of; requires the exporter to run on the node's host")
+	processName := fs.String("node.process-name", "", "name of the node process (e.g. celestia) to export the resource usage of, if --node.pid-file isn't set")
+	configFile := fs.String("config", "", "path to a YAML config file, reloaded on SIGHUP")
+	discoveryRefresh := fs.Duration("discovery.refresh-interval", 30*time.Second, "interval at which the discovered targets are refreshed")
+	kubeSelector := fs.String("discovery.kubernetes.selector", "", "label selector of the Kubernetes pods or services to monitor, e.g. app=celestia-bridge, enables the Kubernetes discovery")
+	kubeNamespace := fs.String("discovery.kubernetes.namespace", "", "namespace to discover the nodes in, defaults to the namespace of the exporter")
+	kubeRole := fs.String("discovery.kubernetes.role", "pod", "kind of objects to discover: pod or service")
+	kubePort := fs.Int("discovery.kubernetes.port", 26658, "node RPC port of the discovered pods and services without an rpc port or celestia-exporter/port annotation")
+	kubeAPIServer := fs.String("discovery.kubernetes.api-server", "", "Kubernetes API server to use instead of the in-cluster one, e.g. http://localhost:8001 of kubectl proxy")
+	discoveryFiles := fs.String("discovery.files", "", "comma-separated list of target files in the file_sd format of Prometheus, JSON or YAML, e.g. /etc/celbridge_export/targets/*.yml")
+	dockerDiscovery := fs.Bool("discovery.docker", false, "discover the nodes to monitor from the running containers of the Docker daemon")
+	dockerHost := fs.String("discovery.docker.host", "unix:///var/run/docker.sock", "address of the Docker daemon, unix:///path or tcp://host:port")
+	dockerImage := fs.String("discovery.docker.image", "celestia-node", "containers whose image contains this string are discovered")
+	dockerLabel := fs.String("discovery.docker.label", "", "containers with this label, given as name or name=value, are discovered as well")
+	dockerNetwork := fs.String("discovery.docker.network", "", "Docker network to reach the containers in, e.g. when the exporter runs in the same docker-compose project; by default the published RPC port is used")
+	dockerPort := fs.Int("discovery.docker.port", 26658, "node RPC port of the discovered containers without a celestia-exporter.port label")
+	nodeType := fs.String("node.type", nodeTypeAuto, "type of the monitored nodes: bridge, full, light, or auto to detect it via node.Info")
+	p2pProtocols := fs.String("p2p.protocols", "", "comma-separated list of libp2p protocol IDs to report bandwidth usage for")
+	balanceAddresses := fs.String("balance.addresses", "", "comma-separated list of additional addresses to export the balance of")
+	authToken := fs.String("auth.token", "", "auth token for the node RPC, overrides the "+authTokenEnv+" environment variable")
+	authTokenFile := fs.String("auth.token-file", "", "file to read the auth token from, re-read when it changes")
+	authTokenEnv := fs.String("auth.token-env", "", "environment variable to read the auth token from, instead of "+authTokenEnv)
+	authTokenCommand := fs.String("auth.token-command", "", "command printing the auth token, with {{.Name}}, {{.Endpoint}}, {{.NodeType}}, {{.Network}} and {{.NodeStore}} of the node, e.g. \"celestia {{.NodeType}} auth read --p2p.network {{.Network}}\"")
+	authTokenTTL := fs.Duration("auth.token-ttl", 0, "how long a token printed by the token command or the celestia binary is used before it is generated again, 0 until the node rejects it")
+	authScope := fs.String("auth.scope", authScopeAdmin, "permission of the auth tokens: admin, or read to skip the collectors that need admin or write permission (p2p, info and canary)")
+	healthFailureThreshold := fs.Int("health.failure-threshold", 3, "consecutive failed scrapes after which a collector makes /readyz fail")
+	collectorsEnable := fs.String("collectors.enable", "", "comma-separated list of collectors to run, all if empty (available: "+strings.Join(collectorNames(), ", ")+")")
+	collectorsDisable := fs.String("collectors.disable", "", "comma-separated list of collectors not to run")
+	consensusEndpoint := fs.String("consensus.endpoint", "", "CometBFT RPC of the paired consensus node, enables the fees and mempool collectors and, with --validator.address, the validator and blocks collectors")
+	appGRPC := fs.String("app.grpc", "", "gRPC server of celestia-app, e.g. localhost:9090, to send the staking, bank and blob queries to; enables the app collector")
+	stakingValidators := fs.String("staking.validators", "", "comma-separated list of operator addresses of further validators to export the stake, delegations and rewards of and to watch for slashes and governance votes")
+	ibcChannels := fs.String("ibc.channels", "", "comma-separated list of IBC channels (port/channel, or channel on the transfer port) to export the state and client expiry of, enables the ibc collector")
+	probePeers := fs.String("probe.peers", "", "comma-separated list of bootstrappers and trusted peers, as TCP multiaddrs (/dns4/host/tcp/2121) or host:port, to measure the reachability and connect latency of from the exporter, enables the probe collector")
+	probeMethods := fs.String("methods.probe", "", "comma-separated JSON-RPC methods the methods collector calls without parameters every scrape, e.g. das.SamplingStats,state.Balance, enables the methods collector")
+	referenceEndpoint := fs.String("reference.endpoint", "", "trusted CometBFT RPC, e.g. a public one, to compare the height and the block hashes of the nodes with, enables the reference collector")
+	snapshotLocation := fs.String("snapshot.location", "", "directory or s3://bucket/prefix URL holding the snapshots of the node, enables the snapshot collector exporting the age and size of the newest one")
+	snapshotS3Endpoint := fs.String("snapshot.s3.endpoint", "https://s3.amazonaws.com", "endpoint of the S3-compatible object storage of s3:// snapshot locations; the credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+	snapshotS3Region := fs.String("snapshot.s3.region", "us-east-1", "region the requests to --snapshot.s3.endpoint are signed for")
+	validatorAddress := fs.String("validator.address", "", "operator address (celestiavaloper...) of the validator to monitor via --consensus.endpoint")
+	// The flag's name before it matched the config file.
+	fs.StringVar(validatorAddress, "consensus.validator", "", "deprecated name of --validator.address")
+	fs.MarkDeprecated("consensus.validator", "use --validator.address instead")
+	missedBlocksWindow := fs.Int("consensus.missed-blocks-window", 100, "number of most recent blocks celestia_validator_window_missed_blocks counts the missed blocks of --validator.address in")
+	canaryNamespace := fs.String("canary.namespace", "", "hex namespace ID to periodically submit and read back a canary blob under, paid from the node's wallet")
+	auditSamples := fs.Int("audit.samples", 0, "number of random historical heights the audit collector fetches the extended data square of per run, 0 disables the audit")
+	auditWindow := fs.Int("audit.window", 0, "number of most recent blocks the audited heights are picked from, all blocks if 0")
+	auditNamespaces := fs.String("audit.namespaces", "", "comma-separated list of hex namespace IDs whose blobs the audit collector fetches at the audited heights")
+	canaryInterval := fs.Duration("canary.interval", 5*time.Minute, "interval of the blob canary, unless set via --scrape.collector-intervals")
+	canaryTimeout := fs.Duration("canary.timeout", 2*time.Minute, "timeout of a blob canary run, unless set via --scrape.collector-timeouts")
+	chainHaltAfter := fs.Duration("chain.halt-after", 5*time.Minute, "time the network head has to stand still on all reachable nodes of a network before celestia_chain_halted is set")
+	geoIPCountryDB := fs.String("p2p.geoip.country-db", "", "MaxMind GeoLite2/GeoIP2 Country or City database to count the peers of the p2p collector by country with")
+	geoIPASNDB := fs.String("p2p.geoip.asn-db", "", "MaxMind GeoLite2/GeoIP2 ASN database to count the peers of the p2p collector by autonomous system with")
+	scrapeInterval := fs.Duration("scrape.interval", 5*time.Second, "interval at which the nodes are polled")
+	scrapeTimeout := fs.Duration("scrape.timeout", 10*time.Second, "maximum duration of a single collector run")
+	collectorIntervals := fs.String("scrape.collector-intervals", "", "comma-separated list of collector=duration pairs overriding --scrape.interval")
+	collectorTimeouts := fs.String("scrape.collector-timeouts", "", "comma-separated list of collector=duration pairs overriding --scrape.timeout")
+	onDemand := fs.Bool("scrape.on-demand", false, "collect metrics when /metrics is scraped instead of polling in the background")
+	cacheTTL := fs.Duration("scrape.cache-ttl", 0, "with --scrape.on-demand, reuse collected metrics for scrapes within this duration")
+	var retry celestiarpc.RetryPolicy
+	fs.IntVar(&retry.MaxAttempts, "rpc.retry.max-attempts", 3, "maximum number of attempts of an rpc request failing with a transient error, 1 disables retries")
+	fs.DurationVar(&retry.BaseDelay, "rpc.retry.base-delay", 200*time.Millisecond, "delay before the first retry, doubled for every further one")
+	fs.DurationVar(&retry.MaxDelay, "rpc.retry.max-delay", 2*time.Second, "maximum delay between retries")
+	fs.Float64Var(&retry.Jitter, "rpc.retry.jitter", 0.2, "fraction by which retry delays are randomized")
+	var breaker breakerConfig
+	fs.IntVar(&breaker.threshold, "rpc.circuit.failure-threshold", 5, "consecutive unreachable rpc requests after which requests to the node are paused, 0 disables the circuit breaker")
+	fs.DurationVar(&breaker.openDuration, "rpc.circuit.open-duration", 30*time.Second, "how long requests are paused before a probe request checks whether the node recovered")
+	var limits limitConfig
+	fs.Float64Var(&limits.maxQPS, "rpc.max-qps", 0, "maximum number of rpc requests per second sent to each node, 0 for no limit")
+	fs.IntVar(&limits.maxConcurrency, "rpc.max-concurrency", 0, "maximum number of concurrent rpc requests to each node, 0 for no limit")
+	fs.IntVar(&limits.workers, "scrape.workers", 0, "maximum number of collector runs in progress across all nodes, 0 for no limit")
+	var endpointTLS targetTLS
+	fs.StringVar(&endpointTLS.CAFile, "endpoint.ca-file", "", "CA certificates to verify https:// endpoints against instead of the system roots")
+	fs.StringVar(&endpointTLS.CertFile, "endpoint.cert", "", "client certificate to present to https:// endpoints")
+	fs.StringVar(&endpointTLS.KeyFile, "endpoint.key", "", "private key of --endpoint.cert")
+	fs.BoolVar(&endpointTLS.InsecureSkipVerify, "endpoint.insecure-skip-verify", false, "don't verify the certificates of https:// endpoints, insecure")
+	fallbacks := fs.String("endpoint.fallbacks", "", "comma-separated fallback endpoints of the node, queried in order while --endpoint is unreachable")
+	failoverRecheck := fs.Duration("endpoint.failover-recheck", time.Minute, "how often the primary endpoint of a node is tried again while a fallback endpoint is in use")
+	mock := fs.Bool("mock", false, "monitor simulated bridge, full and light nodes instead of --endpoint, to develop dashboards and alert rules without a node")
+	mockBlockTime := fs.Duration("mock.block-time", 6*time.Second, "block time of the chain of the simulated nodes of --mock")
+	replay := fs.String("replay", "", "comma-separated list of fixture files recorded with the record command, monitored as nodes named after the files instead of --endpoint")
+	proxyURL := fs.String("endpoint.proxy-url", "", "HTTP(S) or SOCKS5 proxy for requests to the endpoints, overrides the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables")
+	webTLS := webTLSConfig{}
+	fs.StringVar(&webTLS.CertFile, "web.tls.cert", "", "certificate file to serve the HTTP endpoints over HTTPS")
+	fs.StringVar(&webTLS.KeyFile, "web.tls.key", "", "private key file of --web.tls.cert")
+	fs.StringVar(&webTLS.ClientCAFile, "web.tls.client-ca", "", "CA certificates to verify client certificates against, requires clients to present one")
+	fs.StringVar(&webTLS.ClientAuthType, "web.tls.client-auth-type", "", "client certificate policy: NoClientCert, RequestClientCert, RequireAnyClientCert, VerifyClientCertIfGiven or RequireAndVerifyClientCert (default with --web.tls.client-ca)")
+	debugPprof := fs.Bool("debug.pprof", false, "serve the pprof profiles under /debug/pprof/ and the expvar variables under /debug/vars")
+	telemetryPath := fs.String("web.telemetry-path", "/metrics", "path under which the metrics are served")
+	adminListenAddress := fs.String("web.admin-listen-address", "", "serve the status page, the /api/v1/ endpoints and, with --debug.pprof, the debug endpoints on this address, e.g. localhost:8381, instead of on --listen.port, which then only serves the metrics and the health endpoints")
+	debugListenAddress := fs.String("debug.listen-address", "", "with --debug.pprof, serve the debug endpoints on this address, e.g. localhost:6060, without authentication instead of on --listen.port")
+	webConfigFile := fs.String("web.config.file", "", "Prometheus exporter-toolkit web config file with the TLS, basic auth and HTTP/2 settings, instead of --web.tls.* and --web.auth.users-file")
+	webUsersFile := fs.String("web.auth.users-file", "", "YAML file with basic_auth_users (user: bcrypt hash) allowed to scrape /metrics")
+	webBearerToken := fs.String("web.auth.bearer-token", "", "bearer token allowed to scrape /metrics")
+	otlpEndpoint := fs.String("otlp.endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector to push the metrics to, e.g. http://localhost:4318")
+	otlpInterval := fs.Duration("otlp.interval", 30*time.Second, "interval at which the metrics are pushed to --otlp.endpoint")
+	otlpHeaders := fs.String("otlp.headers", "", "comma-separated list of key=value HTTP headers sent with OTLP pushes, e.g. for authentication")
+	otlpAttributes := fs.String("otlp.resource-attributes", "", "comma-separated list of key=value resource attributes of the pushed metrics and traces")
+	tracingEndpoint := fs.String("tracing.endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector to send a trace of every rpc request to, e.g. http://localhost:4318; also adds trace IDs as exemplars to exporter_rpc_duration_seconds")
+	tracingSampleRatio := fs.Float64("tracing.sample-ratio", 1, "fraction of the rpc requests that are traced")
+	pushURL := fs.String("push.url", "", "URL to push the metrics to, for nodes that can't be scraped: a remote_write endpoint or a Pushgateway, see --push.mode")
+	pushMode := fs.String("push.mode", "remote_write", "protocol of --push.url: remote_write or pushgateway")
+	pushInterval := fs.Duration("push.interval", 30*time.Second, "interval at which the metrics are pushed to --push.url")
+	pushJob := fs.String("push.job", "celestia_exporter", "job name the metrics are pushed under in pushgateway mode")
+	pushInstance := fs.String("push.instance", "", "instance grouping label in pushgateway mode, defaults to the hostname")
+	influxURL := fs.String("influx.url", "", "URL of an InfluxDB v2 server to write the metrics to as line protocol, e.g. http://localhost:8086")
+	influxOrg := fs.String("influx.org", "", "InfluxDB organization to write to")
+	influxBucket := fs.String("influx.bucket", "", "InfluxDB bucket to write to")
+	influxToken := fs.String("influx.token", "", "InfluxDB API token with write access to --influx.bucket")
+	influxInterval := fs.Duration("influx.interval", 30*time.Second, "interval at which the metrics are written to --influx.url")
+	alertInterval := fs.Duration("alert.interval", 30*time.Second, "interval at which the alert rules are evaluated")
+	alertSyncLag := fs.Int("alert.sync-lag", 0, "alert when a node is more than this many blocks behind the network head, 0 disables the rule")
+	alertUnreachableFor := fs.Duration("alert.unreachable-for", 0, "alert when all collectors of a node have been failing for this long, 0 disables the rule")
+	alertMinBalance := fs.Float64("alert.min-balance", 0, "alert when a watched wallet holds less than this many utia, 0 disables the rule")
+	alertMissedBlocks := fs.Int("alert.missed-blocks", 0, "alert when the validator missed more than this many of the last --consensus.missed-blocks-window blocks, 0 disables the rule")
+	alertJailed := fs.Bool("alert.validator-jailed", false, "alert when a watched validator is jailed or tombstoned")
+	alertUpgradeBlocks := fs.Int("alert.upgrade-blocks", 0, "alert when a scheduled network upgrade takes effect within this many blocks, 0 disables the rule")
+	alertChainHalted := fs.Bool("alert.chain-halted", false, "alert when a network is halted, see --chain.halt-after")
+	alertIBCExpiry := fs.Duration("alert.ibc-client-expiry", 0, "alert when the light client of a watched IBC channel expires within this duration, 0 disables the rule")
+	alertWebhooks := fs.String("alert.webhook-urls", "", "comma-separated list of URLs alerts are posted to as JSON")
+	alertTelegramToken := fs.String("alert.telegram.bot-token", "", "token of the Telegram bot that sends alerts")
+	alertTelegramChat := fs.String("alert.telegram.chat-id", "", "Telegram chat the alerts are sent to")
+	alertDiscordWebhook := fs.String("alert.discord.webhook-url", "", "Discord webhook URL of the channel the alerts are sent to")
+	alertPagerDutyKey := fs.String("alert.pagerduty.routing-key", "", "routing key of the PagerDuty Events API v2 integration alerts are sent to")
+	alertOpsgenieKey := fs.String("alert.opsgenie.api-key", "", "Opsgenie API key alerts are created with")
+	alertOpsgenieURL := fs.String("alert.opsgenie.api-url", "https://api.opsgenie.com", "Opsgenie API URL, https://api.eu.opsgenie.com for the EU instance")
+	alertEmailServer := fs.String("alert.email.smtp-server", "", "SMTP server, host:port, alerts are mailed via")
+	alertEmailTLS := fs.String("alert.email.tls", emailSTARTTLS, "connection security of --alert.email.smtp-server: starttls, tls (implicit TLS, usually port 465) or none")
+	alertEmailUsername := fs.String("alert.email.username", "", "username to authenticate to --alert.email.smtp-server with")
+	alertEmailPassword := fs.String("alert.email.password", "", "password of --alert.email.username")
+	alertEmailFrom := fs.String("alert.email.from", "", "sender address of the alert emails")
+	alertEmailTo := fs.String("alert.email.to", "", "comma-separated list of addresses the alerts are mailed to")
+	alertEmailSubject := fs.String("alert.email.subject-template", defaultEmailSubject, "Go text/template of the subject of the alert emails")
+	alertEmailBody := fs.String("alert.email.body-template", defaultEmailBody, "Go text/template of the body of the alert emails")
+	alertEmailMinInterval := fs.Duration("alert.email.min-interval", 15*time.Minute, "minimum time between two emails about the same alert firing, 0 mails every notification")
+	alertCooldowns := fs.String("alert.cooldowns", "", "comma-separated list of rule=duration pairs, e.g. sync_lag=30m, for which a resolved alert doesn't fire again")
+	alertGroupByNode := fs.Bool("alert.group-by-node", false, "send the alerts of a node firing or resolving in the same evaluation as one notification, to the webhook, Telegram, Discord and email notifiers")
+	alertSendResolved := fs.Bool("alert.send-resolved", true, "notify the webhook, Telegram, Discord and email notifiers when an alert resolves; PagerDuty and Opsgenie always get the resolutions to close their incidents")
+	alertStateFile := fs.String("alert.state-file", "", "file the active alerts are persisted in, so a restart neither fires them again nor forgets to resolve them")
+	alertPageSeverity := fs.String("alert.page-severity", severityCritical, "minimum severity of the alerts sent to PagerDuty and Opsgenie: warning or critical")
+	naming := addNamingFlags(fs)
+	runtimeMetrics := fs.Bool("metrics.runtime", true, "export Go runtime (go_*) and process (process_*) metrics of the exporter itself")
+	metricsInclude := fs.String("metrics.include", "", "regular expression the exported metric names have to match to be exported, e.g. 'celestia_(header|das)_.*'")
+	metricsExclude := fs.String("metrics.exclude", "", "regular expression of exported metric names not to export, e.g. 'celestia_p2p_peers_by_.*'")
+	maxSeries := fs.Int("metrics.max-series", 0, "maximum number of series exported per metric, the series beyond are summed up into an \"other\" series per node; 0 disables the limit")
+	metricLabels := fs.String("metrics.labels", "", "comma-separated list of name=value labels added to every exported series, e.g. datacenter=fra1,team=infra")
+	stateFile := fs.String("state.file", "", "JSON file to keep heights and counters in across restarts, e.g. to detect node rollbacks while the exporter was down")
+	stateSaveInterval := fs.Duration("state.save-interval", time.Minute, "interval at which --state.file is written")
+	reportS3URL := fs.String("report.s3.url", "", "s3://bucket/prefix URL to upload the JSON status report and --state.file of the exporter to, under <prefix>/<instance>/")
+	reportS3Endpoint := fs.String("report.s3.endpoint", "https://s3.amazonaws.com", "endpoint of the S3-compatible object storage of --report.s3.url; the credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN")
+	reportS3Region := fs.String("report.s3.region", "us-east-1", "region the requests to --report.s3.endpoint are signed for")
+	reportS3Interval := fs.Duration("report.s3.interval", 5*time.Minute, "interval at which the report is uploaded to --report.s3.url")
+	reportSlackWebhook := fs.String("report.slack.webhook-url", "", "Slack incoming webhook URL to post a daily digest of the nodes to: uptime, max sync lag, blobs observed and utia spent")
+	reportSlackTime := fs.String("report.slack.time", "09:00", "time of day in UTC, as HH:MM, at which the daily digest is posted to --report.slack.webhook-url")
+	reportS3Instance := fs.String("report.s3.instance", "", "name of the exporter in the report keys, defaults to the hostname")
+	eventsInterval := fs.Duration("events.interval", 15*time.Second, "interval at which the nodes are checked for the state changes streamed on /api/v1/events")
+	eventsPeerDrop := fs.Float64("events.peer-drop", 0.5, "fraction of its peers a node has to lose between two checks for a peers_dropped event, 0 disables the event")
+	eventsMinBalance := fs.Float64("events.min-balance", 0, "balance in utia whose crossing by a watched wallet is streamed as an event, 0 disables the events")
+	historyRetention := fs.Duration("history.retention", 6*time.Hour, "how long the heights and sync lag served on /api/v1/history are kept in memory")
+	historyResolution := fs.Duration("history.resolution", 30*time.Second, "interval at which the heights and sync lag are sampled for /api/v1/history")
+	shutdownTimeout := fs.Duration("shutdown.timeout", 10*time.Second, "time to wait for in-flight scrapes and collections on SIGINT/SIGTERM before cancelling them")
+	adaptiveInterval := fs.Bool("scrape.adaptive", false, "run the header and das collectors every half block time as observed, within --scrape.adaptive-min and --scrape.adaptive-max, instead of at their interval")
+	adaptiveMin := fs.Duration("scrape.adaptive-min", time.Second, "shortest interval of the collectors with --scrape.adaptive")
+	adaptiveMax := fs.Duration("scrape.adaptive-max", time.Minute, "longest interval of the collectors with --scrape.adaptive")
+	subscribe := fs.Bool("subscribe", false, "update heights from a header.Subscribe WebSocket subscription instead of polling")
+	headerVerifyDepth := fs.Int("header.verify-depth", 10, "number of most recent headers the headerchain collector checks for gaps")
+	syncLagThreshold := fs.Int("sync.lag-threshold", 5, "maximum number of blocks behind the network head for a node to count as synced")
 
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		promhttp.Handler().ServeHTTP(w, r)
-	})
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		started := time.Now()
+		intervals, err := parseDurationList(*collectorIntervals)
+		if err != nil {
+			log.Fatalf("Error parsing --scrape.collector-intervals: %v\n", err)
+		}
+		timeouts, err := parseDurationList(*collectorTimeouts)
+		if err != nil {
+			log.Fatalf("Error parsing --scrape.collector-timeouts: %v\n", err)
+		}
+
+		// The canary pays fees and waits for its blob to be included, so it
+		// doesn't use the scrape interval and timeout.
+		intervals = withDefaultDuration(intervals, "canary", *canaryInterval)
+		timeouts = withDefaultDuration(timeouts, "canary", *canaryTimeout)
+		// Walking the data directory of a bridge node is slow.
+		intervals = withDefaultDuration(intervals, "disk", time.Minute)
 
-	go func() {
-		client := &http.Client{}
-		authToken := getAuthToken(*p2pNetwork, *nodeStorePath) // Update this line
-		for {
-			updateMetrics(client, authToken, *endpoint)
-			time.Sleep(5 * time.Second)
+		var defaultTLS *targetTLS
+		if endpointTLS != (targetTLS{}) {
+			defaultTLS = &endpointTLS
 		}
-	}()
 
-	log.Printf("Celestia Bridge Exporter started on port %s\n", *listenPort)
-	log.Fatal(http.ListenAndServe(":"+*listenPort, nil))
-}
+		defaults := config{
+			ScrapeInterval:     *scrapeInterval,
+			ScrapeTimeout:      *scrapeTimeout,
+			CollectorIntervals: intervals,
+			CollectorTimeouts:  timeouts,
+			AdaptiveInterval:   *adaptiveInterval,
+			AdaptiveMin:        *adaptiveMin,
+			AdaptiveMax:        *adaptiveMax,
+			P2PNetwork:         *p2pNetwork,
+			NodeStore:          *nodeStorePath,
+			DataDir:            *dataDir,
+			PIDFile:            *pidFile,
+			ProcessName:        *processName,
+			SyncLagThreshold:   *syncLagThreshold,
+			HeaderVerifyDepth:  *headerVerifyDepth,
+			MissedBlocksWindow: *missedBlocksWindow,
+			Subscribe:          *subscribe,
+			NodeType:           *nodeType,
+			P2PProtocols:       splitList(*p2pProtocols),
+			BalanceAddresses:   splitList(*balanceAddresses),
+			CanaryNamespace:    *canaryNamespace,
+			AuditSamples:       *auditSamples,
+			AuditWindow:        *auditWindow,
+			AuditNamespaces:    splitList(*auditNamespaces),
+			AuthToken:          *authToken,
+			AuthTokenFile:      *authTokenFile,
+			AuthTokenEnv:       *authTokenEnv,
+			AuthTokenCommand:   *authTokenCommand,
+			AuthTokenTTL:       *authTokenTTL,
+			AuthScope:          *authScope,
+			CollectorsEnable:   splitList(*collectorsEnable),
+			CollectorsDisable:  splitList(*collectorsDisable),
+			ConsensusEndpoint:  *consensusEndpoint,
+			AppGRPC:            *appGRPC,
+			StakingValidators:  splitList(*stakingValidators),
+			IBCChannels:        splitList(*ibcChannels),
+			ProbePeers:         splitList(*probePeers),
+			ProbeMethods:       splitList(*probeMethods),
+			SnapshotLocation:   *snapshotLocation,
+			ReferenceEndpoint:  *referenceEndpoint,
+			TLS:                defaultTLS,
+			ProxyURL:           *proxyURL,
+			Fallbacks:          splitList(*fallbacks),
+			ValidatorAddress:   *validatorAddress,
+			SlackWebhookURL:    *reportSlackWebhook,
+		}
+		var discoverers []discoverer
+		if *kubeSelector != "" {
+			kd, err := newKubernetesDiscovery(*kubeAPIServer, *kubeNamespace, *kubeSelector, *kubeRole, *kubePort)
+			if err != nil {
+				log.Fatalf("Error configuring Kubernetes discovery: %v\n", err)
+			}
+			discoverers = append(discoverers, kd)
+		}
+		if *discoveryFiles != "" {
+			fd, err := newFileDiscovery(splitList(*discoveryFiles))
+			if err != nil {
+				log.Fatalf("Error configuring file discovery: %v\n", err)
+			}
+			discoverers = append(discoverers, fd)
+		}
+		if *dockerDiscovery {
+			dd, err := newDockerDiscovery(*dockerHost, *dockerImage, *dockerLabel, *dockerNetwork, *dockerPort)
+			if err != nil {
+				log.Fatalf("Error configuring Docker discovery: %v\n", err)
+			}
+			discoverers = append(discoverers, dd)
+		}
+		disc := newDiscovery(*discoveryRefresh, discoverers...)
 
-func updateMetrics(client *http.Client, authToken, endpoint string) {
-	local, network, err := getHeights(client, authToken, endpoint)
-	if err != nil {
-		log.Printf("Error getting heights: %v\n", err)
-		return
-	}
+		var localTargets []targetConfig
+		if (*mock || *replay != "") && (*configFile != "" || disc.enabled()) {
+			log.Fatalf("--mock and --replay can't be combined with --config or discovery\n")
+		}
+		if *mock {
+			if *mockBlockTime <= 0 {
+				log.Fatalf("Invalid --mock.block-time %s, must be positive\n", *mockBlockTime)
+			}
+			if localTargets, err = startMockNodes(*p2pNetwork, *mockBlockTime); err != nil {
+				log.Fatalf("Error starting the simulated nodes: %v\n", err)
+			}
+		}
+		if *replay != "" {
+			replayTargets, err := startReplayNodes(splitList(*replay))
+			if err != nil {
+				log.Fatalf("Error replaying the fixtures: %v\n", err)
+			}
+			localTargets = append(localTargets, replayTargets...)
+		}
 
-	localHeight.Set(float64(local))
-	networkHeight.Set(float64(network))
-}
+		loadTargets := func() ([]target, error) {
+			var cfg *config
+			if *configFile != "" {
+				var err error
+				if cfg, err = loadConfig(*configFile, defaults, disc.enabled()); err != nil {
+					return nil, err
+				}
+			} else {
+				endpointList := *endpoint
+				if *endpoints != "" {
+					endpointList = *endpoints
+				}
+				c := defaults
+				cfg = &c
+				// With discovery the default endpoint isn't monitored
+				// unless it is given explicitly.
+				if localTargets != nil {
+					cfg.Targets = append(cfg.Targets, localTargets...)
+				} else if !disc.enabled() || fs.Changed("endpoint") || fs.Changed("endpoints") {
+					for _, entry := range splitList(endpointList) {
+						cfg.Targets = append(cfg.Targets, targetConfig{Endpoint: entry})
+					}
+				}
+			}
+			cfg.Targets = append(cfg.Targets, disc.targets()...)
+			if len(cfg.Targets) == 0 && disc.enabled() {
+				return nil, nil
+			}
+			return cfg.buildTargets()
+		}
 
-func getHeights(client *http.Client, authToken, endpoint string) (int, int, error) {
-	local := getHeight(client, authToken, "header.LocalHead", endpoint)
-	network := getHeight(client, authToken, "header.NetworkHead", endpoint)
+		if disc.enabled() {
+			ctx, cancel := context.WithTimeout(context.Background(), *discoveryRefresh)
+			disc.update(ctx)
+			cancel()
+		}
 
-	return local, network, nil
-}
+		targets, err := loadTargets()
+		if err != nil {
+			log.Fatalf("Error loading targets: %v\n", err)
+		}
 
-func getHeight(client *http.Client, authToken, method, endpoint string) int {
-	reqData := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  method,
-		"params":  []interface{}{},
-	}
-	reqBytes, _ := json.Marshal(reqData)
+		if err := naming.validate(); err != nil {
+			log.Fatalf("Error parsing --metrics.prefix: %v\n", err)
+		}
+		include, err := compileMetricFilter(*metricsInclude)
+		if err != nil {
+			log.Fatalf("Error parsing --metrics.include: %v\n", err)
+		}
+		exclude, err := compileMetricFilter(*metricsExclude)
+		if err != nil {
+			log.Fatalf("Error parsing --metrics.exclude: %v\n", err)
+		}
+		if *maxSeries < 0 {
+			log.Fatalf("Invalid --metrics.max-series %d, must not be negative\n", *maxSeries)
+		}
+		constLabels, err := parseMetricLabels(*metricLabels)
+		if err != nil {
+			log.Fatalf("Error parsing --metrics.labels: %v\n", err)
+		}
+		// All metrics are registered through reg, which adds the constant
+		// labels to every series.
+		registry := prometheus.NewRegistry()
+		reg := prometheus.WrapRegistererWith(constLabels, registry)
+		registerExporterMetrics(reg, *runtimeMetrics)
+		// The filters and the series limit apply to what is scraped and
+		// pushed; the alerts, the status API and the halt detection see all
+		// metrics.
+		gatherer := newCardinalityGatherer(filterGatherer{
+			gatherer: namingGatherer{gatherer: registry, naming: *naming},
+			include:  include,
+			exclude:  exclude,
+		}, *maxSeries, constLabels)
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(reqBytes))
-	if err != nil {
-		log.Printf("Error creating request: %v\n", err)
-		return 0
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
+		var webUsers map[string]string
+		webHTTP2, webHeaders := true, map[string]string(nil)
+		switch {
+		case *webConfigFile != "":
+			if webTLS.CertFile != "" || webTLS.KeyFile != "" || webTLS.ClientCAFile != "" || webTLS.ClientAuthType != "" || *webUsersFile != "" {
+				log.Fatalf("--web.config.file can't be combined with --web.tls.* and --web.auth.users-file\n")
+			}
+			wc, err := readWebConfig(*webConfigFile)
+			if err != nil {
+				log.Fatalf("Error loading web config: %v\n", err)
+			}
+			webTLS, webUsers, webHTTP2, webHeaders = wc.tls, wc.users, wc.http2, wc.headers
+		case *webUsersFile != "":
+			if webUsers, err = readUsersFile(*webUsersFile); err != nil {
+				log.Fatalf("Error loading web auth config: %v\n", err)
+			}
+		}
+		auth := newWebAuth(webUsers, *webBearerToken)
+		if *tracingEndpoint != "" {
+			headers, err := parseKeyValueList(*otlpHeaders)
+			if err != nil {
+				log.Fatalf("Error parsing --otlp.headers: %v\n", err)
+			}
+			attributes, err := parseKeyValueList(*otlpAttributes)
+			if err != nil {
+				log.Fatalf("Error parsing --otlp.resource-attributes: %v\n", err)
+			}
+			if rpcTracer, err = newTracer(*tracingEndpoint, headers, attributes, *tracingSampleRatio); err != nil {
+				log.Fatalf("Error configuring tracing: %v\n", err)
+			}
+		}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error executing request: %v\n", err)
-		return 0
-	}
-	defer resp.Body.Close()
+		if !strings.HasPrefix(*telemetryPath, "/") || *telemetryPath == "/" || strings.HasPrefix(*telemetryPath, "/api/") {
+			log.Fatalf("Invalid --web.telemetry-path %q, must start with / and not be / or under /api/\n", *telemetryPath)
+		}
+		mux := http.NewServeMux()
+		// The status page and the API are served by adminMux, mux itself
+		// unless --web.admin-listen-address is set.
+		adminMux := mux
+		if *adminListenAddress != "" {
+			adminMux = http.NewServeMux()
+		}
+		// Set once the exporter is created, in on-demand mode.
+		var onDemandScrapes *onDemandCollector
+		// Exemplars are only exposed in the OpenMetrics format.
+		metricsHandler := promhttp.InstrumentMetricHandler(reg,
+			promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: rpcTracer != nil}))
+		health := newHealthTracker(*healthFailureThreshold, *onDemand)
+		addTelemetryHandlers := func(m *http.ServeMux) {
+			m.Handle(*telemetryPath, auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				onDemandScrapes.refreshFor(r)
+				metricsHandler.ServeHTTP(w, r)
+			})))
+			m.HandleFunc("/healthz", health.serveHealthz)
+			m.HandleFunc("/readyz", health.serveReadyz)
+		}
+		addTelemetryHandlers(mux)
+		if adminMux != mux {
+			addTelemetryHandlers(adminMux)
+		}
+		status := &statusAPI{gatherer: registry, health: health, started: started}
+		adminMux.Handle("/api/v1/status", auth.wrap(status))
+		hist, err := newHistory(registry, *historyRetention, *historyResolution)
+		if err != nil {
+			log.Fatalf("Error configuring the history: %v\n", err)
+		}
+		adminMux.Handle("/api/v1/history", auth.wrap(hist))
+		if *eventsInterval <= 0 {
+			log.Fatalf("Invalid --events.interval %s, must be positive\n", *eventsInterval)
+		}
+		if *eventsPeerDrop < 0 || *eventsPeerDrop > 1 {
+			log.Fatalf("Invalid --events.peer-drop %g, must be between 0 and 1\n", *eventsPeerDrop)
+		}
+		events := newEventStream(registry, health, *eventsPeerDrop, *eventsMinBalance)
+		adminMux.Handle("/api/v1/events", auth.wrap(events))
+		adminMux.Handle("/api/v1/silence", auth.wrap(maintenance))
+		adminMux.Handle("/", auth.wrap(uiHandler(*telemetryPath)))
+		if *debugListenAddress != "" && !*debugPprof {
+			log.Fatalf("--debug.listen-address requires --debug.pprof\n")
+		}
+		var debugServer *http.Server
+		switch {
+		case *debugPprof && *debugListenAddress != "":
+			debugMux := http.NewServeMux()
+			addDebugHandlers(debugMux, func(h http.Handler) http.Handler { return h })
+			debugServer = &http.Server{Addr: *debugListenAddress, Handler: debugMux}
+			go func() {
+				log.Printf("Serving the debug endpoints on %s\n", *debugListenAddress)
+				if err := debugServer.ListenAndServe(); err != http.ErrServerClosed {
+					log.Fatalf("Error serving the debug endpoints: %v\n", err)
+				}
+			}()
+		case *debugPprof:
+			addDebugHandlers(adminMux, auth.wrap)
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Non-OK HTTP status: %v\n", resp.Status)
-		return 0
-	}
+		if *stateFile != "" {
+			if err := exporterState.load(*stateFile); err != nil {
+				log.Fatalf("Error loading state file: %v\n", err)
+			}
+		}
 
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response body: %v\n", err)
-		return 0
-	}
+		if *geoIPCountryDB != "" || *geoIPASNDB != "" {
+			if peerGeoDB, err = openPeerGeo(*geoIPCountryDB, *geoIPASNDB); err != nil {
+				log.Fatalf("Error configuring the peer geolocation: %v\n", err)
+			}
+		}
 
-	var respData map[string]interface{}
-	if err := json.Unmarshal(respBytes, &respData); err != nil {
-		log.Printf("Error unmarshaling response: %v\n", err)
-		return 0
-	}
+		if snapshotS3, err = s3.New(*snapshotS3Endpoint, *snapshotS3Region, s3.CredentialsFromEnv()); err != nil {
+			log.Fatalf("Error configuring the snapshot storage: %v\n", err)
+		}
 
-	result, ok := respData["result"].(map[string]interface{})
-	if !ok {
-		log.Println("Error: result is not a map")
-		return 0
-	}
+		if limits.workers < 0 {
+			log.Fatalf("Invalid --scrape.workers %d, must not be negative\n", limits.workers)
+		}
+		if *failoverRecheck <= 0 {
+			log.Fatalf("Invalid --endpoint.failover-recheck %s, must be positive\n", *failoverRecheck)
+		}
+		exp := newExporter(&http.Client{}, health, *onDemand, retry, breaker, limits, *failoverRecheck)
+		onDemandScrapes = registerTargetMetrics(reg, exp, *cacheTTL)
+		adminMux.Handle("/api/v1/metrics", auth.wrap(&catalogAPI{gatherer: gatherer, health: health, exporter: exp, naming: *naming}))
+		exp.apply(targets)
 
-	header, ok := result["header"].(map[string]interface{})
-	if !ok {
-		log.Println("Error: header is not a map")
-		return 0
+		sinkCtx, stopSinks := context.WithCancel(context.Background())
+		var sinks sync.WaitGroup
+		if *stateFile != "" {
+			exporterState.run(sinkCtx, &sinks, *stateSaveInterval)
+		}
+		if rpcTracer != nil {
+			rpcTracer.run(sinkCtx, &sinks)
+		}
+		hist.run(sinkCtx, &sinks)
+		events.run(sinkCtx, &sinks, *eventsInterval)
+		if *reportS3URL != "" {
+			if *reportS3Interval <= 0 {
+				log.Fatalf("Invalid --report.s3.interval %s, must be positive\n", *reportS3Interval)
+			}
+			client, err := s3.New(*reportS3Endpoint, *reportS3Region, s3.CredentialsFromEnv())
+			if err != nil {
+				log.Fatalf("Error configuring the report storage: %v\n", err)
+			}
+			instance := *reportS3Instance
+			if instance == "" {
+				instance, _ = os.Hostname()
+			}
+			uploader, err := newReportUploader(client, *reportS3URL, instance, status, exporterState)
+			if err != nil {
+				log.Fatalf("Error configuring the report storage: %v\n", err)
+			}
+			uploader.run(sinkCtx, &sinks, *reportS3Interval)
+		}
+		// Targets may have their own webhook, even without the flag.
+		instance, _ := os.Hostname()
+		digest, err := newSlackDigest(registry, health, exp.targets, *reportSlackWebhook, *reportSlackTime, instance)
+		if err != nil {
+			log.Fatalf("Error configuring the daily digest: %v\n", err)
+		}
+		digest.run(sinkCtx, &sinks)
+		if *chainHaltAfter <= 0 {
+			log.Fatalf("Invalid --chain.halt-after %s, must be positive\n", *chainHaltAfter)
+		}
+		newHaltDetector(registry, health, *chainHaltAfter).run(sinkCtx, &sinks, *scrapeInterval)
+		if *otlpEndpoint != "" {
+			headers, err := parseKeyValueList(*otlpHeaders)
+			if err != nil {
+				log.Fatalf("Error parsing --otlp.headers: %v\n", err)
+			}
+			attributes, err := parseKeyValueList(*otlpAttributes)
+			if err != nil {
+				log.Fatalf("Error parsing --otlp.resource-attributes: %v\n", err)
+			}
+			otlp, err := newOTLPSink(*otlpEndpoint, headers, attributes)
+			if err != nil {
+				log.Fatalf("Error configuring OTLP export: %v\n", err)
+			}
+			runSink(sinkCtx, &sinks, "otlp", *otlpInterval, gatherer, otlp)
+		}
+		if *pushURL != "" {
+			var s sink
+			switch *pushMode {
+			case "remote_write":
+				s = &remoteWriteSink{url: *pushURL, httpClient: &http.Client{}}
+			case "pushgateway":
+				instance := *pushInstance
+				if instance == "" {
+					instance, _ = os.Hostname()
+				}
+				s = &pushgatewaySink{url: *pushURL, job: *pushJob, instance: instance}
+			default:
+				log.Fatalf("Invalid --push.mode %q, must be remote_write or pushgateway\n", *pushMode)
+			}
+			runSink(sinkCtx, &sinks, *pushMode, *pushInterval, gatherer, s)
+		}
+		if *influxURL != "" {
+			influx, err := newInfluxSink(*influxURL, *influxOrg, *influxBucket, *influxToken)
+			if err != nil {
+				log.Fatalf("Error configuring the InfluxDB sink: %v\n", err)
+			}
+			runSink(sinkCtx, &sinks, "influx", *influxInterval, gatherer, influx)
+		}
+
+		var rules []alertRule
+		if *alertSyncLag > 0 {
+			rules = append(rules, syncLagRule(*alertSyncLag))
+		}
+		if *alertUnreachableFor > 0 {
+			rules = append(rules, unreachableRule(*alertUnreachableFor))
+		}
+		if *alertMinBalance > 0 {
+			rules = append(rules, balanceRule(*alertMinBalance))
+		}
+		if *alertMissedBlocks > 0 {
+			rules = append(rules, missedBlocksRule(*alertMissedBlocks))
+		}
+		if *alertJailed {
+			rules = append(rules, jailedRule())
+		}
+		if *alertUpgradeBlocks > 0 {
+			rules = append(rules, upgradeRule(*alertUpgradeBlocks))
+		}
+		if *alertChainHalted {
+			rules = append(rules, chainHaltedRule())
+		}
+		if *alertIBCExpiry > 0 {
+			rules = append(rules, ibcExpiryRule(*alertIBCExpiry))
+		}
+		var notifiers []namedNotifier
+		for _, u := range splitList(*alertWebhooks) {
+			notifiers = append(notifiers, namedNotifier{name: "webhook", skipResolved: !*alertSendResolved, notifier: &webhookNotifier{url: u, httpClient: &http.Client{}}})
+		}
+		if *alertTelegramToken != "" {
+			if *alertTelegramChat == "" {
+				log.Fatalf("--alert.telegram.bot-token requires --alert.telegram.chat-id\n")
+			}
+			notifiers = append(notifiers, namedNotifier{name: "telegram", skipResolved: !*alertSendResolved, notifier: &telegramNotifier{botToken: *alertTelegramToken, chatID: *alertTelegramChat, httpClient: &http.Client{}}})
+		}
+		if *alertDiscordWebhook != "" {
+			notifiers = append(notifiers, namedNotifier{name: "discord", skipResolved: !*alertSendResolved, notifier: &discordNotifier{url: *alertDiscordWebhook, httpClient: &http.Client{}}})
+		}
+		if _, ok := severityRanks[*alertPageSeverity]; !ok {
+			log.Fatalf("Invalid --alert.page-severity %q, must be warning or critical\n", *alertPageSeverity)
+		}
+		if *alertPagerDutyKey != "" {
+			notifiers = append(notifiers, namedNotifier{name: "pagerduty", minSeverity: *alertPageSeverity, notifier: &pagerDutyNotifier{routingKey: *alertPagerDutyKey, httpClient: &http.Client{}}})
+		}
+		if *alertOpsgenieKey != "" {
+			apiURL := strings.TrimSuffix(*alertOpsgenieURL, "/")
+			notifiers = append(notifiers, namedNotifier{name: "opsgenie", minSeverity: *alertPageSeverity, notifier: &opsgenieNotifier{apiURL: apiURL, apiKey: *alertOpsgenieKey, httpClient: &http.Client{}}})
+		}
+		if *alertEmailServer != "" {
+			email, err := newEmailNotifier(*alertEmailServer, *alertEmailTLS, *alertEmailUsername, *alertEmailPassword, *alertEmailFrom, splitList(*alertEmailTo), *alertEmailSubject, *alertEmailBody, *alertEmailMinInterval)
+			if err != nil {
+				log.Fatalf("Error configuring the email notifier: %v\n", err)
+			}
+			notifiers = append(notifiers, namedNotifier{name: "email", skipResolved: !*alertSendResolved, notifier: email})
+		}
+		switch {
+		case len(rules) > 0 && len(notifiers) == 0:
+			log.Fatalf("Alert rules are configured but no notifier, set --alert.webhook-urls, --alert.telegram.bot-token, --alert.discord.webhook-url, --alert.pagerduty.routing-key, --alert.opsgenie.api-key or --alert.email.smtp-server\n")
+		case len(rules) == 0 && len(notifiers) > 0:
+			log.Fatalf("Alert notifiers are configured but no rule, set --alert.sync-lag, --alert.unreachable-for, --alert.min-balance, --alert.missed-blocks, --alert.validator-jailed, --alert.upgrade-blocks, --alert.chain-halted or --alert.ibc-client-expiry\n")
+		case len(rules) > 0:
+			cooldowns, err := parseDurationList(*alertCooldowns)
+			if err != nil {
+				log.Fatalf("Error parsing --alert.cooldowns: %v\n", err)
+			}
+			// The rules match the registered metric names.
+			engine, err := newAlertEngine(registry, health, rules, notifiers, cooldowns, *alertGroupByNode)
+			if err != nil {
+				log.Fatalf("Invalid --alert.cooldowns: %v\n", err)
+			}
+			if *alertStateFile != "" {
+				if err := engine.load(*alertStateFile); err != nil {
+					log.Fatalf("Error loading the alert state file: %v\n", err)
+				}
+			}
+			engine.run(sinkCtx, &sinks, *alertInterval)
+		}
+
+		var reloadMu sync.Mutex
+		reload := func(what string) {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+			targets, err := loadTargets()
+			if err != nil {
+				log.Printf("Error reloading %s, keeping previous targets: %v\n", what, err)
+				return
+			}
+			exp.apply(targets)
+			log.Printf("%s reloaded, monitoring %d node(s)\n", strings.ToUpper(what[:1])+what[1:], len(targets))
+		}
+		if disc.enabled() {
+			disc.onChange = func() { reload("discovered targets") }
+			disc.run(sinkCtx, &sinks)
+		}
+		go func() {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			for range hup {
+				reload("config")
+			}
+		}()
+
+		newServer := func(addr string, handler http.Handler) *http.Server {
+			server := &http.Server{Addr: addr, Handler: withHeaders(handler, webHeaders)}
+			server.RegisterOnShutdown(events.close)
+			if webTLS.enabled() {
+				if server.TLSConfig, err = webTLS.build(); err != nil {
+					log.Fatalf("Error configuring TLS: %v\n", err)
+				}
+			}
+			if !webHTTP2 {
+				// A non-nil, empty map disables HTTP/2 over TLS.
+				server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+			}
+			return server
+		}
+		listenNetwork, ok := listenNetworks[*listenFamily]
+		if !ok {
+			log.Fatalf("Invalid --listen.family %q, must be any, ipv4 or ipv6\n", *listenFamily)
+		}
+		addresses := splitList(*listenAddress)
+		for _, address := range addresses {
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				log.Fatalf("Invalid --listen.address %q: %v\n", address, err)
+			}
+		}
+		if len(addresses) > 0 && *listenSocket != "" {
+			log.Fatalf("--listen.address can't be combined with --listen.socket\n")
+		}
+		if len(addresses) == 0 {
+			addresses = []string{":" + *listenPort}
+		}
+		server := newServer(addresses[0], mux)
+		var adminServer *http.Server
+		if *adminListenAddress != "" {
+			adminServer = newServer(*adminListenAddress, adminMux)
+			adminListener, err := net.Listen(listenNetwork, adminServer.Addr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			go func() {
+				log.Printf("Serving the status page and the API on %s\n", *adminListenAddress)
+				if err := serveWeb(adminServer, adminListener, webTLS.enabled()); err != http.ErrServerClosed {
+					log.Fatalf("Error serving the status page and the API: %v\n", err)
+				}
+			}()
+		}
+		stopped := make(chan struct{})
+		go func() {
+			term := make(chan os.Signal, 1)
+			signal.Notify(term, syscall.SIGINT, syscall.SIGTERM)
+			sig := <-term
+			log.Printf("Received %s, shutting down\n", sig)
+			sdNotify("STOPPING=1")
+
+			ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Printf("Error shutting down HTTP server: %v\n", err)
+			}
+			if adminServer != nil {
+				if err := adminServer.Shutdown(ctx); err != nil {
+					log.Printf("Error shutting down admin HTTP server: %v\n", err)
+				}
+			}
+			if debugServer != nil {
+				if err := debugServer.Shutdown(ctx); err != nil {
+					log.Printf("Error shutting down debug HTTP server: %v\n", err)
+				}
+			}
+			stopSinks()
+			sinks.Wait()
+			exp.shutdown(ctx)
+			if err := exporterState.save(); err != nil {
+				log.Printf("Error saving state file: %v\n", err)
+			}
+			close(stopped)
+		}()
+
+		var listeners []net.Listener
+		if *listenSocket != "" {
+			mode, err := strconv.ParseUint(*listenSocketMode, 8, 32)
+			if err != nil || mode > 0o777 {
+				log.Fatalf("Invalid --listen.socket-mode %s, must be an octal file mode like 0660\n", *listenSocketMode)
+			}
+			listener, err := listenUnix(*listenSocket, os.FileMode(mode))
+			if err != nil {
+				log.Fatal(err)
+			}
+			listeners = append(listeners, listener)
+			log.Printf("Celestia Bridge Exporter started on socket %s, monitoring %d node(s)\n", *listenSocket, len(targets))
+		} else {
+			for _, address := range addresses {
+				listener, err := net.Listen(listenNetwork, address)
+				if err != nil {
+					log.Fatal(err)
+				}
+				listeners = append(listeners, listener)
+			}
+			if *listenAddress == "" {
+				log.Printf("Celestia Bridge Exporter started on port %s, monitoring %d node(s)\n", *listenPort, len(targets))
+			} else {
+				log.Printf("Celestia Bridge Exporter started on %s, monitoring %d node(s)\n", strings.Join(addresses, ", "), len(targets))
+			}
+		}
+		if err := sdNotify(fmt.Sprintf("READY=1\nSTATUS=Monitoring %d node(s)", len(targets))); err != nil {
+			log.Printf("Error notifying systemd: %v\n", err)
+		}
+		runWatchdog(sinkCtx, &sinks, health)
+		// Shutdown closes all listeners of the server.
+		for _, listener := range listeners[1:] {
+			go func(listener net.Listener) {
+				if err := serveWeb(server, listener, webTLS.enabled()); err != http.ErrServerClosed {
+					log.Fatal(err)
+				}
+			}(listener)
+		}
+		if err := serveWeb(server, listeners[0], webTLS.enabled()); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+		<-stopped
+		log.Printf("Celestia Bridge Exporter stopped\n")
 	}
+	return cmd
+}
 
-	heightStr, ok := header["height"].(string)
-	if !ok {
-		log.Println("Error: height is not a string")
-		return 0
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	height, err := strconv.Atoi(heightStr)
-	if err != nil {
-		log.Printf("Error converting height to int: %v\n", err)
-		return 0
+// parseKeyValueList parses a comma-separated list of key=value pairs.
+func parseKeyValueList(list string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, item := range splitList(list) {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected key=value", item)
+		}
+		values[strings.TrimSpace(k)] = strings.TrimSpace(v)
 	}
-	return height
+	return values, nil
 }
 
-func getAuthToken(p2pNetwork, nodeStorePath string) string {
-	cmd := exec.Command("celestia", "bridge", "auth", "admin", "--p2p.network", p2pNetwork, "--node.store", nodeStorePath)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("Error getting auth token: %v, output: %s\n", err, string(out))
-		return ""
+// parseDurationList parses a comma-separated list of name=duration pairs.
+func parseDurationList(list string) (map[string]time.Duration, error) {
+	items := splitList(list)
+	if len(items) == 0 {
+		return nil, nil
 	}
+	durations := make(map[string]time.Duration)
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q, expected name=duration", item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", name, err)
+		}
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations, nil
+}
 
-	return strings.TrimSpace(string(out))
+func withDefaultDuration(m map[string]time.Duration, name string, d time.Duration) map[string]time.Duration {
+	if m == nil {
+		m = make(map[string]time.Duration)
+	}
+	if _, ok := m[name]; !ok {
+		m[name] = d
+	}
+	return m
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// Check states, ordered by severity. The values are the Nagios plugin exit
+// codes.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+)
+
+var checkStateNames = []string{"OK", "WARNING", "CRITICAL"}
+
+type checkResult struct {
+	Name     string   `json:"name"`
+	State    string   `json:"state"`
+	Message  string   `json:"message"`
+	Value    *float64 `json:"value,omitempty"`
+	Warning  *float64 `json:"warning,omitempty"`
+	Critical *float64 `json:"critical,omitempty"`
+
+	state int
+}
+
+type checkThresholds struct {
+	syncLagWarning, syncLagCritical int
+	peersWarning, peersCritical     int
+	balanceWarning, balanceCritical float64
+}
+
+// above rates value against thresholds that are exceeded from above, e.g.
+// the sync lag.
+func above(value, warning, critical float64) int {
+	switch {
+	case value > critical:
+		return checkCritical
+	case value > warning:
+		return checkWarning
+	}
+	return checkOK
+}
+
+// below rates value against thresholds that are exceeded from below, e.g.
+// the number of peers. A threshold of 0 is disabled.
+func below(value, warning, critical float64) int {
+	switch {
+	case critical > 0 && value < critical:
+		return checkCritical
+	case warning > 0 && value < warning:
+		return checkWarning
+	}
+	return checkOK
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+// runCheck evaluates the sync lag, peer count and balance of the node once.
+// Failing rpc calls count as critical, since the node is not healthy if it
+// can't answer them.
+func runCheck(ctx context.Context, client *celestiarpc.Client, t target, th checkThresholds) []checkResult {
+	var results []checkResult
+	failed := func(name string, err error) {
+		results = append(results, checkResult{Name: name, state: checkCritical, Message: err.Error()})
+	}
+
+	if t.NodeType == nodeTypeAuto {
+		t.NodeType = detectNodeType(ctx, client, t)
+	}
+
+	var lag float64
+	var lagErr error
+	if t.collectsHeaders() {
+		local, network, err := client.Heads(ctx)
+		if err == nil {
+			lag = float64(int64(network.Height()) - int64(local.Height()))
+		}
+		lagErr = err
+	} else {
+		stats, err := client.SamplingStats(ctx)
+		if err == nil {
+			lag = float64(int64(stats.NetworkHead) - int64(stats.SampledChainHead))
+		}
+		lagErr = err
+	}
+	if lagErr != nil {
+		failed("sync_lag", lagErr)
+	} else {
+		if lag < 0 {
+			lag = 0
+		}
+		warning, critical := float64(th.syncLagWarning), float64(th.syncLagCritical)
+		results = append(results, checkResult{
+			Name: "sync_lag", state: above(lag, warning, critical),
+			Message: fmt.Sprintf("%.0f blocks behind the network head", lag),
+			Value:   floatPtr(lag), Warning: floatPtr(warning), Critical: floatPtr(critical),
+		})
+	}
+
+	if peers, err := client.Peers(ctx); err != nil {
+		failed("peers", err)
+	} else {
+		n := float64(len(peers))
+		warning, critical := float64(th.peersWarning), float64(th.peersCritical)
+		results = append(results, checkResult{
+			Name: "peers", state: below(n, warning, critical),
+			Message: fmt.Sprintf("%d peers", len(peers)),
+			Value:   floatPtr(n), Warning: floatPtr(warning), Critical: floatPtr(critical),
+		})
+	}
+
+	if th.balanceWarning > 0 || th.balanceCritical > 0 {
+		if balance, err := client.Balance(ctx); err != nil {
+			failed("balance", err)
+		} else if amount, err := strconv.ParseFloat(balance.Amount, 64); err != nil {
+			failed("balance", fmt.Errorf("parsing balance: %w", err))
+		} else {
+			results = append(results, checkResult{
+				Name: "balance", state: below(amount, th.balanceWarning, th.balanceCritical),
+				Message: fmt.Sprintf("%.0f utia", amount),
+				Value:   floatPtr(amount), Warning: floatPtr(th.balanceWarning), Critical: floatPtr(th.balanceCritical),
+			})
+		}
+	}
+
+	for i := range results {
+		results[i].State = checkStateNames[results[i].state]
+	}
+	return results
+}
+
+func worstState(results []checkResult) int {
+	state := checkOK
+	for _, r := range results {
+		if r.state > state {
+			state = r.state
+		}
+	}
+	return state
+}
+
+// writeCheckText writes the results in the Nagios plugin format: a status
+// line followed by performance data.
+func writeCheckText(w io.Writer, results []checkResult) {
+	var msgs, perf []string
+	for _, r := range results {
+		msgs = append(msgs, fmt.Sprintf("%s %s: %s", r.Name, strings.ToLower(r.State), r.Message))
+		if r.Value != nil {
+			perf = append(perf, fmt.Sprintf("%s=%s;%s;%s", r.Name, formatFloat(*r.Value), formatFloat(*r.Warning), formatFloat(*r.Critical)))
+		}
+	}
+	fmt.Fprintf(w, "CELESTIA %s - %s", checkStateNames[worstState(results)], strings.Join(msgs, ", "))
+	if len(perf) > 0 {
+		fmt.Fprintf(w, " | %s", strings.Join(perf, " "))
+	}
+	fmt.Fprintln(w)
+}
+
+func newCheckCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check the node once and exit with 0 (ok), 1 (warning) or 2 (critical)",
+		Long: "Checks the sync lag, the number of peers and optionally the wallet balance of a node once, " +
+			"prints the result and exits with 0 if all checks are ok, 1 on warnings and 2 on critical " +
+			"results, like a Nagios/Icinga plugin.",
+		Args: cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	endpoint := fs.String("endpoint", "http://localhost:26658", "endpoint of the node to check")
+	authToken := fs.String("auth.token", "", "auth token for the node RPC, overrides the "+authTokenEnv+" environment variable")
+	authTokenFile := fs.String("auth.token-file", "", "file to read the auth token from")
+	nodeType := fs.String("node.type", nodeTypeAuto, "type of the node: bridge, full, light, or auto to detect it via node.Info")
+	p2pNetwork := fs.String("p2p.network", "blockspacerace", "network of the node, used to generate an auth token with the celestia binary")
+	nodeStorePath := fs.String("node.store", "/default/path", "node store path, used to generate an auth token with the celestia binary")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of the whole check")
+	format := fs.String("format", "text", "output format: text or json")
+	th := checkThresholds{}
+	fs.IntVar(&th.syncLagWarning, "sync.lag-warning", 5, "blocks behind the network head above which the check warns")
+	fs.IntVar(&th.syncLagCritical, "sync.lag-critical", 50, "blocks behind the network head above which the check is critical")
+	fs.IntVar(&th.peersWarning, "peers.warning", 5, "number of peers below which the check warns, 0 disables")
+	fs.IntVar(&th.peersCritical, "peers.critical", 1, "number of peers below which the check is critical, 0 disables")
+	fs.Float64Var(&th.balanceWarning, "balance.warning", 0, "wallet balance in utia below which the check warns, 0 disables")
+	fs.Float64Var(&th.balanceCritical, "balance.critical", 0, "wallet balance in utia below which the check is critical, 0 disables")
+
+	cmd.Run = func(cmd *cobra.Command, args []string) {
+		if *format != "text" && *format != "json" {
+			fmt.Fprintf(os.Stderr, "Invalid --format %q, must be text or json\n", *format)
+			os.Exit(checkCritical)
+		}
+		u, err := url.Parse(*endpoint)
+		if err != nil || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "Invalid --endpoint %q\n", *endpoint)
+			os.Exit(checkCritical)
+		}
+		t := target{
+			Name:          u.Host,
+			Endpoint:      *endpoint,
+			AuthToken:     *authToken,
+			AuthTokenFile: *authTokenFile,
+			NodeType:      *nodeType,
+			P2PNetwork:    *p2pNetwork,
+			NodeStore:     *nodeStorePath,
+		}
+		e := newExporter(&http.Client{}, newHealthTracker(1, true), true, celestiarpc.RetryPolicy{MaxAttempts: 1}, breakerConfig{}, limitConfig{}, 0)
+		client := celestiarpc.New(t.Endpoint, "", celestiarpc.WithTokenSource(e.tokenSource(t)))
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		results := runCheck(ctx, client, t, th)
+		cancel()
+
+		state := worstState(results)
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(struct {
+				State  string        `json:"state"`
+				Checks []checkResult `json:"checks"`
+			}{checkStateNames[state], results})
+		} else {
+			writeCheckText(os.Stdout, results)
+		}
+		os.Exit(state)
+	}
+	return cmd
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var collectorSkipped = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "exporter_collector_skipped",
+	Help: "Collectors not run because the auth scope lacks the permission they need (1), by collector and permission",
+}, append(targetLabels, "collector", "permission"))
+
+func init() {
+	addTargetMetrics(collectorSkipped)
+}
+
+// Collector collects one group of metrics from a single node.
+type Collector interface {
+	// Name identifies the collector in --collectors.enable and in the
+	// health status.
+	Name() string
+	Collect(ctx context.Context) error
+}
+
+// collectorFactory creates the collector for a target whose node type has
+// been resolved. It returns nil if the collector doesn't apply to the
+// target.
+type collectorFactory func(client *celestiarpc.Client, t target) Collector
+
+var collectorFactories = make(map[string]collectorFactory)
+
+// registerCollector makes a collector available under name. Collectors
+// register themselves from init, so adding one doesn't require changes
+// elsewhere.
+func registerCollector(name string, factory collectorFactory) {
+	if _, ok := collectorFactories[name]; ok {
+		panic("collector registered twice: " + name)
+	}
+	collectorFactories[name] = factory
+}
+
+// Auth scopes of --auth.scope: with a read token only the collectors that
+// don't need admin or write permission run.
+const (
+	authScopeAdmin = "admin"
+	authScopeRead  = "read"
+)
+
+// collectorPermissions are the permissions beyond read that collectors
+// need, keyed by collector name.
+var collectorPermissions = make(map[string]string)
+
+// requirePermission records that the collector registered as name calls
+// methods that need permission, admin or write.
+func requirePermission(name, permission string) {
+	collectorPermissions[name] = permission
+}
+
+func collectorNames() []string {
+	names := make([]string, 0, len(collectorFactories))
+	for name := range collectorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveCollectors returns the names of the collectors to run given the
+// enable and disable lists. An empty enable list enables all collectors.
+func resolveCollectors(enable, disable []string) ([]string, error) {
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if _, ok := collectorFactories[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q, available collectors: %v", name, collectorNames())
+		}
+	}
+
+	if len(enable) == 0 {
+		enable = collectorNames()
+	}
+	disabled := make(map[string]bool)
+	for _, name := range disable {
+		disabled[name] = true
+	}
+	var names []string
+	for _, name := range enable {
+		if !disabled[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// buildCollectors creates the enabled collectors that apply to t, skipping
+// the ones its auth scope doesn't permit.
+func buildCollectors(client *celestiarpc.Client, t target) []Collector {
+	var collectors []Collector
+	for _, name := range t.Collectors {
+		c := collectorFactories[name](client, t)
+		if c == nil {
+			continue
+		}
+		if permission := collectorPermissions[name]; permission != "" && t.AuthScope == authScopeRead {
+			log.Printf("Skipping %s collector of %s, it needs %s permission\n", name, t.Name, permission)
+			collectorSkipped.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, name, permission).Set(1)
+			continue
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors
+}
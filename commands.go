@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is prepended to flag names to get the environment variables
+// that set them, e.g. CELESTIA_EXPORTER_LISTEN_PORT for --listen.port.
+const envPrefix = "CELESTIA_EXPORTER_"
+
+func newRootCommand() *cobra.Command {
+	root := newExportCommand("celbridge_export")
+	root.Long = "Exports metrics of Celestia bridge, full and light nodes to Prometheus.\n\n" +
+		"Without a subcommand the exporter is started, same as with the export subcommand. " +
+		"Every flag can also be set via an environment variable named " + envPrefix + " followed by " +
+		"the flag name in upper case with dots and dashes replaced by underscores."
+	root.SilenceUsage = true
+	root.Version = version
+	root.SetVersionTemplate(versionString() + "\n")
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return bindEnv(cmd.Flags())
+	}
+	root.AddCommand(
+		newExportCommand("export"),
+		newCheckCommand(),
+		newDashboardCommand(),
+		newRulesCommand(),
+		newSilenceCommand(),
+		newRecordCommand(),
+		newVersionCommand(),
+	)
+	root.SetArgs(normalizeArgs(os.Args[1:]))
+	return root
+}
+
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the version of the exporter",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintln(cmd.OutOrStdout(), versionString())
+		},
+	}
+}
+
+// bindEnv sets the flags that weren't given on the command line from their
+// environment variables.
+func bindEnv(fs *pflag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(f.Name))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q of %s: %w", value, name, setErr)
+		}
+	})
+	return err
+}
+
+// normalizeArgs turns single-dash long flags like -listen.port, which the
+// exporter accepted before it used cobra, into their double-dash form.
+// There are no shorthand flags, so this is unambiguous. Negative numbers
+// are flag values and are left alone.
+func normalizeArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		if a == "--" {
+			copy(out[i:], args[i:])
+			break
+		}
+		if len(a) > 2 && a[0] == '-' && a[1] != '-' && (a[1] < '0' || a[1] > '9') {
+			a = "-" + a
+		}
+		out[i] = a
+	}
+	return out
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+// config is the structure of the file passed via --config.
+type config struct {
+	AuthToken          string                   `yaml:"auth_token"`
+	AuthTokenFile      string                   `yaml:"auth_token_file"`
+	AuthTokenEnv       string                   `yaml:"auth_token_env"`
+	AuthTokenCommand   string                   `yaml:"auth_token_command"`
+	AuthTokenTTL       time.Duration            `yaml:"auth_token_ttl"`
+	AuthScope          string                   `yaml:"auth_scope"`
+	ScrapeInterval     time.Duration            `yaml:"scrape_interval"`
+	ScrapeTimeout      time.Duration            `yaml:"scrape_timeout"`
+	CollectorIntervals map[string]time.Duration `yaml:"collector_intervals"`
+	CollectorTimeouts  map[string]time.Duration `yaml:"collector_timeouts"`
+	AdaptiveInterval   bool                     `yaml:"adaptive_interval"`
+	AdaptiveMin        time.Duration            `yaml:"adaptive_interval_min"`
+	AdaptiveMax        time.Duration            `yaml:"adaptive_interval_max"`
+	P2PNetwork         string                   `yaml:"p2p_network"`
+	NodeStore          string                   `yaml:"node_store"`
+	DataDir            string                   `yaml:"data_dir"`
+	PIDFile            string                   `yaml:"pid_file"`
+	ProcessName        string                   `yaml:"process_name"`
+	SyncLagThreshold   int                      `yaml:"sync_lag_threshold"`
+	HeaderVerifyDepth  int                      `yaml:"header_verify_depth"`
+	MissedBlocksWindow int                      `yaml:"missed_blocks_window"`
+	Subscribe          bool                     `yaml:"subscribe"`
+	NodeType           string                   `yaml:"node_type"`
+	P2PProtocols       []string                 `yaml:"p2p_protocols"`
+	BalanceAddresses   []string                 `yaml:"balance_addresses"`
+	CanaryNamespace    string                   `yaml:"canary_namespace"`
+	AuditSamples       int                      `yaml:"audit_samples"`
+	AuditWindow        int                      `yaml:"audit_window"`
+	AuditNamespaces    []string                 `yaml:"audit_namespaces"`
+	CollectorsEnable   []string                 `yaml:"collectors_enable"`
+	CollectorsDisable  []string                 `yaml:"collectors_disable"`
+	TLS                *targetTLS               `yaml:"tls"`
+	ProxyURL           string                   `yaml:"proxy_url"`
+	Fallbacks          []string                 `yaml:"fallback_endpoints"`
+	ConsensusEndpoint  string                   `yaml:"consensus_endpoint"`
+	AppGRPC            string                   `yaml:"app_grpc"`
+	StakingValidators  []string                 `yaml:"staking_validators"`
+	IBCChannels        []string                 `yaml:"ibc_channels"`
+	ProbePeers         []string                 `yaml:"probe_peers"`
+	ProbeMethods       []string                 `yaml:"probe_methods"`
+	SnapshotLocation   string                   `yaml:"snapshot_location"`
+	ReferenceEndpoint  string                   `yaml:"reference_endpoint"`
+	ValidatorAddress   string                   `yaml:"validator_address"`
+	SlackWebhookURL    string                   `yaml:"slack_webhook_url"`
+	Targets            []targetConfig           `yaml:"targets"`
+}
+
+type targetConfig struct {
+	Name               string                   `yaml:"name"`
+	Endpoint           string                   `yaml:"endpoint"`
+	AuthToken          string                   `yaml:"auth_token"`
+	AuthTokenFile      string                   `yaml:"auth_token_file"`
+	AuthTokenEnv       string                   `yaml:"auth_token_env"`
+	AuthTokenCommand   string                   `yaml:"auth_token_command"`
+	AuthTokenTTL       time.Duration            `yaml:"auth_token_ttl"`
+	AuthScope          string                   `yaml:"auth_scope"`
+	P2PNetwork         string                   `yaml:"p2p_network"`
+	NodeStore          string                   `yaml:"node_store"`
+	DataDir            string                   `yaml:"data_dir"`
+	PIDFile            string                   `yaml:"pid_file"`
+	ProcessName        string                   `yaml:"process_name"`
+	ScrapeInterval     time.Duration            `yaml:"scrape_interval"`
+	ScrapeTimeout      time.Duration            `yaml:"scrape_timeout"`
+	CollectorIntervals map[string]time.Duration `yaml:"collector_intervals"`
+	CollectorTimeouts  map[string]time.Duration `yaml:"collector_timeouts"`
+	AdaptiveInterval   *bool                    `yaml:"adaptive_interval"`
+	AdaptiveMin        time.Duration            `yaml:"adaptive_interval_min"`
+	AdaptiveMax        time.Duration            `yaml:"adaptive_interval_max"`
+	SyncLagThreshold   *int                     `yaml:"sync_lag_threshold"`
+	HeaderVerifyDepth  *int                     `yaml:"header_verify_depth"`
+	MissedBlocksWindow *int                     `yaml:"missed_blocks_window"`
+	Subscribe          *bool                    `yaml:"subscribe"`
+	NodeType           string                   `yaml:"node_type"`
+	P2PProtocols       []string                 `yaml:"p2p_protocols"`
+	BalanceAddresses   []string                 `yaml:"balance_addresses"`
+	CanaryNamespace    string                   `yaml:"canary_namespace"`
+	AuditSamples       *int                     `yaml:"audit_samples"`
+	AuditWindow        *int                     `yaml:"audit_window"`
+	AuditNamespaces    []string                 `yaml:"audit_namespaces"`
+	CollectorsEnable   []string                 `yaml:"collectors_enable"`
+	CollectorsDisable  []string                 `yaml:"collectors_disable"`
+	TLS                *targetTLS               `yaml:"tls"`
+	ProxyURL           string                   `yaml:"proxy_url"`
+	Fallbacks          []string                 `yaml:"fallback_endpoints"`
+	ConsensusEndpoint  string                   `yaml:"consensus_endpoint"`
+	AppGRPC            string                   `yaml:"app_grpc"`
+	StakingValidators  []string                 `yaml:"staking_validators"`
+	IBCChannels        []string                 `yaml:"ibc_channels"`
+	ProbePeers         []string                 `yaml:"probe_peers"`
+	ProbeMethods       []string                 `yaml:"probe_methods"`
+	SnapshotLocation   string                   `yaml:"snapshot_location"`
+	ReferenceEndpoint  string                   `yaml:"reference_endpoint"`
+	ValidatorAddress   string                   `yaml:"validator_address"`
+	SlackWebhookURL    string                   `yaml:"slack_webhook_url"`
+}
+
+// loadConfig reads the config file at path. Fields left empty in the file
+// fall back to the values in defaults. Without discovery the file must
+// list at least one target.
+func loadConfig(path string, defaults config, discovery bool) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := defaults
+	cfg.Targets = nil
+	// The decoder writes into existing maps, keep it away from the defaults.
+	cfg.CollectorIntervals = copyDurations(defaults.CollectorIntervals)
+	cfg.CollectorTimeouts = copyDurations(defaults.CollectorTimeouts)
+	if defaults.TLS != nil {
+		tlsDefaults := *defaults.TLS
+		cfg.TLS = &tlsDefaults
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(cfg.Targets) == 0 && !discovery {
+		return nil, fmt.Errorf("%s: no targets configured", path)
+	}
+	return &cfg, nil
+}
+
+// buildTargets resolves the targets of cfg, applying the global defaults.
+func (cfg *config) buildTargets() ([]target, error) {
+	var entries []string
+	for _, tc := range cfg.Targets {
+		if strings.TrimSpace(tc.Endpoint) == "" {
+			return nil, fmt.Errorf("target %q has no endpoint", tc.Name)
+		}
+		if tc.Name != "" {
+			entries = append(entries, tc.Name+"="+tc.Endpoint)
+		} else {
+			entries = append(entries, tc.Endpoint)
+		}
+	}
+
+	targets, err := parseTargetList(entries)
+	if err != nil {
+		return nil, err
+	}
+	for i, tc := range cfg.Targets {
+		t := &targets[i]
+		// A target with a token source of its own ignores the default
+		// ones, which might take precedence over it.
+		t.AuthToken, t.AuthTokenFile, t.AuthTokenEnv, t.AuthTokenCommand = tc.AuthToken, tc.AuthTokenFile, tc.AuthTokenEnv, tc.AuthTokenCommand
+		if t.AuthToken == "" && t.AuthTokenFile == "" && t.AuthTokenEnv == "" && t.AuthTokenCommand == "" {
+			t.AuthToken, t.AuthTokenFile, t.AuthTokenEnv, t.AuthTokenCommand = cfg.AuthToken, cfg.AuthTokenFile, cfg.AuthTokenEnv, cfg.AuthTokenCommand
+		}
+		t.AuthTokenTTL = tc.AuthTokenTTL
+		if t.AuthTokenTTL == 0 {
+			t.AuthTokenTTL = cfg.AuthTokenTTL
+		}
+		t.AuthScope = firstNonEmpty(tc.AuthScope, cfg.AuthScope, authScopeAdmin)
+		if t.AuthScope != authScopeAdmin && t.AuthScope != authScopeRead {
+			return nil, fmt.Errorf("target %q: invalid auth scope %q, must be admin or read", t.Name, t.AuthScope)
+		}
+		t.P2PNetwork = firstNonEmpty(tc.P2PNetwork, cfg.P2PNetwork)
+		t.NodeStore = firstNonEmpty(tc.NodeStore, cfg.NodeStore)
+		t.DataDir = firstNonEmpty(tc.DataDir, cfg.DataDir)
+		t.PIDFile = firstNonEmpty(tc.PIDFile, cfg.PIDFile)
+		t.ProcessName = firstNonEmpty(tc.ProcessName, cfg.ProcessName)
+		t.ScrapeInterval = cfg.ScrapeInterval
+		if tc.ScrapeInterval > 0 {
+			t.ScrapeInterval = tc.ScrapeInterval
+		}
+		t.ScrapeTimeout = cfg.ScrapeTimeout
+		if tc.ScrapeTimeout > 0 {
+			t.ScrapeTimeout = tc.ScrapeTimeout
+		}
+		if t.ScrapeInterval <= 0 || t.ScrapeTimeout <= 0 {
+			return nil, fmt.Errorf("target %q: scrape interval and timeout must be positive", t.Name)
+		}
+		if t.CollectorIntervals, err = mergeDurations(cfg.CollectorIntervals, tc.CollectorIntervals); err != nil {
+			return nil, fmt.Errorf("target %q: collector intervals: %w", t.Name, err)
+		}
+		if t.CollectorTimeouts, err = mergeDurations(cfg.CollectorTimeouts, tc.CollectorTimeouts); err != nil {
+			return nil, fmt.Errorf("target %q: collector timeouts: %w", t.Name, err)
+		}
+		t.AdaptiveInterval = cfg.AdaptiveInterval
+		if tc.AdaptiveInterval != nil {
+			t.AdaptiveInterval = *tc.AdaptiveInterval
+		}
+		t.AdaptiveMin = cfg.AdaptiveMin
+		if tc.AdaptiveMin > 0 {
+			t.AdaptiveMin = tc.AdaptiveMin
+		}
+		t.AdaptiveMax = cfg.AdaptiveMax
+		if tc.AdaptiveMax > 0 {
+			t.AdaptiveMax = tc.AdaptiveMax
+		}
+		if t.AdaptiveInterval && (t.AdaptiveMin <= 0 || t.AdaptiveMax < t.AdaptiveMin) {
+			return nil, fmt.Errorf("target %q: adaptive interval bounds must be positive, with the minimum not above the maximum", t.Name)
+		}
+		t.SyncLagThreshold = cfg.SyncLagThreshold
+		if tc.SyncLagThreshold != nil {
+			t.SyncLagThreshold = *tc.SyncLagThreshold
+		}
+		t.HeaderVerifyDepth = cfg.HeaderVerifyDepth
+		if tc.HeaderVerifyDepth != nil {
+			t.HeaderVerifyDepth = *tc.HeaderVerifyDepth
+		}
+		t.MissedBlocksWindow = cfg.MissedBlocksWindow
+		if tc.MissedBlocksWindow != nil {
+			t.MissedBlocksWindow = *tc.MissedBlocksWindow
+		}
+		if t.MissedBlocksWindow < 0 {
+			return nil, fmt.Errorf("target %q: missed blocks window must not be negative", t.Name)
+		}
+		t.Subscribe = cfg.Subscribe
+		if tc.Subscribe != nil {
+			t.Subscribe = *tc.Subscribe
+		}
+		t.NodeType = firstNonEmpty(tc.NodeType, cfg.NodeType, nodeTypeAuto)
+		if !validNodeType(t.NodeType) {
+			return nil, fmt.Errorf("target %q: invalid node type %q", t.Name, t.NodeType)
+		}
+		if _, err := tokenCommandArgs(*t); err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+		if t.AuthTokenTTL < 0 {
+			return nil, fmt.Errorf("target %q: auth token TTL must not be negative", t.Name)
+		}
+		t.P2PProtocols = cfg.P2PProtocols
+		if tc.P2PProtocols != nil {
+			t.P2PProtocols = tc.P2PProtocols
+		}
+		t.BalanceAddresses = cfg.BalanceAddresses
+		if tc.BalanceAddresses != nil {
+			t.BalanceAddresses = tc.BalanceAddresses
+		}
+		t.CanaryNamespace = firstNonEmpty(tc.CanaryNamespace, cfg.CanaryNamespace)
+		if t.CanaryNamespace != "" {
+			if _, err := parseNamespace(t.CanaryNamespace); err != nil {
+				return nil, fmt.Errorf("target %q: canary: %w", t.Name, err)
+			}
+		}
+		t.AuditSamples = cfg.AuditSamples
+		if tc.AuditSamples != nil {
+			t.AuditSamples = *tc.AuditSamples
+		}
+		t.AuditWindow = cfg.AuditWindow
+		if tc.AuditWindow != nil {
+			t.AuditWindow = *tc.AuditWindow
+		}
+		if t.AuditSamples < 0 || t.AuditWindow < 0 {
+			return nil, fmt.Errorf("target %q: audit samples and window must not be negative", t.Name)
+		}
+		t.AuditNamespaces = cfg.AuditNamespaces
+		if tc.AuditNamespaces != nil {
+			t.AuditNamespaces = tc.AuditNamespaces
+		}
+		for _, ns := range t.AuditNamespaces {
+			if _, err := parseNamespace(ns); err != nil {
+				return nil, fmt.Errorf("target %q: audit: %w", t.Name, err)
+			}
+		}
+		enable, disable := cfg.CollectorsEnable, cfg.CollectorsDisable
+		if tc.CollectorsEnable != nil {
+			enable = tc.CollectorsEnable
+		}
+		if tc.CollectorsDisable != nil {
+			disable = tc.CollectorsDisable
+		}
+		if t.Collectors, err = resolveCollectors(enable, disable); err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+		if tc.TLS != nil {
+			t.TLS = *tc.TLS
+		} else if cfg.TLS != nil {
+			t.TLS = *cfg.TLS
+		}
+		if _, err := t.TLS.clientConfig(); err != nil {
+			return nil, fmt.Errorf("target %q: tls: %w", t.Name, err)
+		}
+		t.Fallbacks = cfg.Fallbacks
+		if tc.Fallbacks != nil {
+			t.Fallbacks = tc.Fallbacks
+		}
+		for _, endpoint := range t.Fallbacks {
+			if err := validateFallback(endpoint); err != nil {
+				return nil, fmt.Errorf("target %q: %w", t.Name, err)
+			}
+		}
+		t.ProxyURL = firstNonEmpty(tc.ProxyURL, cfg.ProxyURL)
+		if err := validateProxyURL(t.ProxyURL); err != nil {
+			return nil, fmt.Errorf("target %q: %w", t.Name, err)
+		}
+		t.ConsensusEndpoint = firstNonEmpty(tc.ConsensusEndpoint, cfg.ConsensusEndpoint)
+		t.ValidatorAddress = firstNonEmpty(tc.ValidatorAddress, cfg.ValidatorAddress)
+		t.StakingValidators = cfg.StakingValidators
+		if tc.StakingValidators != nil {
+			t.StakingValidators = tc.StakingValidators
+		}
+		t.IBCChannels = cfg.IBCChannels
+		if tc.IBCChannels != nil {
+			t.IBCChannels = tc.IBCChannels
+		}
+		for _, ch := range t.IBCChannels {
+			if _, _, err := parseIBCChannel(ch); err != nil {
+				return nil, fmt.Errorf("target %q: %w", t.Name, err)
+			}
+		}
+		t.ProbePeers = cfg.ProbePeers
+		if tc.ProbePeers != nil {
+			t.ProbePeers = tc.ProbePeers
+		}
+		for _, peer := range t.ProbePeers {
+			if _, err := probeAddress(peer); err != nil {
+				return nil, fmt.Errorf("target %q: probe: %w", t.Name, err)
+			}
+		}
+		t.ProbeMethods = cfg.ProbeMethods
+		if tc.ProbeMethods != nil {
+			t.ProbeMethods = tc.ProbeMethods
+		}
+		for _, method := range t.ProbeMethods {
+			if err := validateProbeMethod(method); err != nil {
+				return nil, fmt.Errorf("target %q: %w", t.Name, err)
+			}
+		}
+		t.ReferenceEndpoint = firstNonEmpty(tc.ReferenceEndpoint, cfg.ReferenceEndpoint)
+		t.SlackWebhookURL = firstNonEmpty(tc.SlackWebhookURL, cfg.SlackWebhookURL)
+		t.SnapshotLocation = firstNonEmpty(tc.SnapshotLocation, cfg.SnapshotLocation)
+		if t.SnapshotLocation != "" {
+			if err := validateSnapshotLocation(t.SnapshotLocation); err != nil {
+				return nil, fmt.Errorf("target %q: %w", t.Name, err)
+			}
+		}
+		t.AppGRPC = firstNonEmpty(tc.AppGRPC, cfg.AppGRPC)
+		if t.AppGRPC != "" {
+			if _, _, err := cometrpc.ParseGRPCAddress(t.AppGRPC); err != nil {
+				return nil, fmt.Errorf("target %q: %w", t.Name, err)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// mergeDurations combines per-collector durations, with those in override
+// taking precedence over the ones in base.
+func mergeDurations(base, override map[string]time.Duration) (map[string]time.Duration, error) {
+	if len(base) == 0 && len(override) == 0 {
+		return nil, nil
+	}
+	merged := make(map[string]time.Duration)
+	for _, m := range []map[string]time.Duration{base, override} {
+		for name, d := range m {
+			if _, ok := collectorFactories[name]; !ok {
+				return nil, fmt.Errorf("unknown collector %q", name)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("%s: duration must be positive", name)
+			}
+			merged[name] = d
+		}
+	}
+	return merged, nil
+}
+
+func copyDurations(m map[string]time.Duration) map[string]time.Duration {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]time.Duration, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
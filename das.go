@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// dasMetrics are the DAS metrics of one node type, named
+// <node type>_das_sampled_chain_head etc.
+type dasMetrics struct {
+	sampledChainHead  *prometheus.GaugeVec
+	catchUpHead       *prometheus.GaugeVec
+	networkHeadHeight *prometheus.GaugeVec
+	isRunning         *prometheus.GaugeVec
+	catchUpDone       *prometheus.GaugeVec
+	concurrency       *prometheus.GaugeVec
+	workers           *prometheus.GaugeVec
+}
+
+func newDASMetrics(namespace string) *dasMetrics {
+	m := &dasMetrics{
+		sampledChainHead: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "sampled_chain_head",
+			Help:      "Height up to which all headers have been sampled",
+		}, targetLabels),
+		catchUpHead: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "catch_up_head",
+			Help:      "Height the DAS catch-up routine has reached",
+		}, targetLabels),
+		networkHeadHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "network_head_height",
+			Help:      "Network head height known to the DAS routine",
+		}, targetLabels),
+		isRunning: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "is_running",
+			Help:      "Whether the DAS routine is running (1) or not (0)",
+		}, targetLabels),
+		catchUpDone: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "catch_up_done",
+			Help:      "Whether the DAS routine has caught up with the network head (1) or not (0)",
+		}, targetLabels),
+		concurrency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "concurrency",
+			Help:      "Maximum number of concurrent DAS workers",
+		}, targetLabels),
+		workers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "das",
+			Name:      "workers",
+			Help:      "Number of active DAS workers by job type",
+		}, append(targetLabels, "job_type")),
+	}
+	addTargetMetrics(m.sampledChainHead, m.catchUpHead, m.networkHeadHeight,
+		m.isRunning, m.catchUpDone, m.concurrency, m.workers)
+	return m
+}
+
+var dasMetricsByType = map[string]*dasMetrics{
+	nodeTypeLight: newDASMetrics(nodeTypeLight),
+	nodeTypeFull:  newDASMetrics(nodeTypeFull),
+}
+
+func init() {
+	registerCollector("das", newDASCollector)
+}
+
+// dasCollector exports the sampling stats of light and full nodes.
+type dasCollector struct {
+	client *celestiarpc.Client
+	target target
+}
+
+func newDASCollector(client *celestiarpc.Client, t target) Collector {
+	if !t.collectsDAS() {
+		return nil
+	}
+	return &dasCollector{client: client, target: t}
+}
+
+func (c *dasCollector) Name() string { return "das" }
+
+func (c *dasCollector) Collect(ctx context.Context) error {
+	t := c.target
+	stats, err := c.client.SamplingStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	m := dasMetricsByType[t.NodeType]
+	m.sampledChainHead.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(stats.SampledChainHead))
+	m.catchUpHead.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(stats.CatchupHead))
+	m.networkHeadHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(stats.NetworkHead))
+	// Bridge and full nodes observe the block time from the header times
+	// of their header collector, if it runs.
+	if !t.collectsHeaders() || !t.collectorEnabled("header") {
+		blockTimes.observe(t, stats.NetworkHead, time.Now())
+	}
+	m.isRunning.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(boolToFloat(stats.IsRunning))
+	m.catchUpDone.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(boolToFloat(stats.CatchUpDone))
+	m.concurrency.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(stats.Concurrency))
+
+	workers := make(map[string]int)
+	for _, w := range stats.Workers {
+		workers[w.JobType]++
+	}
+	m.workers.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	for jobType, n := range workers {
+		m.workers.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, jobType).Set(float64(n))
+	}
+	return nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
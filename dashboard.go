@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+// metricInfo describes a per-target metric for generated dashboards and
+// rules.
+type metricInfo struct {
+	name   string
+	help   string
+	kind   string // gauge, counter, histogram or summary
+	labels []string
+}
+
+var descPattern = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{.*\}, variableLabels: \[(.*)\]\}$`)
+
+// describeTargetMetrics returns the per-target metrics as registered via
+// addTargetMetrics, so generated artifacts follow renamed or added metrics.
+func describeTargetMetrics() ([]metricInfo, error) {
+	var infos []metricInfo
+	for _, m := range targetMetrics {
+		kind := "gauge"
+		switch m.(type) {
+		case *prometheus.CounterVec:
+			kind = "counter"
+		case *prometheus.HistogramVec:
+			kind = "histogram"
+		case *prometheus.SummaryVec:
+			kind = "summary"
+		}
+
+		descs := make(chan *prometheus.Desc, 1)
+		go func() {
+			m.Describe(descs)
+			close(descs)
+		}()
+		for d := range descs {
+			match := descPattern.FindStringSubmatch(d.String())
+			if match == nil {
+				return nil, fmt.Errorf("unexpected metric description %s", d)
+			}
+			name, err := strconv.Unquote(match[1])
+			if err != nil {
+				return nil, err
+			}
+			help, err := strconv.Unquote(match[2])
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, metricInfo{name: name, help: help, kind: kind, labels: strings.Fields(match[3])})
+		}
+	}
+	return infos, nil
+}
+
+// extraLabels returns the labels of the metric besides the target labels.
+func (m metricInfo) extraLabels() []string {
+	var extra []string
+	for _, l := range m.labels {
+		if l != "node" && l != "endpoint" && l != "network" {
+			extra = append(extra, l)
+		}
+	}
+	return extra
+}
+
+type dashboardRow struct{ prefix, title string }
+
+// dashboardRows groups the metrics into dashboard rows by name prefix: by
+// node type for the legacy names, else by subsystem.
+func dashboardRows(n metricNaming) []dashboardRow {
+	if n.legacy {
+		return []dashboardRow{
+			{nodeTypeBridge + "_", "Bridge node"},
+			{nodeTypeFull + "_", "Full node"},
+			{nodeTypeLight + "_", "Light node"},
+			{"celestia_wallet_", "Wallet"},
+			{"celestia_validator_", "Validator"},
+			{"celestia_canary_", "Canary"},
+			{"exporter_", "Exporter"},
+			{"", "Other"},
+		}
+	}
+	p := n.prefix + "_"
+	return []dashboardRow{
+		{p + "header_", "Headers"},
+		{p + "das_", "Data availability sampling"},
+		{p + "eds_", "EDS availability"},
+		{p + "p2p_", "P2P"},
+		{p + "wallet_", "Wallet"},
+		{p + "validator_", "Validator"},
+		{p + "canary_", "Canary"},
+		{p + "exporter_", "Exporter"},
+		{"", "Other"},
+	}
+}
+
+type dashboardDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type dashboardGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type dashboardTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type dashboardPanel struct {
+	ID          int                  `json:"id"`
+	Type        string               `json:"type"`
+	Title       string               `json:"title"`
+	Description string               `json:"description,omitempty"`
+	Datasource  *dashboardDatasource `json:"datasource,omitempty"`
+	GridPos     dashboardGridPos     `json:"gridPos"`
+	Collapsed   *bool                `json:"collapsed,omitempty"`
+	Panels      []dashboardPanel     `json:"panels,omitempty"`
+	Targets     []dashboardTarget    `json:"targets,omitempty"`
+	FieldConfig interface{}          `json:"fieldConfig,omitempty"`
+}
+
+var promDatasource = &dashboardDatasource{Type: "prometheus", UID: "${datasource}"}
+
+// buildDashboard returns a Grafana dashboard with a row per metric group
+// and a time series panel per metric, filtered by the node variable.
+func buildDashboard(title, uid string, metrics []metricInfo, n metricNaming) map[string]interface{} {
+	requests, _ := n.name("exporter_rpc_requests_total")
+	var panels []dashboardPanel
+	id, y := 0, 0
+	done := make(map[string]bool)
+	for _, row := range dashboardRows(n) {
+		var rowMetrics []metricInfo
+		for _, m := range metrics {
+			if !done[m.name] && strings.HasPrefix(m.name, row.prefix) {
+				done[m.name] = true
+				rowMetrics = append(rowMetrics, m)
+			}
+		}
+		if len(rowMetrics) == 0 {
+			continue
+		}
+
+		id++
+		collapsed := false
+		panels = append(panels, dashboardPanel{
+			ID: id, Type: "row", Title: row.title, Collapsed: &collapsed,
+			GridPos: dashboardGridPos{H: 1, W: 24, X: 0, Y: y},
+		})
+		y++
+		for i, m := range rowMetrics {
+			id++
+			panels = append(panels, dashboardPanel{
+				ID:          id,
+				Type:        "timeseries",
+				Title:       m.name,
+				Description: m.help,
+				Datasource:  promDatasource,
+				GridPos:     dashboardGridPos{H: 8, W: 12, X: (i % 2) * 12, Y: y + (i/2)*8},
+				Targets:     []dashboardTarget{panelTarget(m)},
+				FieldConfig: map[string]interface{}{
+					"defaults":  map[string]interface{}{"unit": panelUnit(m)},
+					"overrides": []interface{}{},
+				},
+			})
+		}
+		y += (len(rowMetrics) + 1) / 2 * 8
+	}
+
+	return map[string]interface{}{
+		"title":         title,
+		"uid":           uid,
+		"tags":          []string{"celestia"},
+		"editable":      true,
+		"schemaVersion": 36,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"timezone":      "browser",
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{
+					"name":  "datasource",
+					"label": "Datasource",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+				{
+					"name":       "network",
+					"label":      "Network",
+					"type":       "query",
+					"datasource": promDatasource,
+					"definition": "label_values(" + requests + ", network)",
+					"query": map[string]string{
+						"query": "label_values(" + requests + ", network)",
+						"refId": "network",
+					},
+					"refresh":    2,
+					"sort":       1,
+					"multi":      true,
+					"includeAll": true,
+					"allValue":   ".*",
+					"current":    map[string]interface{}{},
+				},
+				{
+					"name":       "node",
+					"label":      "Node",
+					"type":       "query",
+					"datasource": promDatasource,
+					"definition": "label_values(" + requests + `{network=~"$network"}, node)`,
+					"query": map[string]string{
+						"query": "label_values(" + requests + `{network=~"$network"}, node)`,
+						"refId": "node",
+					},
+					"refresh":    2,
+					"sort":       1,
+					"multi":      true,
+					"includeAll": true,
+					"allValue":   ".*",
+					"current":    map[string]interface{}{},
+				},
+			},
+		},
+		"panels": panels,
+	}
+}
+
+func panelTarget(m metricInfo) dashboardTarget {
+	selector := m.name + `{network=~"$network", node=~"$node"}`
+	legend := "{{node}}"
+	by := "node"
+	for _, l := range m.extraLabels() {
+		legend += " {{" + l + "}}"
+		by += ", " + l
+	}
+
+	expr := selector
+	switch m.kind {
+	case "counter":
+		expr = "rate(" + selector + "[$__rate_interval])"
+	case "histogram":
+		expr = fmt.Sprintf(`histogram_quantile(0.95, sum by (le, %s) (rate(%s_bucket{network=~"$network", node=~"$node"}[$__rate_interval])))`, by, m.name)
+		legend += " p95"
+	}
+	return dashboardTarget{Expr: expr, LegendFormat: legend, RefID: "A"}
+}
+
+func panelUnit(m metricInfo) string {
+	switch {
+	case strings.HasSuffix(m.name, "_bytes_per_second"):
+		return "Bps"
+	case strings.HasSuffix(m.name, "_bytes"):
+		return "bytes"
+	case strings.HasSuffix(m.name, "_timestamp_seconds"):
+		return "dateTimeAsIso"
+	case strings.HasSuffix(m.name, "_seconds"):
+		return "s"
+	}
+	return "short"
+}
+
+// newDashboardCommand returns the dashboard subcommand, which writes a
+// Grafana dashboard for the exporter's metrics.
+func newDashboardCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Write a Grafana dashboard for the exported metrics",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	title := fs.String("title", "Celestia Node Exporter", "title of the dashboard")
+	uid := fs.String("uid", "celestia-exporter", "uid of the dashboard, keep it stable to overwrite the dashboard on re-import")
+	output := fs.String("output", "-", "file to write the dashboard JSON to, - for stdout")
+	naming := addNamingFlags(fs)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := naming.validate(); err != nil {
+			return err
+		}
+		metrics, err := describeTargetMetrics()
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(buildDashboard(*title, *uid, naming.describe(metrics), *naming))
+		})
+	}
+	return cmd
+}
+
+// writeOutput calls write with the file at path, or stdout if path is -.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
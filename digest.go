@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// digestResolution is the interval at which the nodes are sampled for the
+// daily digest.
+const digestResolution = time.Minute
+
+// digestNode is what the digest collected about a node since the last
+// post.
+type digestNode struct {
+	samples   int
+	reachable int
+	maxLag    *float64
+	blobs     float64
+	lastBlobs *float64
+	spent     float64
+	balances  map[string]float64
+}
+
+// slackDigest posts a daily summary of every node to its Slack incoming
+// webhook, slack_webhook_url of its target or --report.slack.webhook-url:
+// its uptime, the largest sync lag, the blobs in the blocks the
+// fees collector walked and the utia spent from its watched wallets. Like
+// the history it samples the registry and the health tracker and keeps
+// the samples in memory, so a digest after a restart only covers the time
+// since. Nodes sharing a webhook are posted in one message.
+type slackDigest struct {
+	gatherer prometheus.Gatherer
+	health   *healthTracker
+	targets  func() []target
+	// webhookURL is the default webhook, which also receives the digest
+	// of nodes removed since their last sample.
+	webhookURL string
+	instance   string
+	at         time.Duration
+	httpClient *http.Client
+
+	since time.Time
+	nodes map[string]*digestNode
+}
+
+// newSlackDigest returns a digest of the targets posted every day at the
+// time at, in the form 15:04 in UTC.
+func newSlackDigest(g prometheus.Gatherer, health *healthTracker, targets func() []target, webhookURL, at, instance string) (*slackDigest, error) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q, expected HH:MM in UTC", at)
+	}
+	return &slackDigest{
+		gatherer:   g,
+		health:     health,
+		targets:    targets,
+		webhookURL: webhookURL,
+		instance:   instance,
+		at:         time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute,
+		httpClient: &http.Client{},
+		nodes:      make(map[string]*digestNode),
+	}, nil
+}
+
+// next returns the first time the digest is due after now.
+func (d *slackDigest) next(now time.Time) time.Time {
+	now = now.UTC()
+	due := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(d.at)
+	if !due.After(now) {
+		due = due.AddDate(0, 0, 1)
+	}
+	return due
+}
+
+// run samples the nodes every digestResolution and posts the digest when
+// it is due, until ctx is done. While no webhook is configured nothing is
+// sampled or posted.
+func (d *slackDigest) run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.since = time.Now()
+		due := d.next(d.since)
+		ticker := time.NewTicker(digestResolution)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				webhooks := d.webhooks()
+				if len(webhooks) > 0 || d.webhookURL != "" {
+					d.sample()
+				}
+				if now.Before(due) {
+					continue
+				}
+				for _, group := range d.groups(webhooks) {
+					postCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+					err := d.post(postCtx, group.webhookURL, group.nodes, now)
+					cancel()
+					if err != nil {
+						log.Printf("Error posting the daily digest of %s to Slack: %v\n", strings.Join(group.nodes, ", "), err)
+						sinkPushes.WithLabelValues("slack", "failure").Inc()
+					} else {
+						sinkPushes.WithLabelValues("slack", "success").Inc()
+					}
+				}
+				// A failed digest is not retried, the next one starts
+				// over.
+				d.since = now
+				d.nodes = make(map[string]*digestNode)
+				due = d.next(now)
+			}
+		}
+	}()
+}
+
+// webhooks returns the webhook of every target that has one, by name.
+func (d *slackDigest) webhooks() map[string]string {
+	webhooks := make(map[string]string)
+	for _, t := range d.targets() {
+		if t.SlackWebhookURL != "" {
+			webhooks[t.Name] = t.SlackWebhookURL
+		}
+	}
+	return webhooks
+}
+
+// digestGroup is the nodes whose digest is posted to one webhook.
+type digestGroup struct {
+	webhookURL string
+	nodes      []string
+}
+
+// groups splits the sampled nodes by their webhook, sorted by the first
+// node. Removed nodes go to the default webhook, and without nodes it
+// receives an empty digest.
+func (d *slackDigest) groups(webhooks map[string]string) []digestGroup {
+	var groups []digestGroup
+	index := make(map[string]int)
+	for _, name := range sortedKeys(d.nodes) {
+		webhookURL, ok := webhooks[name]
+		if !ok {
+			webhookURL = d.webhookURL
+		}
+		if webhookURL == "" {
+			continue
+		}
+		i, ok := index[webhookURL]
+		if !ok {
+			i = len(groups)
+			index[webhookURL] = i
+			groups = append(groups, digestGroup{webhookURL: webhookURL})
+		}
+		groups[i].nodes = append(groups[i].nodes, name)
+	}
+	if len(groups) == 0 && d.webhookURL != "" {
+		groups = append(groups, digestGroup{webhookURL: d.webhookURL})
+	}
+	return groups
+}
+
+func (d *slackDigest) sample() {
+	families, err := d.gatherer.Gather()
+	if err != nil {
+		log.Printf("Error gathering metrics for the daily digest: %v\n", err)
+	}
+	nodes := nodeStatuses(families)
+	_, _, health := d.health.status()
+	blobs := make(map[string]float64)
+	for _, mf := range families {
+		if mf.GetName() != "celestia_pfb_blobs_total" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			blobs[metricLabels(m)["node"]] = m.GetCounter().GetValue()
+		}
+	}
+
+	for name, ts := range health.Targets {
+		dn, ok := d.nodes[name]
+		if !ok {
+			dn = &digestNode{balances: make(map[string]float64)}
+			d.nodes[name] = dn
+		}
+		dn.samples++
+		for _, cs := range ts.Collectors {
+			if cs.ConsecutiveFailures == 0 {
+				dn.reachable++
+				break
+			}
+		}
+
+		ns, ok := nodes[name]
+		if !ok {
+			continue
+		}
+		if lag := ns.SyncLagBlocks; lag != nil && (dn.maxLag == nil || *lag > *dn.maxLag) {
+			dn.maxLag = lag
+		}
+		if total, ok := blobs[name]; ok {
+			switch {
+			case dn.lastBlobs == nil:
+			case total >= *dn.lastBlobs:
+				dn.blobs += total - *dn.lastBlobs
+			default:
+				// The counter was reset when the target was reloaded.
+				dn.blobs += total
+			}
+			dn.lastBlobs = &total
+		}
+		// Only decreases count as spent, so top-ups of a wallet don't
+		// offset its spending.
+		for address, balance := range ns.BalancesUtia {
+			if last, ok := dn.balances[address]; ok && balance < last {
+				dn.spent += last - balance
+			}
+			dn.balances[address] = balance
+		}
+	}
+}
+
+// text renders the digest of the nodes names as a Slack message.
+func (d *slackDigest) text(names []string, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*Daily digest of %s*, %s to %s UTC\n", d.instance, d.since.UTC().Format("2006-01-02 15:04"), now.UTC().Format("2006-01-02 15:04"))
+	if len(names) == 0 {
+		b.WriteString("No nodes were monitored.\n")
+	}
+	for _, name := range names {
+		dn := d.nodes[name]
+		parts := []string{fmt.Sprintf("uptime %.2f%%", 100*float64(dn.reachable)/float64(dn.samples))}
+		if dn.maxLag != nil {
+			parts = append(parts, fmt.Sprintf("max sync lag %.0f blocks", *dn.maxLag))
+		}
+		if dn.lastBlobs != nil {
+			parts = append(parts, fmt.Sprintf("%.0f blobs observed", dn.blobs))
+		}
+		if len(dn.balances) > 0 {
+			parts = append(parts, fmt.Sprintf("%.0f utia spent", dn.spent))
+		}
+		fmt.Fprintf(&b, "• *%s*: %s\n", name, strings.Join(parts, ", "))
+	}
+	return b.String()
+}
+
+func (d *slackDigest) post(ctx context.Context, webhookURL string, names []string, now time.Time) error {
+	msg := struct {
+		Text string `json:"text"`
+	}{d.text(names, now)}
+	err := postJSON(ctx, d.httpClient, webhookURL, msg)
+	// The webhook URL is the secret, keep it out of the logs.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
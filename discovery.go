@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// discoverer finds nodes to monitor in an external system, like the service
+// discovery of Prometheus. The discovered targets get the defaults of the
+// flags or the config file like the configured ones.
+type discoverer interface {
+	// name identifies the discoverer in logs.
+	name() string
+	discover(ctx context.Context) ([]targetConfig, error)
+}
+
+// watcher is implemented by discoverers that learn of changes themselves,
+// like the file discovery; watch calls changed on every change until ctx
+// is done, which runs the discoverers before the next refresh.
+type watcher interface {
+	watch(ctx context.Context, changed func())
+}
+
+// discovery runs the discoverers every refresh interval and calls onChange
+// when the discovered targets change. A discoverer that fails keeps its
+// previous targets, so an unreachable API server doesn't drop the nodes.
+type discovery struct {
+	discoverers []discoverer
+	refresh     time.Duration
+	onChange    func()
+
+	mu    sync.Mutex
+	found [][]targetConfig
+}
+
+func newDiscovery(refresh time.Duration, discoverers ...discoverer) *discovery {
+	return &discovery{
+		discoverers: discoverers,
+		refresh:     refresh,
+		found:       make([][]targetConfig, len(discoverers)),
+	}
+}
+
+func (d *discovery) enabled() bool {
+	return len(d.discoverers) > 0
+}
+
+// targets returns the targets found by the last successful run of every
+// discoverer.
+func (d *discovery) targets() []targetConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var all []targetConfig
+	for _, found := range d.found {
+		all = append(all, found...)
+	}
+	return all
+}
+
+// update runs all discoverers once and reports whether the targets changed.
+func (d *discovery) update(ctx context.Context) bool {
+	changed := false
+	for i, disc := range d.discoverers {
+		found, err := disc.discover(ctx)
+		if err != nil {
+			log.Printf("Error discovering targets via %s: %v\n", disc.name(), err)
+			continue
+		}
+		d.mu.Lock()
+		if !reflect.DeepEqual(found, d.found[i]) {
+			d.found[i] = found
+			changed = true
+		}
+		d.mu.Unlock()
+	}
+	return changed
+}
+
+// run updates the targets every refresh interval and whenever a watcher
+// reports a change, until ctx is done.
+func (d *discovery) run(ctx context.Context, wg *sync.WaitGroup) {
+	// Changes reported while an update runs are coalesced into one more.
+	trigger := make(chan struct{}, 1)
+	for _, disc := range d.discoverers {
+		if w, ok := disc.(watcher); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.watch(ctx, func() {
+					select {
+					case trigger <- struct{}{}:
+					default:
+					}
+				})
+			}()
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(d.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-trigger:
+			}
+			updateCtx, cancel := context.WithTimeout(ctx, d.refresh)
+			changed := d.update(updateCtx)
+			cancel()
+			if changed && d.onChange != nil {
+				d.onChange()
+			}
+		}
+	}()
+}
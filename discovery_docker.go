@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels of containers that override what the discovery derives, e.g.
+// celestia-exporter.port: "26658" in a docker-compose file.
+const (
+	dockerLabelPort     = "celestia-exporter.port"
+	dockerLabelNetwork  = "celestia-exporter.network"
+	dockerLabelNodeType = "celestia-exporter.node-type"
+)
+
+// dockerDiscovery lists the running containers of the Docker daemon at host
+// whose image contains image or which carry label, e.g. the nodes of a
+// docker-compose setup on the same host.
+type dockerDiscovery struct {
+	host       string
+	image      string
+	label      string
+	network    string
+	port       int
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newDockerDiscovery(host, image, label, network string, port int) (*dockerDiscovery, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker host %q: %w", host, err)
+	}
+	d := &dockerDiscovery{
+		host:       host,
+		image:      image,
+		label:      label,
+		network:    network,
+		port:       port,
+		httpClient: &http.Client{},
+	}
+	switch u.Scheme {
+	case "unix":
+		// The host part of the URL is ignored, all requests go to the
+		// socket.
+		d.baseURL = "http://docker"
+		d.httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", u.Path)
+			},
+		}
+	case "tcp", "http":
+		d.baseURL = "http://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported Docker host %q, must be unix:// or tcp://", host)
+	}
+	return d, nil
+}
+
+func (d *dockerDiscovery) name() string { return "docker" }
+
+// The subset of the container list and inspect responses the discovery
+// reads.
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	Labels map[string]string `json:"Labels"`
+	Ports  []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+	} `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type dockerInspect struct {
+	Config struct {
+		Env []string `json:"Env"`
+		Cmd []string `json:"Cmd"`
+	} `json:"Config"`
+}
+
+func (d *dockerDiscovery) discover(ctx context.Context) ([]targetConfig, error) {
+	var containers []dockerContainer
+	if err := d.get(ctx, "/containers/json", &containers); err != nil {
+		return nil, err
+	}
+	var targets []targetConfig
+	for _, c := range containers {
+		if !d.matches(c) {
+			continue
+		}
+		var inspect dockerInspect
+		if err := d.get(ctx, "/containers/"+c.ID+"/json", &inspect); err != nil {
+			return nil, err
+		}
+		t, err := d.target(c, inspect)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// get decodes the response of the Docker API at path into result.
+func (d *dockerDiscovery) get(ctx context.Context, path string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// matches reports whether c runs a node: its image contains the image
+// pattern or it carries the label, given as name or name=value.
+func (d *dockerDiscovery) matches(c dockerContainer) bool {
+	if d.label != "" {
+		name, value, hasValue := strings.Cut(d.label, "=")
+		if v, ok := c.Labels[name]; ok && (!hasValue || v == value) {
+			return true
+		}
+	}
+	return d.image != "" && strings.Contains(c.Image, d.image)
+}
+
+// target returns the target of container c, named after the container.
+func (d *dockerDiscovery) target(c dockerContainer, inspect dockerInspect) (targetConfig, error) {
+	name := c.ID
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	port := d.port
+	if p, ok := c.Labels[dockerLabelPort]; ok {
+		var err error
+		if port, err = strconv.Atoi(p); err != nil {
+			return targetConfig{}, fmt.Errorf("container %s: invalid %s label %q", name, dockerLabelPort, p)
+		}
+	}
+	host, hostPort, err := d.address(c, port)
+	if err != nil {
+		return targetConfig{}, fmt.Errorf("container %s: %w", name, err)
+	}
+
+	// The celestia-node image is configured by the NODE_TYPE and
+	// P2P_NETWORK variables, a custom command by its flags.
+	env := make(map[string]string)
+	for _, e := range inspect.Config.Env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			env[k] = v
+		}
+	}
+	network := firstNonEmpty(c.Labels[dockerLabelNetwork], env["P2P_NETWORK"], commandFlag(inspect.Config.Cmd, "--p2p.network"))
+	nodeType := firstNonEmpty(c.Labels[dockerLabelNodeType], env["NODE_TYPE"], commandNodeType(inspect.Config.Cmd))
+
+	return targetConfig{
+		Name:       name,
+		Endpoint:   "http://" + net.JoinHostPort(host, strconv.Itoa(hostPort)),
+		P2PNetwork: network,
+		NodeType:   nodeType,
+	}, nil
+}
+
+// address returns where the node RPC of c is reached: the container's
+// address in the configured Docker network, else the published port on the
+// Docker host, else the container's address in its first network.
+func (d *dockerDiscovery) address(c dockerContainer, port int) (string, int, error) {
+	networks := c.NetworkSettings.Networks
+	if d.network != "" {
+		n, ok := networks[d.network]
+		if !ok || n.IPAddress == "" {
+			return "", 0, fmt.Errorf("not attached to network %s", d.network)
+		}
+		return n.IPAddress, port, nil
+	}
+	for _, p := range c.Ports {
+		if p.PrivatePort == port && p.PublicPort != 0 {
+			return d.publishedHost(p.IP), p.PublicPort, nil
+		}
+	}
+	names := make([]string, 0, len(networks))
+	for n := range networks {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if ip := networks[n].IPAddress; ip != "" {
+			return ip, port, nil
+		}
+	}
+	return "", 0, fmt.Errorf("port %d is not published and the container has no address", port)
+}
+
+// publishedHost returns the host to reach a port published on ip, which is
+// the Docker host unless the port is bound to a specific address.
+func (d *dockerDiscovery) publishedHost(ip string) string {
+	if ip != "" && ip != "0.0.0.0" && ip != "::" {
+		return ip
+	}
+	if u, err := url.Parse(d.host); err == nil && u.Scheme != "unix" {
+		return u.Hostname()
+	}
+	return "127.0.0.1"
+}
+
+// commandFlag returns the value of flag in the command cmd, given as
+// "--flag value" or "--flag=value".
+func commandFlag(cmd []string, flag string) string {
+	for i, arg := range cmd {
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"=")
+		}
+		if arg == flag && i+1 < len(cmd) {
+			return cmd[i+1]
+		}
+	}
+	return ""
+}
+
+// commandNodeType returns the node type of a "celestia <type> start"
+// command.
+func commandNodeType(cmd []string) string {
+	for _, arg := range cmd {
+		switch arg {
+		case nodeTypeBridge, nodeTypeFull, nodeTypeLight:
+			return arg
+		}
+	}
+	return ""
+}
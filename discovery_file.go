@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileDiscovery reads the targets from files in the file_sd format of
+// Prometheus, written by tools like Ansible or Terraform:
+//
+//	[{"targets": ["10.0.0.1:26658", "10.0.0.2:26658"], "labels": {"p2p_network": "mocha"}}]
+//
+// The files are re-read every refresh interval and, on Linux, whenever one
+// in their directories is written.
+type fileDiscovery struct {
+	patterns []string
+}
+
+// fileTargetGroup is an entry of a target file.
+type fileTargetGroup struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+func newFileDiscovery(patterns []string) (*fileDiscovery, error) {
+	for _, p := range patterns {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", p, err)
+		}
+	}
+	return &fileDiscovery{patterns: patterns}, nil
+}
+
+func (d *fileDiscovery) name() string { return "file" }
+
+func (d *fileDiscovery) discover(ctx context.Context) ([]targetConfig, error) {
+	var files []string
+	for _, p := range d.patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var targets []targetConfig
+	for _, file := range files {
+		found, err := readTargetFile(file)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, found...)
+	}
+	return targets, nil
+}
+
+// readTargetFile reads the targets of a JSON (.json) or YAML (.yml, .yaml)
+// target file. The labels set the fields of the config file's targets that
+// differ between nodes.
+func readTargetFile(file string) ([]targetConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var groups []fileTargetGroup
+	switch filepath.Ext(file) {
+	case ".json":
+		err = json.Unmarshal(data, &groups)
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &groups)
+	default:
+		return nil, fmt.Errorf("%s: unsupported file type, must be .json, .yml or .yaml", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	var targets []targetConfig
+	for _, g := range groups {
+		if g.Labels["name"] != "" && len(g.Targets) > 1 {
+			return nil, fmt.Errorf("%s: the name label requires a single target", file)
+		}
+		for _, address := range g.Targets {
+			tc := targetConfig{Endpoint: address}
+			if !strings.Contains(address, "://") {
+				tc.Endpoint = "http://" + address
+			}
+			for label, value := range g.Labels {
+				switch label {
+				case "name":
+					tc.Name = value
+				case "p2p_network":
+					tc.P2PNetwork = value
+				case "node_type":
+					tc.NodeType = value
+				case "auth_token_file":
+					tc.AuthTokenFile = value
+				case "auth_token_env":
+					tc.AuthTokenEnv = value
+				case "auth_token_command":
+					tc.AuthTokenCommand = value
+				case "node_store":
+					tc.NodeStore = value
+				case "consensus_endpoint":
+					tc.ConsensusEndpoint = value
+				case "validator_address":
+					tc.ValidatorAddress = value
+				default:
+					return nil, fmt.Errorf("%s: unsupported label %q", file, label)
+				}
+			}
+			targets = append(targets, tc)
+		}
+	}
+	return targets, nil
+}
+
+// watch calls changed whenever a file in the directories of the patterns
+// is written, created or removed, so a new target file is picked up at
+// once rather than at the next refresh.
+func (d *fileDiscovery) watch(ctx context.Context, changed func()) {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, p := range d.patterns {
+		dir := filepath.Dir(p)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	if err := watchFiles(ctx, dirs, changed); err != nil {
+		log.Printf("Error watching target files, only re-reading them every refresh interval: %v\n", err)
+	}
+}
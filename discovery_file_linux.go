@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// watchFiles calls changed whenever a file in dirs is written, created,
+// removed or renamed, until ctx is done. Directories rather than files are
+// watched, as tools replace files by renaming new ones over them.
+func watchFiles(ctx context.Context, dirs []string, changed func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	// The non-blocking descriptor is handled by the runtime poller, so
+	// closing it ends a pending read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	for _, dir := range dirs {
+		mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE)
+		if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+			f.Close()
+			return fmt.Errorf("watching %s: %w", dir, err)
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	buf := make([]byte, 4096)
+	for {
+		if _, err := f.Read(buf); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		changed()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// watchFiles is only implemented on Linux.
+func watchFiles(ctx context.Context, dirs []string, changed func()) error {
+	return errors.New("watching files is only supported on Linux")
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// serviceAccountDir holds the service account files mounted into every pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Annotations of pods and services that override what the discovery
+// derives, e.g. celestia-exporter/port: "26658".
+const (
+	annotationPort     = "celestia-exporter/port"
+	annotationNetwork  = "celestia-exporter/network"
+	annotationNodeType = "celestia-exporter/node-type"
+)
+
+// kubernetesDiscovery lists the pods or services matching a label selector
+// via the Kubernetes API, using the service account of the exporter's pod
+// unless apiServer is set, e.g. to http://localhost:8001 of kubectl proxy.
+type kubernetesDiscovery struct {
+	apiServer  string
+	namespace  string
+	selector   string
+	role       string
+	port       int
+	httpClient *http.Client
+	// tokenFile is re-read on every request, as the token is rotated.
+	tokenFile string
+}
+
+func newKubernetesDiscovery(apiServer, namespace, selector, role string, port int) (*kubernetesDiscovery, error) {
+	if role != "pod" && role != "service" {
+		return nil, fmt.Errorf("invalid role %q, must be pod or service", role)
+	}
+	d := &kubernetesDiscovery{
+		apiServer:  strings.TrimSuffix(apiServer, "/"),
+		namespace:  namespace,
+		selector:   selector,
+		role:       role,
+		port:       port,
+		httpClient: &http.Client{},
+	}
+	if d.apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("not running in a Kubernetes cluster, set the API server")
+		}
+		d.apiServer = "https://" + net.JoinHostPort(host, port)
+		d.tokenFile = serviceAccountDir + "/token"
+
+		ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+		if err != nil {
+			return nil, fmt.Errorf("reading service account CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in the service account CA")
+		}
+		d.httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}
+	}
+	if d.namespace == "" {
+		// Default to the namespace of the exporter's pod, which a Role
+		// grants access to.
+		d.namespace = "default"
+		if ns, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+			d.namespace = strings.TrimSpace(string(ns))
+		}
+	}
+	return d, nil
+}
+
+func (d *kubernetesDiscovery) name() string { return "kubernetes" }
+
+// The subset of the pod and service objects the discovery reads.
+type kubeMetadata struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type kubePort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"containerPort"`
+	Port          int    `json:"port"`
+}
+
+type kubePod struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		Containers []struct {
+			Ports []kubePort `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase      string `json:"phase"`
+		PodIP      string `json:"podIP"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+type kubeService struct {
+	Metadata kubeMetadata `json:"metadata"`
+	Spec     struct {
+		Ports []kubePort `json:"ports"`
+	} `json:"spec"`
+}
+
+func (d *kubernetesDiscovery) discover(ctx context.Context) ([]targetConfig, error) {
+	var targets []targetConfig
+	switch d.role {
+	case "pod":
+		var pods struct {
+			Items []kubePod `json:"items"`
+		}
+		if err := d.list(ctx, "pods", &pods); err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			// Pods that are not ready are skipped like by a Service, e.g.
+			// while a node starts.
+			if pod.Status.Phase != "Running" || pod.Status.PodIP == "" || !podReady(pod) {
+				continue
+			}
+			var ports []kubePort
+			for _, c := range pod.Spec.Containers {
+				ports = append(ports, c.Ports...)
+			}
+			port, err := d.rpcPort(pod.Metadata, ports)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, kubeTarget(pod.Metadata, pod.Status.PodIP, port))
+		}
+	case "service":
+		var services struct {
+			Items []kubeService `json:"items"`
+		}
+		if err := d.list(ctx, "services", &services); err != nil {
+			return nil, err
+		}
+		for _, svc := range services.Items {
+			port, err := d.rpcPort(svc.Metadata, svc.Spec.Ports)
+			if err != nil {
+				return nil, err
+			}
+			host := svc.Metadata.Name + "." + svc.Metadata.Namespace + ".svc"
+			targets = append(targets, kubeTarget(svc.Metadata, host, port))
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// list gets the objects of kind (pods or services) matching the selector.
+func (d *kubernetesDiscovery) list(ctx context.Context, kind string, result interface{}) error {
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/%s?labelSelector=%s", d.apiServer, url.PathEscape(d.namespace), kind, url.QueryEscape(d.selector))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if d.tokenFile != "" {
+		token, err := os.ReadFile(d.tokenFile)
+		if err != nil {
+			return fmt.Errorf("reading service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("listing %s: %s: %s", kind, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding %s: %w", kind, err)
+	}
+	return nil
+}
+
+// rpcPort returns the port of the node RPC: the port annotation, else the
+// port named rpc, else the default port.
+func (d *kubernetesDiscovery) rpcPort(meta kubeMetadata, ports []kubePort) (int, error) {
+	if p, ok := meta.Annotations[annotationPort]; ok {
+		port, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("%s/%s: invalid %s annotation %q", meta.Namespace, meta.Name, annotationPort, p)
+		}
+		return port, nil
+	}
+	for _, p := range ports {
+		if p.Name == "rpc" {
+			if p.ContainerPort != 0 {
+				return p.ContainerPort, nil
+			}
+			return p.Port, nil
+		}
+	}
+	return d.port, nil
+}
+
+func podReady(pod kubePod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// kubeTarget returns the target of a pod or service, named
+// <namespace>/<name>.
+func kubeTarget(meta kubeMetadata, host string, port int) targetConfig {
+	return targetConfig{
+		Name:       meta.Namespace + "/" + meta.Name,
+		Endpoint:   "http://" + net.JoinHostPort(host, strconv.Itoa(port)),
+		P2PNetwork: meta.Annotations[annotationNetwork],
+		NodeType:   meta.Annotations[annotationNodeType],
+	}
+}
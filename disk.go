@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+const (
+	// diskGrowthWindow is the period the growth rate of the data directory
+	// is computed over, diskGrowthMinSpan the shortest period it is
+	// reported for.
+	diskGrowthWindow  = 24 * time.Hour
+	diskGrowthMinSpan = 10 * time.Minute
+)
+
+var (
+	dataDirSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_data_dir_size_bytes",
+		Help: "Disk space used by the node data directory",
+	}, targetLabels)
+	dataDirStoreSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_data_dir_store_size_bytes",
+		Help: "Disk space used by a top-level directory of the node data directory, e.g. the blocks or data store",
+	}, append(targetLabels, "store"))
+	dataDirGrowth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_data_dir_growth_bytes_per_day",
+		Help: "Growth of the node data directory per day, averaged over up to the last 24 hours",
+	}, targetLabels)
+	diskFree = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_disk_free_bytes",
+		Help: "Free space available to unprivileged users on the volume of the node data directory",
+	}, targetLabels)
+	diskSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_disk_size_bytes",
+		Help: "Size of the volume of the node data directory",
+	}, targetLabels)
+	diskFillETA = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_disk_fill_eta_seconds",
+		Help: "Seconds until the volume of the node data directory is full at the current growth rate, absent while it doesn't grow",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(dataDirSize, dataDirStoreSize, dataDirGrowth, diskFree, diskSize, diskFillETA)
+	registerCollector("disk", newDiskCollector)
+}
+
+// diskSample is the size of the data directory at a point in time.
+type diskSample struct {
+	at   time.Time
+	size int64
+}
+
+// diskCollector measures the node data directory given by DataDir, which
+// requires the exporter to run on the host of the node. Walking a bridge
+// node's store takes a while, so it runs every minute by default.
+type diskCollector struct {
+	target  target
+	samples []diskSample
+}
+
+func newDiskCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.DataDir == "" {
+		return nil
+	}
+	return &diskCollector{target: t}
+}
+
+func (c *diskCollector) Name() string { return "disk" }
+
+func (c *diskCollector) Collect(ctx context.Context) error {
+	t := c.target
+
+	stores, err := storeSizes(ctx, t.DataDir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	dataDirStoreSize.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	for store, size := range stores {
+		total += size
+		if store != "" {
+			dataDirStoreSize.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, store).Set(float64(size))
+		}
+	}
+	dataDirSize.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(total))
+
+	free, size, err := volumeUsage(t.DataDir)
+	if err != nil {
+		return err
+	}
+	diskFree.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(free))
+	diskSize.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(size))
+
+	growth, ok := c.growth(time.Now(), total)
+	if !ok {
+		return nil
+	}
+	dataDirGrowth.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(growth)
+	if growth > 0 {
+		diskFillETA.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(free) / growth * (24 * time.Hour).Seconds())
+	} else {
+		diskFillETA.DeleteLabelValues(t.Name, t.Endpoint, t.P2PNetwork)
+	}
+	return nil
+}
+
+// growth records the size at now and returns the growth per day since the
+// oldest sample within diskGrowthWindow. ok is false until the samples
+// span diskGrowthMinSpan.
+func (c *diskCollector) growth(now time.Time, size int64) (perDay float64, ok bool) {
+	c.samples = append(c.samples, diskSample{at: now, size: size})
+	i := 0
+	for i < len(c.samples)-1 && now.Sub(c.samples[i].at) > diskGrowthWindow {
+		i++
+	}
+	c.samples = c.samples[i:]
+
+	oldest := c.samples[0]
+	span := now.Sub(oldest.at)
+	if span < diskGrowthMinSpan {
+		return 0, false
+	}
+	return float64(size-oldest.size) / span.Seconds() * (24 * time.Hour).Seconds(), true
+}
+
+// storeSizes returns the disk usage of dir by top-level directory, with
+// files directly in dir under "". Files removed during the walk, as the
+// stores compact, are skipped.
+func storeSizes(ctx context.Context, dir string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		store := ""
+		if filepath.Dir(rel) != "." {
+			store = topLevel(rel)
+		}
+		sizes[store] += diskUsage(info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// topLevel returns the first element of the relative path rel.
+func topLevel(rel string) string {
+	for {
+		parent := filepath.Dir(rel)
+		if parent == "." {
+			return rel
+		}
+		rel = parent
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// volumeUsage returns the free space available to unprivileged users and
+// the size of the volume holding path.
+func volumeUsage(path string) (free, size uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), st.Blocks * uint64(st.Bsize), nil
+}
+
+// diskUsage returns the space allocated for a file, which for the sparse
+// and preallocated files of the stores differs from its size.
+func diskUsage(info fs.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Blocks * 512
+	}
+	return info.Size()
+}
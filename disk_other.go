@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// volumeUsage is only implemented on Linux.
+func volumeUsage(path string) (free, size uint64, err error) {
+	return 0, 0, errors.New("measuring free disk space is only supported on Linux")
+}
+
+// diskUsage returns the size of a file.
+func diskUsage(info fs.FileInfo) int64 {
+	return info.Size()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// edsCheckHeights is the number of most recent heights, starting at the
+// local head, the eds collector checks on every run.
+const edsCheckHeights = 3
+
+// edsMetrics are the EDS availability metrics of one node type, named
+// <node type>_eds_availability_check_duration_seconds etc.
+type edsMetrics struct {
+	checkDuration       *prometheus.HistogramVec
+	checkFailures       *prometheus.CounterVec
+	storeQueryFailures  *prometheus.CounterVec
+	lastAvailableHeight *prometheus.GaugeVec
+	recentUnavailable   *prometheus.GaugeVec
+}
+
+func newEDSMetrics(namespace string) *edsMetrics {
+	m := &edsMetrics{
+		checkDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "eds",
+			Name:      "availability_check_duration_seconds",
+			Help:      "Duration of share.SharesAvailable for the most recent heights",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, targetLabels),
+		checkFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "eds",
+			Name:      "availability_check_failures_total",
+			Help:      "Number of recent heights share.SharesAvailable reported as unavailable",
+		}, targetLabels),
+		storeQueryFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "eds",
+			Name:      "store_query_failures_total",
+			Help:      "Number of recent heights the first share of the extended data square couldn't be read for",
+		}, targetLabels),
+		lastAvailableHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "eds",
+			Name:      "last_available_height",
+			Help:      "Highest height whose shares were available and readable in the last run",
+		}, targetLabels),
+		recentUnavailable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "eds",
+			Name:      "recent_heights_unavailable",
+			Help:      "Number of the most recent heights checked in the last run whose shares weren't available or readable",
+		}, targetLabels),
+	}
+	addTargetMetrics(m.checkDuration, m.checkFailures, m.storeQueryFailures,
+		m.lastAvailableHeight, m.recentUnavailable)
+	return m
+}
+
+var edsMetricsByType = map[string]*edsMetrics{
+	nodeTypeBridge: newEDSMetrics(nodeTypeBridge),
+	nodeTypeFull:   newEDSMetrics(nodeTypeFull),
+}
+
+func init() {
+	registerCollector("eds", newEDSCollector)
+}
+
+// edsCollector checks that the shares of the most recent heights of bridge
+// and full nodes can actually be served: a node can keep syncing headers
+// while its EDS store fails, which the header metrics don't show. For every
+// height it calls share.SharesAvailable and reads the first share of the
+// square from the store.
+type edsCollector struct {
+	client *celestiarpc.Client
+	target target
+}
+
+func newEDSCollector(client *celestiarpc.Client, t target) Collector {
+	if !t.collectsHeaders() {
+		return nil
+	}
+	return &edsCollector{client: client, target: t}
+}
+
+func (c *edsCollector) Name() string { return "eds" }
+
+func (c *edsCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	m := edsMetricsByType[t.NodeType]
+
+	head, err := client.LocalHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lastAvailable uint64
+	unavailable := 0
+	for i := uint64(0); i < edsCheckHeights && i < head.Height(); i++ {
+		height := head.Height() - i
+
+		start := time.Now()
+		if err := client.SharesAvailable(ctx, height); err != nil {
+			if !nodeError(err) {
+				return err
+			}
+			m.checkFailures.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Inc()
+			unavailable++
+			continue
+		}
+		m.checkDuration.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Observe(time.Since(start).Seconds())
+
+		if _, err := client.GetShare(ctx, height, 0, 0); err != nil {
+			if !nodeError(err) {
+				return err
+			}
+			m.storeQueryFailures.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Inc()
+			unavailable++
+			continue
+		}
+		if height > lastAvailable {
+			lastAvailable = height
+		}
+	}
+	if lastAvailable > 0 {
+		m.lastAvailableHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(lastAvailable))
+	}
+	m.recentUnavailable.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(unavailable))
+	return nil
+}
+
+// nodeError reports whether err is an error the node answered with, as
+// opposed to a failure to reach it. Unsupported methods don't count, they
+// are reported like any other failed run.
+func nodeError(err error) bool {
+	var rpcErr *celestiarpc.RPCError
+	return errors.As(err, &rpcErr) && !errors.Is(err, celestiarpc.ErrMethodNotFound)
+}
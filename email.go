@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultEmailSubject = `[{{upper .Status}}] {{.Rule}} ({{.Severity}}): {{.Summary}}`
+	defaultEmailBody    = `{{.Summary}}
+
+Rule:     {{.Rule}}
+Severity: {{.Severity}}
+Status:   {{.Status}}
+Started:  {{.StartsAt.UTC.Format "2006-01-02 15:04:05 MST"}}
+{{- if .EndsAt}}
+Resolved: {{.EndsAt.UTC.Format "2006-01-02 15:04:05 MST"}}
+{{- end}}
+{{range $name, $value := .Labels}}
+{{$name}}: {{$value}}
+{{- end}}
+`
+)
+
+// SMTP connection security of emailNotifier.
+const (
+	emailSTARTTLS = "starttls"
+	emailTLS      = "tls"
+	emailNone     = "none"
+)
+
+// emailNotifier sends alerts as emails via an SMTP server. The subject and
+// the body are text/templates executed on the alert. A firing alert that
+// was already mailed within minInterval, e.g. one that flaps, is not mailed
+// again, nor is its resolution.
+type emailNotifier struct {
+	addr     string
+	host     string
+	security string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	subject  *template.Template
+	body     *template.Template
+
+	minInterval time.Duration
+
+	mu         sync.Mutex
+	sent       map[string]time.Time
+	suppressed map[string]bool
+}
+
+var emailFuncs = template.FuncMap{"upper": strings.ToUpper}
+
+// newEmailNotifier returns a notifier sending from from to the addresses to
+// via the SMTP server at addr, host:port, authenticating with PLAIN if
+// username is set.
+func newEmailNotifier(addr, security, username, password, from string, to []string, subject, body string, minInterval time.Duration) (*emailNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP server address %q: %w", addr, err)
+	}
+	if security != emailSTARTTLS && security != emailTLS && security != emailNone {
+		return nil, fmt.Errorf("invalid TLS mode %q, must be starttls, tls or none", security)
+	}
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("both a sender and recipients are required")
+	}
+	n := &emailNotifier{
+		addr:        addr,
+		host:        host,
+		security:    security,
+		from:        from,
+		to:          to,
+		minInterval: minInterval,
+		sent:        make(map[string]time.Time),
+		suppressed:  make(map[string]bool),
+	}
+	if username != "" {
+		// PlainAuth refuses to send the password unencrypted to anything
+		// but localhost.
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	if n.subject, err = template.New("subject").Funcs(emailFuncs).Parse(subject); err != nil {
+		return nil, fmt.Errorf("parsing the subject template: %w", err)
+	}
+	if n.body, err = template.New("body").Funcs(emailFuncs).Parse(body); err != nil {
+		return nil, fmt.Errorf("parsing the body template: %w", err)
+	}
+	return n, nil
+}
+
+func (n *emailNotifier) notify(ctx context.Context, a alert) error {
+	now := time.Now()
+	if n.limited(a, now) {
+		return nil
+	}
+	if err := n.mail(ctx, a); err != nil {
+		return err
+	}
+	n.markSent(now, a)
+	return nil
+}
+
+// mail sends a, regardless of the rate limit.
+func (n *emailNotifier) mail(ctx context.Context, a alert) error {
+	var subject, body bytes.Buffer
+	if err := n.subject.Execute(&subject, a); err != nil {
+		return fmt.Errorf("rendering the subject: %w", err)
+	}
+	if err := n.body.Execute(&body, a); err != nil {
+		return fmt.Errorf("rendering the body: %w", err)
+	}
+	return n.send(ctx, n.message(strings.Join(strings.Fields(subject.String()), " "), body.String()))
+}
+
+// notifyGroup mails the alerts of a node that aren't rate limited in one
+// email, with a subject listing their rules and the bodies one after the
+// other.
+func (n *emailNotifier) notifyGroup(ctx context.Context, node string, alerts []alert) error {
+	now := time.Now()
+	var mailed []alert
+	for _, a := range alerts {
+		if !n.limited(a, now) {
+			mailed = append(mailed, a)
+		}
+	}
+	switch len(mailed) {
+	case 0:
+		return nil
+	case 1:
+		if err := n.mail(ctx, mailed[0]); err != nil {
+			return err
+		}
+		n.markSent(now, mailed[0])
+		return nil
+	}
+	var rules []string
+	var body bytes.Buffer
+	for i, a := range mailed {
+		rules = append(rules, a.Rule)
+		if i > 0 {
+			body.WriteString("\n----\n\n")
+		}
+		if err := n.body.Execute(&body, a); err != nil {
+			return fmt.Errorf("rendering the body: %w", err)
+		}
+	}
+	subject := fmt.Sprintf("%d alerts of node %s: %s", len(mailed), node, strings.Join(rules, ", "))
+	if err := n.send(ctx, n.message(subject, body.String())); err != nil {
+		return err
+	}
+	n.markSent(now, mailed...)
+	return nil
+}
+
+// limited reports whether a is not mailed because of the rate limit. Only
+// mails that were sent, see markSent, limit the ones after them, so an
+// alert whose mail failed is mailed again on its next notification.
+func (n *emailNotifier) limited(a alert, now time.Time) bool {
+	if n.minInterval <= 0 {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := a.key()
+	if a.Status == alertResolved {
+		if n.suppressed[key] {
+			delete(n.suppressed, key)
+			return true
+		}
+		return false
+	}
+	if last, ok := n.sent[key]; ok && now.Sub(last) < n.minInterval {
+		n.suppressed[key] = true
+		return true
+	}
+	return false
+}
+
+// markSent records that the firing alerts among alerts were mailed at now.
+func (n *emailNotifier) markSent(now time.Time, alerts ...alert) {
+	if n.minInterval <= 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	// Forget the alerts that can't limit anything anymore.
+	for k, last := range n.sent {
+		if now.Sub(last) >= n.minInterval {
+			delete(n.sent, k)
+		}
+	}
+	for _, a := range alerts {
+		if a.Status != alertResolved {
+			n.sent[a.key()] = now
+		}
+	}
+}
+
+func (n *emailNotifier) message(subject, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// send delivers msg, bounded by ctx, which net/smtp doesn't take itself.
+func (n *emailNotifier) send(ctx context.Context, msg []byte) error {
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if n.security == emailTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: n.host}}).DialContext(ctx, "tcp", n.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", n.addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if n.security == emailSTARTTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+	if n.auth != nil {
+		if err := c.Auth(n.auth); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, to := range n.to {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
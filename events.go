@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	eventSyncLost        = "sync_lost"
+	eventSyncRegained    = "sync_regained"
+	eventPeersDropped    = "peers_dropped"
+	eventBalanceLow      = "balance_below_threshold"
+	eventBalanceRestored = "balance_above_threshold"
+	eventUnreachable     = "node_unreachable"
+	eventReachable       = "node_reachable"
+)
+
+// eventBacklog is the number of past events kept for clients reconnecting
+// with Last-Event-ID.
+const eventBacklog = 256
+
+// event is a state change of a node, streamed on /api/v1/events.
+type event struct {
+	ID      uint64            `json:"id"`
+	Type    string            `json:"type"`
+	Time    time.Time         `json:"time"`
+	Labels  map[string]string `json:"labels"`
+	Summary string            `json:"summary"`
+	Value   *float64          `json:"value,omitempty"`
+}
+
+// eventState is what the event stream remembers of a node between two
+// evaluations to detect the changes.
+type eventState struct {
+	synced      *bool
+	peers       *float64
+	balanceLow  map[string]bool
+	unreachable bool
+}
+
+// eventStream compares the state of the nodes, read from the registry and
+// the health tracker like the alert engine does, at a fixed interval and
+// streams the changes to the clients of /api/v1/events as Server-Sent
+// Events. Unlike alerts, events need no rules or notifiers and fire on
+// every change.
+type eventStream struct {
+	gatherer   prometheus.Gatherer
+	health     *healthTracker
+	peerDrop   float64
+	minBalance float64
+
+	// nodes is only used by the evaluation loop.
+	nodes map[string]*eventState
+
+	mu          sync.Mutex
+	nextID      uint64
+	backlog     []event
+	subscribers map[chan event]bool
+	closed      bool
+}
+
+func newEventStream(g prometheus.Gatherer, health *healthTracker, peerDrop, minBalance float64) *eventStream {
+	return &eventStream{
+		gatherer:    g,
+		health:      health,
+		peerDrop:    peerDrop,
+		minBalance:  minBalance,
+		nodes:       make(map[string]*eventState),
+		nextID:      1,
+		subscribers: make(map[chan event]bool),
+	}
+}
+
+// run evaluates the nodes every interval until ctx is done.
+func (s *eventStream) run(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.evaluate(now)
+			}
+		}
+	}()
+}
+
+func (s *eventStream) evaluate(now time.Time) {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		log.Printf("Error gathering metrics for the events: %v\n", err)
+	}
+	nodes := nodeStatuses(families)
+	_, _, health := s.health.status()
+
+	// Removed targets are forgotten without events.
+	for name := range s.nodes {
+		if _, ok := nodes[name]; !ok {
+			delete(s.nodes, name)
+		}
+	}
+	for _, name := range sortedKeys(nodes) {
+		ns := nodes[name]
+		labels := map[string]string{"node": name, "endpoint": ns.Endpoint, "network": ns.Network}
+		prev, known := s.nodes[name]
+		if !known {
+			prev = &eventState{balanceLow: make(map[string]bool)}
+			s.nodes[name] = prev
+		}
+		emit := func(typ, summary string, value *float64, extra ...string) {
+			l := labels
+			if len(extra) > 0 {
+				l = make(map[string]string, len(labels)+len(extra)/2)
+				for k, v := range labels {
+					l[k] = v
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					l[extra[i]] = extra[i+1]
+				}
+			}
+			s.publish(event{Type: typ, Time: now, Labels: l, Summary: summary, Value: value})
+		}
+
+		// The first evaluation of a node only records its state, so a
+		// restart of the exporter doesn't repeat the events.
+		if ns.Synced != nil {
+			switch {
+			case !known || prev.synced == nil:
+			case *prev.synced && !*ns.Synced:
+				emit(eventSyncLost, fmt.Sprintf("node %s lost sync", name), ns.SyncLagBlocks)
+			case !*prev.synced && *ns.Synced:
+				emit(eventSyncRegained, fmt.Sprintf("node %s is in sync again", name), ns.SyncLagBlocks)
+			}
+			prev.synced = ns.Synced
+		}
+		if ns.Peers != nil {
+			if s.peerDrop > 0 && prev.peers != nil && *prev.peers > 0 && (*prev.peers-*ns.Peers) / *prev.peers >= s.peerDrop {
+				emit(eventPeersDropped, fmt.Sprintf("peers of node %s dropped from %.0f to %.0f", name, *prev.peers, *ns.Peers), ns.Peers)
+			}
+			prev.peers = ns.Peers
+		}
+		if s.minBalance > 0 {
+			for _, address := range sortedKeys(ns.BalancesUtia) {
+				balance := ns.BalancesUtia[address]
+				low := balance < s.minBalance
+				was, seen := prev.balanceLow[address]
+				prev.balanceLow[address] = low
+				switch {
+				case !seen || was == low:
+				case low:
+					emit(eventBalanceLow, fmt.Sprintf("balance of %s on node %s dropped to %.0f utia, below %.0f utia", address, name, balance, s.minBalance), &balance, "address", address)
+				default:
+					emit(eventBalanceRestored, fmt.Sprintf("balance of %s on node %s is %.0f utia, above %.0f utia again", address, name, balance, s.minBalance), &balance, "address", address)
+				}
+			}
+		}
+		if ts, ok := health.Targets[name]; ok && len(ts.Collectors) > 0 {
+			unreachable := true
+			for _, cs := range ts.Collectors {
+				unreachable = unreachable && cs.ConsecutiveFailures > 0
+			}
+			switch {
+			case !known || prev.unreachable == unreachable:
+			case unreachable:
+				emit(eventUnreachable, fmt.Sprintf("node %s is unreachable: %s", name, ts.LastError), nil)
+			default:
+				emit(eventReachable, fmt.Sprintf("node %s is reachable again", name), nil)
+			}
+			prev.unreachable = unreachable
+		}
+	}
+}
+
+// publish numbers e and sends it to all subscribers. Subscribers that
+// don't keep up are dropped; they reconnect and catch up from the backlog.
+func (s *eventStream) publish(e event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.ID = s.nextID
+	s.nextID++
+	s.backlog = append(s.backlog, e)
+	if len(s.backlog) > eventBacklog {
+		s.backlog = s.backlog[len(s.backlog)-eventBacklog:]
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel receiving the events after the ID after,
+// starting with those still in the backlog.
+func (s *eventStream) subscribe(after uint64) (chan event, []event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missed []event
+	for _, e := range s.backlog {
+		if e.ID > after {
+			missed = append(missed, e)
+		}
+	}
+	ch := make(chan event, 64)
+	if s.closed {
+		close(ch)
+		return ch, missed
+	}
+	s.subscribers[ch] = true
+	return ch, missed
+}
+
+func (s *eventStream) unsubscribe(ch chan event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers[ch] {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// close ends all streams, so a shutdown of the HTTP server doesn't wait
+// for the clients to disconnect.
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+}
+
+// ServeHTTP streams the events as Server-Sent Events. A client reconnecting
+// with the Last-Event-ID header, or the last_event_id parameter, first
+// receives the events it missed, as far as they are still kept.
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid last event ID %q", lastID), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ch, missed := s.subscribe(after)
+	defer s.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	// Comments keep proxies and load balancers from closing an idle
+	// stream.
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, e)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
@@ -0,0 +1,694 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	collectorLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exporter_last_success_timestamp_seconds",
+		Help: "Time of the last successful run of a collector as a Unix time, by collector",
+	}, append(targetLabels, "collector"))
+	collectorDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exporter_collector_duration_seconds",
+		Help: "Duration of the last run of a collector, including the wait for a scrape worker, by collector",
+	}, append(targetLabels, "collector"))
+	collectorOverruns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "exporter_collector_overruns_total",
+		Help: "Number of runs of a collector that took longer than its interval, delaying the next run, by collector",
+	}, append(targetLabels, "collector"))
+	scrapeWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "exporter_scrape_workers_busy",
+		Help: "Number of collector runs in progress across all targets, at most --scrape.workers if set",
+	})
+)
+
+func init() {
+	addTargetMetrics(collectorLastSuccess, collectorDuration, collectorOverruns)
+	addExporterMetrics(scrapeWorkersBusy)
+}
+
+// exporter runs the polling goroutines of every target and swaps them out
+// when the target list changes. In on-demand mode there are no polling goroutines;
+// collectAll is called on every scrape instead.
+type exporter struct {
+	httpClient *http.Client
+	// transportClients are the HTTP clients for targets with TLS or proxy
+	// settings, by settings.
+	transportClients map[transportSettings]*http.Client
+	health           *healthTracker
+	onDemand         bool
+	retry            celestiarpc.RetryPolicy
+	breaker          breakerConfig
+	limits           limitConfig
+	failoverRecheck  time.Duration
+	// workers holds a token per collector run in progress, nil without
+	// --scrape.workers.
+	workers chan struct{}
+
+	mu      sync.Mutex
+	running map[string]*runningTarget
+	tokens  map[string]celestiarpc.TokenSource
+	// stopped is set by shutdown, after which apply doesn't start targets.
+	stopped bool
+}
+
+type runningTarget struct {
+	target target
+	client *celestiarpc.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	// stop is closed on shutdown to end the polling loops after their
+	// current run, without cancelling in-flight calls like cancel does.
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	initOnce sync.Once
+	// resolved is target with the node type resolved, only set after init.
+	resolved   target
+	collectors []Collector
+}
+
+// breakerConfig configures the per-target circuit breakers; a threshold of
+// 0 disables them.
+type breakerConfig struct {
+	threshold    int
+	openDuration time.Duration
+}
+
+// limitConfig configures the per-target request limits and the number of
+// collector runs across all targets at a time; 0 disables a limit.
+type limitConfig struct {
+	maxQPS         float64
+	maxConcurrency int
+	workers        int
+}
+
+func newExporter(httpClient *http.Client, health *healthTracker, onDemand bool, retry celestiarpc.RetryPolicy, breaker breakerConfig, limits limitConfig, failoverRecheck time.Duration) *exporter {
+	var workers chan struct{}
+	if limits.workers > 0 {
+		workers = make(chan struct{}, limits.workers)
+	}
+	return &exporter{
+		workers:          workers,
+		httpClient:       httpClient,
+		health:           health,
+		onDemand:         onDemand,
+		retry:            retry,
+		breaker:          breaker,
+		limits:           limits,
+		failoverRecheck:  failoverRecheck,
+		transportClients: make(map[transportSettings]*http.Client),
+		running:          make(map[string]*runningTarget),
+		tokens:           make(map[string]celestiarpc.TokenSource),
+	}
+}
+
+// apply replaces the running targets with targets. Unchanged targets keep
+// polling, removed ones are stopped and their series deleted.
+func (e *exporter) apply(targets []target) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+
+	wanted := make(map[string]target)
+	for _, t := range targets {
+		wanted[t.Name] = t
+	}
+
+	for name, rt := range e.running {
+		if t, ok := wanted[name]; ok && reflect.DeepEqual(t, rt.target) {
+			continue
+		}
+		rt.cancel()
+		rt.wg.Wait()
+		deleteTargetMetrics(rt.target)
+		blockTimes.forget(rt.target)
+		e.health.remove(name)
+		delete(e.running, name)
+	}
+
+	for _, t := range targets {
+		if _, ok := e.running[t.Name]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		rt := &runningTarget{
+			target: t,
+			client: celestiarpc.New(t.Endpoint, "",
+				celestiarpc.WithHTTPClient(e.httpClientFor(t)),
+				celestiarpc.WithTokenSource(e.tokenSource(t)),
+				celestiarpc.WithRetryPolicy(e.retry),
+				celestiarpc.WithCircuitBreaker(newCircuitBreaker(t, e.breaker.threshold, e.breaker.openDuration)),
+				celestiarpc.WithLimiter(celestiarpc.NewLimiter(e.limits.maxQPS, e.limits.maxConcurrency)),
+				celestiarpc.WithFailover(newFailover(t, e.failoverRecheck)),
+				celestiarpc.WithRequestHook(observeRPC(t)),
+				celestiarpc.WithAuthFailureHandler(func(method string) {
+					authFailures.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Inc()
+					log.Printf("Auth token rejected by %s for %s\n", t.Name, method)
+				})),
+			ctx:    ctx,
+			cancel: cancel,
+			stop:   make(chan struct{}),
+		}
+		e.running[t.Name] = rt
+		if !e.onDemand {
+			rt.wg.Add(1)
+			go e.poll(rt)
+		}
+	}
+}
+
+// transportSettings are the settings of a target that need their own HTTP
+// transport.
+type transportSettings struct {
+	tls      targetTLS
+	proxyURL string
+}
+
+// httpClientFor returns the HTTP client for the target's TLS and proxy
+// settings.
+func (e *exporter) httpClientFor(t target) *http.Client {
+	settings := transportSettings{tls: t.TLS, proxyURL: t.ProxyURL}
+	if settings == (transportSettings{}) {
+		return e.httpClient
+	}
+	if hc, ok := e.transportClients[settings]; ok {
+		return hc
+	}
+	tlsConfig, err := t.TLS.clientConfig()
+	if err != nil {
+		// The settings were validated when loading the targets, the files
+		// must have changed since.
+		log.Printf("Error configuring TLS for %s, using defaults: %v\n", t.Name, err)
+		return e.httpClient
+	}
+	if t.TLS.InsecureSkipVerify {
+		log.Printf("TLS certificate verification disabled for %s\n", t.Name)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if t.ProxyURL != "" {
+		// Validated when loading the targets.
+		proxyURL, _ := url.Parse(t.ProxyURL)
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	hc := &http.Client{Transport: transport, Timeout: e.httpClient.Timeout}
+	e.transportClients[settings] = hc
+	return hc
+}
+
+// tokenSource resolves where the target's auth token comes from: the
+// configured token, the token file, the target's environment variable or
+// command, the environment, the keystore of the node store, or as a last
+// resort the celestia binary. File, keystore and command sources are shared
+// between targets.
+func (e *exporter) tokenSource(t target) celestiarpc.TokenSource {
+	switch {
+	case t.AuthToken != "":
+		return celestiarpc.StaticToken(t.AuthToken)
+	case t.AuthTokenFile != "":
+		if ts, ok := e.tokens[t.AuthTokenFile]; ok {
+			return ts
+		}
+		ts := &fileToken{path: t.AuthTokenFile}
+		e.tokens[t.AuthTokenFile] = ts
+		return ts
+	case t.AuthTokenEnv != "":
+		return envToken(t.AuthTokenEnv)
+	case t.AuthTokenCommand == "" && os.Getenv(authTokenEnv) != "":
+		return celestiarpc.StaticToken(os.Getenv(authTokenEnv))
+	}
+
+	// With the keystore of the node store at hand, tokens are minted
+	// in-process rather than with the celestia binary.
+	if t.AuthTokenCommand == "" && t.NodeStore != "" {
+		secretFile := filepath.Join(t.NodeStore, jwtSecretKey)
+		if _, err := os.Stat(secretFile); err == nil {
+			scope := authScopeAdmin
+			if t.AuthScope == authScopeRead {
+				scope = authScopeRead
+			}
+			key := "store|" + scope + "|" + secretFile
+			if ts, ok := e.tokens[key]; ok {
+				return ts
+			}
+			ts := &storeToken{secretFile: secretFile, permissions: scopePermissions[scope], ttl: t.AuthTokenTTL}
+			e.tokens[key] = ts
+			return ts
+		}
+	}
+
+	// The command was checked when the target was configured.
+	args, _ := tokenCommandArgs(t)
+	key := "exec|" + strings.Join(args, "\x00")
+	if ts, ok := e.tokens[key]; ok {
+		return ts
+	}
+	ts := &commandToken{args: args, ttl: t.AuthTokenTTL}
+	e.tokens[key] = ts
+	return ts
+}
+
+// initTarget resolves the node type, builds the collectors and starts the
+// header subscription if configured. It runs once, before the first
+// collection.
+func (e *exporter) initTarget(rt *runningTarget) {
+	rt.initOnce.Do(func() {
+		t := rt.target
+		if t.NodeType == nodeTypeAuto {
+			ctx, cancel := context.WithTimeout(rt.ctx, t.ScrapeTimeout)
+			t.NodeType = detectNodeType(ctx, rt.client, t)
+			cancel()
+		}
+		rt.resolved = t
+		exporterState.restore(t)
+		rt.collectors = buildCollectors(rt.client, t)
+
+		if t.Subscribe && t.collectsHeaders() && t.collectorEnabled("header") {
+			rt.wg.Add(1)
+			go e.subscribe(rt.ctx, rt, t)
+		}
+	})
+}
+
+// collect runs all collectors of the target once. With a header
+// subscription the collectors don't cover the header metrics.
+func (e *exporter) collect(ctx context.Context, rt *runningTarget) {
+	e.initTarget(rt)
+	for _, c := range rt.collectors {
+		if ctx.Err() != nil {
+			return
+		}
+		e.runCollector(ctx, rt, c)
+	}
+}
+
+// runCollector runs c once, bounded by the collector's timeout, which
+// includes the wait for a scrape worker. The run is cancelled as well when
+// ctx, of the scrape it is for, is done; as the scrape was given up on,
+// such a run isn't recorded.
+func (e *exporter) runCollector(ctx context.Context, rt *runningTarget, c Collector) {
+	t := rt.resolved
+	start := time.Now()
+	runCtx, cancel := context.WithTimeout(rt.ctx, t.collectorTimeout(c.Name()))
+	defer cancel()
+	if ctx.Done() != nil {
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-stop:
+			}
+		}()
+	}
+	var err error
+	if e.acquireWorker(runCtx) {
+		err = c.Collect(runCtx)
+		e.releaseWorker()
+	} else {
+		err = fmt.Errorf("no scrape worker free within the timeout: %w", runCtx.Err())
+	}
+	collectorDuration.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, c.Name()).Set(time.Since(start).Seconds())
+	if ctx.Err() != nil {
+		return
+	}
+	e.record(t, c.Name(), err)
+}
+
+// acquireWorker waits for a free scrape worker, unless ctx is done first.
+func (e *exporter) acquireWorker(ctx context.Context) bool {
+	if e.workers != nil {
+		select {
+		case e.workers <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	scrapeWorkersBusy.Inc()
+	return true
+}
+
+func (e *exporter) releaseWorker() {
+	scrapeWorkersBusy.Dec()
+	if e.workers != nil {
+		<-e.workers
+	}
+}
+
+// poll starts the periodic collection for the target. Every collector runs
+// in its own loop at its own interval, so a slow collector doesn't delay
+// the others.
+func (e *exporter) poll(rt *runningTarget) {
+	defer rt.wg.Done()
+
+	e.initTarget(rt)
+	for _, c := range rt.collectors {
+		rt.wg.Add(1)
+		go e.pollCollector(rt, c)
+	}
+}
+
+func (e *exporter) pollCollector(rt *runningTarget, c Collector) {
+	defer rt.wg.Done()
+
+	t := rt.resolved
+	interval := t.collectorInterval(c.Name())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		e.runCollector(context.Background(), rt, c)
+		if time.Since(start) > interval {
+			collectorOverruns.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, c.Name()).Inc()
+		}
+		// With an adaptive interval the next run follows the block time
+		// observed so far.
+		if next := pollInterval(t, c.Name()); next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+		if blockTimeCollectors[c.Name()] {
+			collectorInterval.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, c.Name()).Set(interval.Seconds())
+		}
+		select {
+		case <-rt.ctx.Done():
+			return
+		case <-rt.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// shutdown stops all targets, letting in-flight collections finish until
+// ctx is done. Collections still running then are cancelled.
+func (e *exporter) shutdown(ctx context.Context) {
+	e.mu.Lock()
+	e.stopped = true
+	targets := make([]*runningTarget, 0, len(e.running))
+	for _, rt := range e.running {
+		close(rt.stop)
+		targets = append(targets, rt)
+	}
+	e.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, rt := range targets {
+			rt.wg.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Shutdown deadline exceeded, cancelling in-flight collections\n")
+	}
+	for _, rt := range targets {
+		rt.cancel()
+	}
+	<-done
+}
+
+// targets returns the running targets, sorted by name.
+func (e *exporter) targets() []target {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	targets := make([]target, 0, len(e.running))
+	for _, rt := range e.running {
+		targets = append(targets, rt.target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets
+}
+
+// collectAll collects all targets concurrently and waits for them to finish.
+func (e *exporter) collectAll(ctx context.Context) {
+	e.mu.Lock()
+	targets := make([]*runningTarget, 0, len(e.running))
+	for _, rt := range e.running {
+		// apply waits for in-flight collections before dropping a target.
+		rt.wg.Add(1)
+		targets = append(targets, rt)
+	}
+	e.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, rt := range targets {
+		wg.Add(1)
+		go func(rt *runningTarget) {
+			defer wg.Done()
+			defer rt.wg.Done()
+			e.collect(ctx, rt)
+		}(rt)
+	}
+	wg.Wait()
+}
+
+// subscribe keeps a header.Subscribe subscription open for the target and
+// updates the height metrics for every pushed header. Dropped connections
+// are re-established after the header collector's interval.
+func (e *exporter) subscribe(ctx context.Context, rt *runningTarget, t target) {
+	defer rt.wg.Done()
+
+	// There is nothing to finish on a subscription, end it right away on
+	// shutdown.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-rt.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	timeout := t.collectorTimeout("header")
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		e.record(t, "header", updateHeaderMetrics(callCtx, rt.client, t))
+		cancel()
+
+		err := rt.client.SubscribeHeaders(ctx, func(network *celestiarpc.ExtendedHeader) {
+			callCtx, cancel := context.WithTimeout(ctx, timeout)
+			local, err := rt.client.LocalHead(callCtx)
+			cancel()
+			if err == nil {
+				setHeightMetrics(t, local, network)
+			} else {
+				markHeightsStale(t)
+			}
+			e.record(t, "header", err)
+		})
+		if err != nil {
+			// Until the subscription is back the heights aren't updated.
+			markHeightsStale(t)
+			e.record(t, "header", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(t.collectorInterval("header")):
+		}
+	}
+}
+
+// targetMetric is a metric vector labeled per target.
+type targetMetric interface {
+	prometheus.Collector
+	DeletePartialMatch(labels prometheus.Labels) int
+}
+
+var targetMetrics []targetMetric
+
+// addTargetMetrics remembers per-target metrics so that they get registered
+// by registerTargetMetrics, and their series deleted when a target is
+// removed.
+func addTargetMetrics(metrics ...targetMetric) {
+	targetMetrics = append(targetMetrics, metrics...)
+}
+
+// registerTargetMetrics registers all per-target metrics. In on-demand mode
+// they are wrapped in a collector that refreshes them on every scrape,
+// which is returned.
+func registerTargetMetrics(reg prometheus.Registerer, e *exporter, cacheTTL time.Duration) *onDemandCollector {
+	if e.onDemand {
+		collectors := make([]prometheus.Collector, len(targetMetrics))
+		for i, m := range targetMetrics {
+			collectors[i] = m
+		}
+		c := &onDemandCollector{exporter: e, ttl: cacheTTL, collectors: collectors}
+		reg.MustRegister(c)
+		return c
+	}
+	for _, m := range targetMetrics {
+		reg.MustRegister(m)
+	}
+	return nil
+}
+
+// onDemandCollector collects all targets when Prometheus scrapes it, unless
+// the previous collection is younger than ttl, and then exposes the
+// collected metrics.
+type onDemandCollector struct {
+	exporter   *exporter
+	ttl        time.Duration
+	collectors []prometheus.Collector
+
+	mu          sync.Mutex
+	lastRefresh time.Time
+	// scraped is set when a scrape refreshed the metrics for its gathering
+	// to come, see refreshFor.
+	scraped bool
+}
+
+func (c *onDemandCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, col := range c.collectors {
+		col.Describe(ch)
+	}
+}
+
+func (c *onDemandCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	if c.scraped {
+		c.scraped = false
+	} else {
+		c.refresh(context.Background())
+	}
+	c.mu.Unlock()
+
+	for _, col := range c.collectors {
+		col.Collect(ch)
+	}
+}
+
+// refreshFor collects the targets for the scrape r before its metrics are
+// gathered, with the context of the request bounded by the scrape timeout
+// Prometheus sends, so that the queries of a scrape Prometheus gave up on
+// are cancelled. It does nothing for a nil c, without on-demand mode.
+func (c *onDemandCollector) refreshFor(r *http.Request) {
+	if c == nil {
+		return
+	}
+	ctx := r.Context()
+	if s, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64); err == nil && s > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s*float64(time.Second)))
+		defer cancel()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh(ctx)
+	c.scraped = true
+}
+
+// refresh collects all targets unless the previous collection is younger
+// than the TTL. The caller holds c.mu.
+func (c *onDemandCollector) refresh(ctx context.Context) {
+	if time.Since(c.lastRefresh) >= c.ttl {
+		c.exporter.collectAll(ctx)
+		c.lastRefresh = time.Now()
+	}
+}
+
+// record logs a failed collector run and updates the target's health.
+// Runs failing because of an open circuit breaker aren't logged, the
+// breaker logs when it opens.
+func (e *exporter) record(t target, collector string, err error) {
+	switch {
+	case err == nil || errors.Is(err, celestiarpc.ErrCircuitOpen):
+	case errors.Is(err, celestiarpc.ErrMethodNotFound):
+		log.Printf("Error collecting %s metrics from %s: %v (the node API doesn't support it, disable the collector with --collectors.disable %s)\n", collector, t.Name, err, collector)
+	default:
+		log.Printf("Error collecting %s metrics from %s: %v\n", collector, t.Name, err)
+	}
+	if err == nil {
+		collectorLastSuccess.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, collector).SetToCurrentTime()
+	}
+	e.health.record(t, collector, err)
+}
+
+// detectNodeType asks the node for its type, falling back to bridge if the
+// node can't tell.
+func detectNodeType(ctx context.Context, client *celestiarpc.Client, t target) string {
+	if t.AuthScope == authScopeRead {
+		log.Printf("Can't detect the node type of %s with a read token, assuming bridge; set the node type\n", t.Name)
+		return nodeTypeBridge
+	}
+	info, err := client.Info(ctx)
+	if err != nil {
+		log.Printf("Error detecting node type of %s, assuming bridge: %v\n", t.Name, err)
+		return nodeTypeBridge
+	}
+	switch info.Type {
+	case celestiarpc.NodeTypeBridge, celestiarpc.NodeTypeFull, celestiarpc.NodeTypeLight:
+		log.Printf("Detected %s node at %s\n", info.Type, t.Name)
+		return info.Type.String()
+	}
+	log.Printf("Unknown node type %d reported by %s, assuming bridge\n", info.Type, t.Name)
+	return nodeTypeBridge
+}
+
+func deleteTargetMetrics(t target) {
+	labels := prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint}
+	for _, m := range targetMetrics {
+		m.DeletePartialMatch(labels)
+	}
+}
+
+// joinErrors combines the errors of a collector that makes several
+// independent calls into one, or returns nil if there were none.
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return joinedErrors(errs)
+}
+
+type joinedErrors []error
+
+func (e joinedErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether all of the errors match target, so that e.g. a
+// collector only counts as cut off by the circuit breaker if all of its
+// calls were.
+func (e joinedErrors) Is(target error) bool {
+	for _, err := range e {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	endpointFailovers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "exporter_endpoint_failovers_total",
+		Help: "Number of times requests to the node switched to one of its fallback endpoints",
+	}, targetLabels)
+	endpointActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exporter_endpoint_active",
+		Help: "Always 1, with the source of the endpoint the node with fallback endpoints is currently queried through as label: primary, secondary, secondary2 etc.",
+	}, append(targetLabels, "source"))
+)
+
+func init() {
+	addTargetMetrics(endpointFailovers, endpointActive)
+}
+
+// endpointSource returns the source label of the endpoint with index i of
+// a node with fallbacks: primary, secondary, secondary2 etc.
+func endpointSource(i int) string {
+	switch i {
+	case 0:
+		return "primary"
+	case 1:
+		return "secondary"
+	}
+	return "secondary" + strconv.Itoa(i)
+}
+
+// setEndpointSource marks the endpoint of t with the source as the one it
+// is currently queried through.
+func setEndpointSource(t target, source string) {
+	endpointActive.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	endpointActive.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, source).Set(1)
+}
+
+// newFailover returns the failover of t to its fallback endpoints, which
+// exports the switches and logs them, or nil if t has no fallbacks.
+func newFailover(t target, recheck time.Duration) *celestiarpc.Failover {
+	if len(t.Fallbacks) == 0 {
+		return nil
+	}
+	setEndpointSource(t, endpointSource(0))
+	return celestiarpc.NewFailover(t.Fallbacks, recheck, func(endpoint string, i int) {
+		setEndpointSource(t, endpointSource(i))
+		if i == 0 {
+			log.Printf("%s is reachable again, switched back to it\n", t.Endpoint)
+			return
+		}
+		endpointFailovers.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Inc()
+		log.Printf("WARNING: switched %s to its %s endpoint %s\n", t.Name, endpointSource(i), endpoint)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	minGasPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_min_gas_price_utia",
+		Help: "Minimum gas price in utia the consensus node accepts transactions with",
+	}, targetLabels)
+	networkMinGasPrice = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_network_min_gas_price_utia",
+		Help: "Minimum gas price in utia of the network",
+	}, targetLabels)
+	pfbFeePerBlob = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "celestia_pfb_fee_per_blob_utia",
+		Help:    "Fee in utia of the PayForBlobs transactions in the walked blocks divided by their number of blobs",
+		Buckets: prometheus.ExponentialBuckets(100, 2, 12),
+	}, targetLabels)
+	pfbGasPrice = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "celestia_pfb_gas_price_utia",
+		Help:    "Gas price in utia of the PayForBlobs transactions in the walked blocks, their fee divided by their gas limit",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 11),
+	}, targetLabels)
+	pfbBlobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_pfb_blobs_total",
+		Help: "Number of blobs of the PayForBlobs transactions in the walked blocks",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(minGasPrice, networkMinGasPrice, pfbFeePerBlob, pfbGasPrice, pfbBlobs)
+	registerCollector("fees", newFeesCollector)
+}
+
+// feesCollector exports the minimum gas prices of the consensus node at the
+// target's consensus endpoint and the fees of the PayForBlobs transactions
+// in its new blocks, so rollups can tune the fees of their blob
+// submissions. Like the blocks collector it starts at the latest block.
+type feesCollector struct {
+	client *cometrpc.Client
+	target target
+
+	lastHeight int64
+}
+
+func newFeesCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.ConsensusEndpoint == "" {
+		return nil
+	}
+	return &feesCollector{client: consensusClient(t), target: t}
+}
+
+func (c *feesCollector) Name() string { return "fees" }
+
+func (c *feesCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+
+	price, err := client.MinGasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	minGasPrice.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(price)
+	// celestia-app before v2 has no network minimum.
+	if price, err := client.NetworkMinGasPrice(ctx); err == nil {
+		networkMinGasPrice.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(price)
+	} else {
+		networkMinGasPrice.DeleteLabelValues(t.Name, t.Endpoint, t.P2PNetwork)
+	}
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	latest := status.SyncInfo.LatestBlockHeight
+	if c.lastHeight == 0 || latest-c.lastHeight > maxBlocksPerRun {
+		c.lastHeight = latest - 1
+	}
+
+	for h := c.lastHeight + 1; h <= latest; h++ {
+		b, err := client.Block(ctx, h)
+		if err != nil {
+			return err
+		}
+		for _, tx := range b.Data.Txs {
+			pfb, err := cometrpc.DecodePayForBlobs(tx)
+			if err != nil {
+				return err
+			}
+			if pfb == nil {
+				continue
+			}
+			pfbFeePerBlob.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Observe(pfb.Fee / float64(pfb.Blobs))
+			pfbBlobs.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Add(float64(pfb.Blobs))
+			if pfb.GasLimit > 0 {
+				pfbGasPrice.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Observe(pfb.Fee / float64(pfb.GasLimit))
+			}
+		}
+		c.lastHeight = h
+	}
+	return nil
+}
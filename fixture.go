@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"my-celestia-exporter/pkg/rpcfixture"
+)
+
+func newRecordCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "Record the JSON-RPC responses of a node to a fixture file, for replaying them with --replay",
+		Long: "Serves a proxy on --listen forwarding JSON-RPC requests to the node of --endpoint and appends every " +
+			"call with the node's answer to --output, until interrupted. Point an exporter, or any other client, at " +
+			"the proxy to record the node's behavior, e.g. to attach it to a bug report, and replay the fixture with " +
+			"--replay. Authorization headers are forwarded but never recorded; WebSocket subscriptions aren't proxied.",
+		Args: cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	endpoint := fs.String("endpoint", "http://localhost:26658", "rpc address of the node to record")
+	listen := fs.String("listen", "localhost:26659", "address the recording proxy listens on")
+	output := fs.String("output", "", "fixture file the calls are appended to")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *output == "" {
+			return fmt.Errorf("--output is required")
+		}
+		if u, err := url.Parse(*endpoint); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid --endpoint %q, must be an http:// or https:// URL", *endpoint)
+		}
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{
+			Handler:           rpcfixture.NewRecorder(*endpoint, &http.Client{}, f),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+		fmt.Fprintf(cmd.OutOrStdout(), "Recording %s to %s, point the client at http://%s\n", *endpoint, *output, listener.Addr())
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+	return cmd
+}
+
+// startReplayNodes serves the fixtures of --replay on loopback ports and
+// returns the targets monitoring them, named after the fixture files
+// without their extension.
+func startReplayNodes(files []string) ([]targetConfig, error) {
+	var targets []targetConfig
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		replayer, err := rpcfixture.Load(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		endpoint, err := serveLoopback("replayed node "+name, replayer)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Replaying %s as node %s\n", file, name)
+		targets = append(targets, targetConfig{
+			Name:     name,
+			Endpoint: endpoint,
+			// The fixtures hold no tokens, a token keeps the exporter from
+			// looking for one.
+			AuthToken: "replay",
+		})
+	}
+	return targets, nil
+}
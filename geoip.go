@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// peerGeoDB classifies the peers of the p2p collector by country and
+// autonomous system if --p2p.geoip.country-db or --p2p.geoip.asn-db is set.
+var peerGeoDB *peerGeo
+
+// peerGeo looks up IP addresses in MaxMind databases: a GeoLite2 or GeoIP2
+// Country or City database and an ASN database, either may be missing.
+type peerGeo struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+func openPeerGeo(countryDB, asnDB string) (*peerGeo, error) {
+	g := &peerGeo{}
+	var err error
+	if countryDB != "" {
+		if g.country, err = maxminddb.Open(countryDB); err != nil {
+			return nil, fmt.Errorf("opening country database: %w", err)
+		}
+	}
+	if asnDB != "" {
+		if g.asn, err = maxminddb.Open(asnDB); err != nil {
+			return nil, fmt.Errorf("opening ASN database: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// lookup returns the ISO country code and the AS number and organization
+// of ip, "unknown" for what the databases don't have.
+func (g *peerGeo) lookup(ip net.IP) (country, asn, org string) {
+	country, asn, org = "unknown", "unknown", "unknown"
+	if g.country != nil {
+		var rec struct {
+			Country struct {
+				ISOCode string `maxminddb:"iso_code"`
+			} `maxminddb:"country"`
+		}
+		if err := g.country.Lookup(ip, &rec); err == nil && rec.Country.ISOCode != "" {
+			country = rec.Country.ISOCode
+		}
+	}
+	if g.asn != nil {
+		var rec struct {
+			Number       uint   `maxminddb:"autonomous_system_number"`
+			Organization string `maxminddb:"autonomous_system_organization"`
+		}
+		if err := g.asn.Lookup(ip, &rec); err == nil && rec.Number != 0 {
+			asn, org = strconv.FormatUint(uint64(rec.Number), 10), rec.Organization
+		}
+	}
+	return country, asn, org
+}
+
+// peerIP returns the first public IP address of a peer's multiaddrs, e.g.
+// /ip4/1.2.3.4/tcp/2121, resolving DNS multiaddrs if the peer announces
+// no IP address. It returns nil if the peer has only private addresses.
+func peerIP(ctx context.Context, addrs []string) net.IP {
+	var hosts []string
+	for _, addr := range addrs {
+		parts := strings.Split(addr, "/")
+		if len(parts) < 3 {
+			continue
+		}
+		switch parts[1] {
+		case "ip4", "ip6":
+			if ip := net.ParseIP(parts[2]); isPublicIP(ip) {
+				return ip
+			}
+		case "dns", "dns4", "dns6":
+			hosts = append(hosts, parts[2])
+		}
+	}
+	for _, host := range hosts {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			if isPublicIP(ip.IP) {
+				return ip.IP
+			}
+		}
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return ip != nil && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	govActiveProposals = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_gov_active_proposals",
+		Help: "Number of governance proposals in their voting period",
+	}, targetLabels)
+	govProposalVotingEnd = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_gov_proposal_voting_end_timestamp_seconds",
+		Help: "Unix time the voting period of the active proposal ends",
+	}, append(targetLabels, "proposal"))
+	govValidatorVoted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_gov_validator_voted",
+		Help: "1 if the validator voted on the active proposal, 0 otherwise",
+	}, append(append([]string{}, validatorLabels...), "proposal"))
+)
+
+func init() {
+	addTargetMetrics(govActiveProposals, govProposalVotingEnd, govValidatorVoted)
+	registerCollector("gov", newGovCollector)
+}
+
+// govCollector exports the governance proposals in their voting period and
+// whether the validators of the target voted on them. The series of a
+// proposal are removed once its voting period is over.
+type govCollector struct {
+	client *cometrpc.Client
+	target target
+
+	// proposals are the IDs of the active proposals of the last run.
+	proposals map[string]bool
+}
+
+func newGovCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.AppGRPC == "" && t.ConsensusEndpoint == "" {
+		return nil
+	}
+	return &govCollector{client: consensusClient(t), target: t}
+}
+
+func (c *govCollector) Name() string { return "gov" }
+
+func (c *govCollector) Collect(ctx context.Context) error {
+	t := c.target
+	proposals, err := c.client.ActiveProposals(ctx)
+	if err != nil {
+		return err
+	}
+	govActiveProposals.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(len(proposals)))
+
+	var errs []error
+	active := make(map[string]bool, len(proposals))
+	for _, p := range proposals {
+		id := strconv.FormatUint(p.ID, 10)
+		active[id] = true
+		govProposalVotingEnd.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, id).Set(float64(p.VotingEndTime))
+		for _, addr := range t.stakingValidators() {
+			voter, err := cometrpc.AccountAddress(addr)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			voted, err := c.client.Voted(ctx, p.ID, voter)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			govValidatorVoted.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr, id).Set(boolToFloat(voted))
+		}
+	}
+
+	for id := range c.proposals {
+		if !active[id] {
+			labels := prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint, "proposal": id}
+			govProposalVotingEnd.DeletePartialMatch(labels)
+			govValidatorVoted.DeletePartialMatch(labels)
+		}
+	}
+	c.proposals = active
+	return joinErrors(errs)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	chainHalted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_chain_halted",
+		Help: "1 if the network head seen by all reachable nodes of the network stopped advancing for --chain.halt-after, 0 otherwise",
+	}, []string{"network"})
+	chainHeadAdvanced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_chain_head_last_advanced_timestamp_seconds",
+		Help: "Time the highest network head seen by the nodes of the network last advanced",
+	}, []string{"network"})
+)
+
+func init() {
+	addExporterMetrics(chainHalted, chainHeadAdvanced)
+}
+
+// chainHead is the highest network head of a network and when it was first
+// seen.
+type chainHead struct {
+	height   float64
+	advanced time.Time
+	halted   bool
+}
+
+// haltDetector tells a halted chain from stuck nodes: a network counts as
+// halted when the highest network head of its reachable nodes hasn't
+// advanced for haltAfter. A single stuck node doesn't hold back the
+// highest head, and nodes whose header collector fails are left out, so
+// unreachable nodes don't look like a halt either.
+type haltDetector struct {
+	gatherer  prometheus.Gatherer
+	health    *healthTracker
+	haltAfter time.Duration
+
+	networks map[string]*chainHead
+}
+
+func newHaltDetector(g prometheus.Gatherer, health *healthTracker, haltAfter time.Duration) *haltDetector {
+	return &haltDetector{
+		gatherer:  g,
+		health:    health,
+		haltAfter: haltAfter,
+		networks:  make(map[string]*chainHead),
+	}
+}
+
+// run checks the network heads every interval until ctx is done.
+func (d *haltDetector) run(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				d.check(now)
+			}
+		}
+	}()
+}
+
+func (d *haltDetector) check(now time.Time) {
+	families, err := d.gatherer.Gather()
+	if err != nil {
+		log.Printf("Error gathering metrics for the halt detection: %v\n", err)
+	}
+	_, _, health := d.health.status()
+
+	monitored := make(map[string]bool)
+	heads := make(map[string]float64)
+	for name, ns := range nodeStatuses(families) {
+		if ns.NetworkHeight == nil {
+			continue
+		}
+		monitored[ns.Network] = true
+		if cs, ok := health.Targets[name].Collectors["header"]; !ok || cs.ConsecutiveFailures > 0 {
+			continue
+		}
+		if head, ok := heads[ns.Network]; !ok || *ns.NetworkHeight > head {
+			heads[ns.Network] = *ns.NetworkHeight
+		}
+	}
+
+	// Networks without nodes left lose their series.
+	for network := range d.networks {
+		if !monitored[network] {
+			delete(d.networks, network)
+			chainHalted.DeleteLabelValues(network)
+			chainHeadAdvanced.DeleteLabelValues(network)
+		}
+	}
+	for network := range monitored {
+		ch, ok := d.networks[network]
+		if !ok {
+			ch = &chainHead{advanced: now}
+			d.networks[network] = ch
+		}
+		head, reachable := heads[network]
+		if reachable && head > ch.height {
+			ch.height, ch.advanced = head, now
+		}
+		chainHeadAdvanced.WithLabelValues(network).Set(float64(ch.advanced.Unix()))
+		if !reachable {
+			// Without reachable nodes there is no telling whether the
+			// chain moves on.
+			chainHalted.WithLabelValues(network).Set(0)
+			continue
+		}
+		halted := now.Sub(ch.advanced) >= d.haltAfter
+		if halted && !ch.halted {
+			log.Printf("WARNING: network %s seems halted, its head has been at height %.0f for %s\n", network, ch.height, now.Sub(ch.advanced).Round(time.Second))
+		} else if !halted && ch.halted {
+			log.Printf("Network %s advances again, its head is at height %.0f\n", network, ch.height)
+		}
+		ch.halted = halted
+		chainHalted.WithLabelValues(network).Set(boolToFloat(halted))
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// headerMetrics are the header sync metrics of one node type, named
+// <node type>_local_height etc.
+type headerMetrics struct {
+	localHeight       *prometheus.GaugeVec
+	networkHeight     *prometheus.GaugeVec
+	syncLagBlocks     *prometheus.GaugeVec
+	isSynced          *prometheus.GaugeVec
+	syncSecondsBehind *prometheus.GaugeVec
+	heightStale       *prometheus.GaugeVec
+	blocksSynced      *prometheus.CounterVec
+	rollbacks         *prometheus.CounterVec
+	syncRate          *prometheus.GaugeVec
+	syncETA           *prometheus.GaugeVec
+}
+
+func newHeaderMetrics(namespace string) *headerMetrics {
+	m := &headerMetrics{
+		localHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "local_height",
+			Help:      "Local height of the Celestia node",
+		}, targetLabels),
+		networkHeight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "network_height",
+			Help:      "Network height of the Celestia node",
+		}, targetLabels),
+		syncLagBlocks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sync_lag_blocks",
+			Help:      "Number of blocks the local head is behind the network head",
+		}, targetLabels),
+		isSynced: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "is_synced",
+			Help:      "Whether the sync lag is within the configured threshold (1) or not (0)",
+		}, targetLabels),
+		syncSecondsBehind: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sync_seconds_behind",
+			Help:      "Time difference between the network head and the local head header timestamps",
+		}, targetLabels),
+		heightStale: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "height_stale",
+			Help:      "Whether the last attempt to fetch the heights failed and the height metrics show the last successfully fetched values (1) or not (0)",
+		}, targetLabels),
+		blocksSynced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "blocks_synced_total",
+			Help:      "Number of blocks the local head advanced by, kept across exporter restarts with --state.file",
+		}, targetLabels),
+		rollbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "height_rollbacks_total",
+			Help:      "Number of times the local height went backwards, also across exporter restarts with --state.file",
+		}, targetLabels),
+		syncRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sync_rate_bps",
+			Help:      "Blocks per second the local head advanced at over the last minute",
+		}, targetLabels),
+		syncETA: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sync_eta_seconds",
+			Help:      "Estimated time until the local head reaches the network head at the rate the sync lag shrank at over the last minute, 0 once synced",
+		}, targetLabels),
+	}
+	addTargetMetrics(m.localHeight, m.networkHeight, m.syncLagBlocks, m.isSynced, m.syncSecondsBehind, m.heightStale, m.blocksSynced, m.rollbacks, m.syncRate, m.syncETA)
+	return m
+}
+
+var headerMetricsByType = map[string]*headerMetrics{
+	nodeTypeBridge: newHeaderMetrics(nodeTypeBridge),
+	nodeTypeFull:   newHeaderMetrics(nodeTypeFull),
+}
+
+func init() {
+	registerCollector("header", newHeaderCollector)
+}
+
+// headerCollector polls the local and network head of bridge and full nodes.
+// With --subscribe the header metrics are updated by the subscription
+// instead.
+type headerCollector struct {
+	client *celestiarpc.Client
+	target target
+}
+
+func newHeaderCollector(client *celestiarpc.Client, t target) Collector {
+	if !t.collectsHeaders() || t.Subscribe {
+		return nil
+	}
+	return &headerCollector{client: client, target: t}
+}
+
+func (c *headerCollector) Name() string { return "header" }
+
+func (c *headerCollector) Collect(ctx context.Context) error {
+	return updateHeaderMetrics(ctx, c.client, c.target)
+}
+
+func updateHeaderMetrics(ctx context.Context, client *celestiarpc.Client, t target) error {
+	local, network, err := client.Heads(ctx)
+	if err != nil {
+		markHeightsStale(t)
+		return err
+	}
+	setHeightMetrics(t, local, network)
+	return nil
+}
+
+// markHeightsStale flags the height metrics of t as outdated. The last good
+// values are kept rather than reported as 0, which would look like a node
+// that fell back to genesis and break lag calculations.
+func markHeightsStale(t target) {
+	headerMetricsByType[t.NodeType].heightStale.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(1)
+}
+
+func setHeightMetrics(t target, local, network *celestiarpc.ExtendedHeader) {
+	m := headerMetricsByType[t.NodeType]
+	m.heightStale.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(0)
+	m.localHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(local.Height()))
+	m.networkHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(network.Height()))
+	exporterState.observeHeight(t, local.Height())
+	blockTimes.observe(t, network.Height(), network.Header.Time)
+
+	lag := int(network.Height()) - int(local.Height())
+	if lag < 0 {
+		lag = 0
+	}
+	m.syncLagBlocks.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(lag))
+	m.isSynced.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(boolToFloat(lag <= t.SyncLagThreshold))
+
+	rate, closing, ok := headerSyncRates.observe(t, heightSample{at: time.Now(), local: local.Height(), network: network.Height()})
+	if ok {
+		m.syncRate.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(rate)
+		switch {
+		case lag <= t.SyncLagThreshold:
+			m.syncETA.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(0)
+		case closing > 0:
+			m.syncETA.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(lag) / closing)
+		default:
+			// The node doesn't catch up, there is no estimate.
+			m.syncETA.DeleteLabelValues(t.Name, t.Endpoint, t.P2PNetwork)
+		}
+	}
+
+	if !local.Header.Time.IsZero() && !network.Header.Time.IsZero() {
+		behind := network.Header.Time.Sub(local.Header.Time).Seconds()
+		if behind < 0 {
+			behind = 0
+		}
+		m.syncSecondsBehind.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(behind)
+	}
+}
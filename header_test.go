@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// headerNode answers header.LocalHead and header.NetworkHead with the
+// heights it is set to, or fails in the way fail does.
+type headerNode struct {
+	mu      sync.Mutex
+	local   uint64
+	network uint64
+	fail    func(w http.ResponseWriter)
+}
+
+func (n *headerNode) set(local, network uint64, fail func(w http.ResponseWriter)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.local, n.network, n.fail = local, network, fail
+}
+
+func (n *headerNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	local, network, fail := n.local, n.network, n.fail
+	n.mu.Unlock()
+	if fail != nil {
+		fail(w)
+		return
+	}
+	type request struct {
+		ID     uint64 `json:"id"`
+		Method string `json:"method"`
+	}
+	answer := func(req request) interface{} {
+		height := local
+		if req.Method == "header.NetworkHead" {
+			height = network
+		}
+		return map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  map[string]interface{}{"header": map[string]interface{}{"height": fmt.Sprint(height)}},
+		}
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// The heads are fetched in one batch or one by one.
+	var reqs []request
+	if err := json.Unmarshal(body, &reqs); err == nil {
+		resps := make([]interface{}, len(reqs))
+		for i, req := range reqs {
+			resps[i] = answer(req)
+		}
+		json.NewEncoder(w).Encode(resps)
+		return
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(answer(req))
+}
+
+func TestUpdateHeaderMetricsKeepsHeightsOnFailure(t *testing.T) {
+	failures := []struct {
+		name string
+		fail func(w http.ResponseWriter)
+	}{
+		{"transport error", func(w http.ResponseWriter) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		}},
+		{"HTTP 5xx", func(w http.ResponseWriter) {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+		}},
+		{"JSON-RPC error", func(w http.ResponseWriter) {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header: not found"}}`))
+		}},
+		{"malformed JSON", func(w http.ResponseWriter) {
+			w.Write([]byte(`[{"jsonrpc":"2.0","id":1,"result":`))
+		}},
+	}
+	for i, f := range failures {
+		t.Run(f.name, func(t *testing.T) {
+			node := &headerNode{}
+			srv := httptest.NewServer(node)
+			defer srv.Close()
+			tgt := target{Name: fmt.Sprintf("header-test-%d", i), Endpoint: srv.URL, P2PNetwork: "test", NodeType: nodeTypeBridge, SyncLagThreshold: 5}
+			defer deleteTargetMetrics(tgt)
+			m := headerMetricsByType[nodeTypeBridge]
+			labels := []string{tgt.Name, tgt.Endpoint, tgt.P2PNetwork}
+			client := celestiarpc.New(srv.URL, "")
+			check := func(local, network, lag, stale float64) {
+				t.Helper()
+				for _, c := range []struct {
+					name      string
+					got, want float64
+				}{
+					{"local height", testutil.ToFloat64(m.localHeight.WithLabelValues(labels...)), local},
+					{"network height", testutil.ToFloat64(m.networkHeight.WithLabelValues(labels...)), network},
+					{"sync lag", testutil.ToFloat64(m.syncLagBlocks.WithLabelValues(labels...)), lag},
+					{"height stale", testutil.ToFloat64(m.heightStale.WithLabelValues(labels...)), stale},
+				} {
+					if c.got != c.want {
+						t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+					}
+				}
+			}
+
+			node.set(100, 103, nil)
+			if err := updateHeaderMetrics(context.Background(), client, tgt); err != nil {
+				t.Fatalf("first fetch: %v", err)
+			}
+			check(100, 103, 3, 0)
+
+			node.set(0, 0, f.fail)
+			if err := updateHeaderMetrics(context.Background(), client, tgt); err == nil {
+				t.Fatal("failed fetch returned no error")
+			}
+			check(100, 103, 3, 1)
+
+			node.set(101, 103, nil)
+			if err := updateHeaderMetrics(context.Background(), client, tgt); err != nil {
+				t.Fatalf("fetch after the failure: %v", err)
+			}
+			check(101, 103, 2, 0)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// headerChainMetrics are the header chain verification metrics of one node
+// type, named <node type>_header_gap_detected etc.
+type headerChainMetrics struct {
+	gapDetected         *prometheus.GaugeVec
+	lastHeaderTimestamp *prometheus.GaugeVec
+	timeSinceLastBlock  *prometheus.GaugeVec
+}
+
+func newHeaderChainMetrics(namespace string) *headerChainMetrics {
+	m := &headerChainMetrics{
+		gapDetected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "header_gap_detected",
+			Help:      "Whether the most recent headers of the local chain are missing heights or don't link by hash (1) or not (0)",
+		}, targetLabels),
+		lastHeaderTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_header_timestamp_seconds",
+			Help:      "Timestamp of the local head header as a Unix time",
+		}, targetLabels),
+		timeSinceLastBlock: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "time_since_last_block_seconds",
+			Help:      "Seconds elapsed since the timestamp of the local head header",
+		}, targetLabels),
+	}
+	addTargetMetrics(m.gapDetected, m.lastHeaderTimestamp, m.timeSinceLastBlock)
+	return m
+}
+
+var reorgDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "celestia_reorg_detected_total",
+	Help: "Number of times heights the headerchain collector checked before came back with a different hash, by depth, the number of heights that changed",
+}, append(targetLabels, "depth"))
+
+var headerChainMetricsByType = map[string]*headerChainMetrics{
+	nodeTypeBridge: newHeaderChainMetrics(nodeTypeBridge),
+	nodeTypeFull:   newHeaderChainMetrics(nodeTypeFull),
+}
+
+func init() {
+	addTargetMetrics(reorgDetected)
+	registerCollector("headerchain", newHeaderChainCollector)
+}
+
+// headerChainCollector fetches the last HeaderVerifyDepth headers below the
+// local head of bridge and full nodes and checks that they form a chain. It
+// also reports the age of the local head, which keeps growing when the node
+// stops receiving headers even if its RPC still answers. The hashes of the
+// checked heights are kept, so a height that comes back with a different
+// hash on a later run shows up as a reorg.
+type headerChainCollector struct {
+	client *celestiarpc.Client
+	target target
+
+	hashes map[uint64]string
+}
+
+func newHeaderChainCollector(client *celestiarpc.Client, t target) Collector {
+	if !t.collectsHeaders() {
+		return nil
+	}
+	return &headerChainCollector{client: client, target: t, hashes: make(map[uint64]string)}
+}
+
+func (c *headerChainCollector) Name() string { return "headerchain" }
+
+func (c *headerChainCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	m := headerChainMetricsByType[t.NodeType]
+
+	head, err := client.LocalHead(ctx)
+	if err != nil {
+		return err
+	}
+	if !head.Header.Time.IsZero() {
+		m.lastHeaderTimestamp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(head.Header.Time.Unix()))
+		m.timeSinceLastBlock.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(time.Since(head.Header.Time).Seconds())
+	}
+
+	gap := false
+	next := head
+	headers := []*celestiarpc.ExtendedHeader{head}
+	for i := 1; i < t.HeaderVerifyDepth && next.Height() > 1; i++ {
+		h, err := client.GetByHeight(ctx, next.Height()-1)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, h)
+		if !linked(h, next) {
+			gap = true
+			break
+		}
+		next = h
+	}
+	m.gapDetected.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(boolToFloat(gap))
+	c.checkReorg(head.Height(), headers)
+	return nil
+}
+
+// checkReorg compares the hashes of headers with the ones seen on earlier
+// runs and forgets the heights that dropped out of the verify depth.
+func (c *headerChainCollector) checkReorg(head uint64, headers []*celestiarpc.ExtendedHeader) {
+	t := c.target
+	changed, lowest := 0, head
+	for _, h := range headers {
+		hash := h.Hash()
+		if hash == "" {
+			continue
+		}
+		if prev, ok := c.hashes[h.Height()]; ok && prev != hash {
+			changed++
+			lowest = h.Height()
+		}
+		c.hashes[h.Height()] = hash
+	}
+	for height := range c.hashes {
+		if height > head || head-height >= uint64(t.HeaderVerifyDepth) {
+			delete(c.hashes, height)
+		}
+	}
+	if changed > 0 {
+		log.Printf("WARNING: reorg of %d block(s) on %s, the hashes from height %d on changed\n", changed, t.Name, lowest)
+		reorgDetected.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, strconv.Itoa(changed)).Inc()
+	}
+}
+
+// linked reports whether next directly follows prev. Hashes are only
+// compared if the node returned both.
+func linked(prev, next *celestiarpc.ExtendedHeader) bool {
+	if next.Height() != prev.Height()+1 {
+		return false
+	}
+	if prev.Hash() == "" || next.Header.LastBlockID.Hash == "" {
+		return true
+	}
+	return prev.Hash() == next.Header.LastBlockID.Hash
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// healthTracker records the outcome of every collector run per target and
+// serves /healthz and /readyz from it.
+type healthTracker struct {
+	// failureThreshold is the number of consecutive failures after which a
+	// collector counts as unhealthy.
+	failureThreshold int
+	// onDemand disables the liveness check, which detects stuck polling
+	// loops; on-demand collection only happens when scraped.
+	onDemand bool
+
+	mu      sync.Mutex
+	targets map[string]*targetHealth
+}
+
+type targetHealth struct {
+	lastAttempt time.Time
+	collectors  map[string]*collectorHealth
+}
+
+type collectorHealth struct {
+	// interval is the interval the collector currently runs at, its
+	// adaptive one with --scrape.adaptive.
+	interval            time.Duration
+	lastAttempt         time.Time
+	lastSuccess         time.Time
+	lastError           string
+	lastErrorTime       time.Time
+	consecutiveFailures int
+}
+
+func newHealthTracker(failureThreshold int, onDemand bool) *healthTracker {
+	return &healthTracker{
+		failureThreshold: failureThreshold,
+		onDemand:         onDemand,
+		targets:          make(map[string]*targetHealth),
+	}
+}
+
+func (h *healthTracker) record(t target, collector string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	th, ok := h.targets[t.Name]
+	if !ok {
+		th = &targetHealth{collectors: make(map[string]*collectorHealth)}
+		h.targets[t.Name] = th
+	}
+	now := time.Now()
+	th.lastAttempt = now
+
+	ch, ok := th.collectors[collector]
+	if !ok {
+		ch = &collectorHealth{}
+		th.collectors[collector] = ch
+	}
+	ch.interval = pollInterval(t, collector)
+	ch.lastAttempt = now
+	if err != nil {
+		ch.lastError = err.Error()
+		ch.lastErrorTime = now
+		ch.consecutiveFailures++
+	} else {
+		ch.lastSuccess = now
+		ch.consecutiveFailures = 0
+	}
+}
+
+func (h *healthTracker) remove(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.targets, name)
+}
+
+type collectorStatus struct {
+	Healthy             bool       `json:"healthy"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorTime       *time.Time `json:"last_error_time,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+type targetStatus struct {
+	Ready       bool                       `json:"ready"`
+	Live        bool                       `json:"live"`
+	LastAttempt time.Time                  `json:"last_attempt"`
+	LastSuccess *time.Time                 `json:"last_success,omitempty"`
+	LastError   string                     `json:"last_error,omitempty"`
+	Collectors  map[string]collectorStatus `json:"collectors"`
+}
+
+type healthStatus struct {
+	Status  string                  `json:"status"`
+	Targets map[string]targetStatus `json:"targets"`
+}
+
+// status evaluates all targets. A target is live if one of its collectors
+// ran within three of its current intervals (always in on-demand mode), and
+// ready if every collector succeeded at least once and has not failed
+// failureThreshold times in a row.
+func (h *healthTracker) status() (live, ready bool, s healthStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	live, ready = true, len(h.targets) > 0
+	s.Targets = make(map[string]targetStatus)
+	now := time.Now()
+	for name, th := range h.targets {
+		ts := targetStatus{
+			Ready:       true,
+			Live:        h.onDemand,
+			LastAttempt: th.lastAttempt,
+			Collectors:  make(map[string]collectorStatus),
+		}
+
+		var lastSuccess, lastErrorTime time.Time
+		for _, cname := range sortedKeys(th.collectors) {
+			ch := th.collectors[cname]
+			ts.Live = ts.Live || now.Sub(ch.lastAttempt) <= 3*ch.interval
+			healthy := !ch.lastSuccess.IsZero() && ch.consecutiveFailures < h.failureThreshold
+			ts.Collectors[cname] = collectorStatus{
+				Healthy:             healthy,
+				LastSuccess:         timeOrNil(ch.lastSuccess),
+				LastError:           ch.lastError,
+				LastErrorTime:       timeOrNil(ch.lastErrorTime),
+				ConsecutiveFailures: ch.consecutiveFailures,
+			}
+			ts.Ready = ts.Ready && healthy
+			if ch.lastSuccess.After(lastSuccess) {
+				lastSuccess = ch.lastSuccess
+			}
+			if ch.lastErrorTime.After(lastErrorTime) {
+				lastErrorTime = ch.lastErrorTime
+				ts.LastError = ch.lastError
+			}
+		}
+		ts.LastSuccess = timeOrNil(lastSuccess)
+
+		live = live && ts.Live
+		ready = ready && ts.Ready
+		s.Targets[name] = ts
+	}
+
+	switch {
+	case !live:
+		s.Status = "stalled"
+	case !ready:
+		s.Status = "unready"
+	default:
+		s.Status = "ok"
+	}
+	return live, ready, s
+}
+
+func (h *healthTracker) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	live, _, s := h.status()
+	writeHealth(w, live, s)
+}
+
+func (h *healthTracker) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	_, ready, s := h.status()
+	writeHealth(w, ready, s)
+}
+
+func writeHealth(w http.ResponseWriter, ok bool, s healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s)
+}
+
+// timeOrNil returns nil for the zero time so it is omitted from JSON.
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
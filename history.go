@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// historySample is the state of a node at a point in time. Values the node
+// doesn't have, like the local height of a light node, are omitted.
+type historySample struct {
+	// At is a Unix time in seconds.
+	At            int64    `json:"t"`
+	LocalHeight   *float64 `json:"local_height,omitempty"`
+	NetworkHeight *float64 `json:"network_height,omitempty"`
+	SampledHeight *float64 `json:"das_sampled_height,omitempty"`
+	SyncLagBlocks *float64 `json:"sync_lag_blocks,omitempty"`
+}
+
+// historyRing holds the most recent samples of a node, overwriting the
+// oldest one once it is full.
+type historyRing struct {
+	samples []historySample
+	next    int
+	full    bool
+}
+
+func (r *historyRing) add(s historySample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	r.full = r.full || r.next == 0
+}
+
+// since returns the samples taken at or after t, oldest first.
+func (r *historyRing) since(t int64) []historySample {
+	ordered := r.samples[:r.next]
+	if r.full {
+		ordered = append(append([]historySample{}, r.samples[r.next:]...), r.samples[:r.next]...)
+	}
+	out := []historySample{}
+	for _, s := range ordered {
+		if s.At >= t {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// history keeps the heights and sync lag of every node in memory, sampled
+// every resolution for the last retention, for /api/v1/history and the
+// sparklines of the web UI.
+type history struct {
+	gatherer   prometheus.Gatherer
+	resolution time.Duration
+	size       int
+
+	mu    sync.Mutex
+	nodes map[string]*historyRing
+}
+
+func newHistory(g prometheus.Gatherer, retention, resolution time.Duration) (*history, error) {
+	if resolution <= 0 || retention < resolution {
+		return nil, fmt.Errorf("the resolution must be positive and not longer than the retention")
+	}
+	return &history{
+		gatherer:   g,
+		resolution: resolution,
+		size:       int(retention / resolution),
+		nodes:      make(map[string]*historyRing),
+	}, nil
+}
+
+// run samples the nodes every resolution until ctx is done.
+func (h *history) run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(h.resolution)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				h.record(now)
+			}
+		}
+	}()
+}
+
+func (h *history) record(now time.Time) {
+	families, err := h.gatherer.Gather()
+	if err != nil {
+		log.Printf("Error gathering metrics for the history: %v\n", err)
+	}
+	nodes := nodeStatuses(families)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Removed targets lose their history.
+	for name := range h.nodes {
+		if _, ok := nodes[name]; !ok {
+			delete(h.nodes, name)
+		}
+	}
+	for name, ns := range nodes {
+		ring, ok := h.nodes[name]
+		if !ok {
+			ring = &historyRing{samples: make([]historySample, h.size)}
+			h.nodes[name] = ring
+		}
+		ring.add(historySample{
+			At:            now.Unix(),
+			LocalHeight:   ns.LocalHeight,
+			NetworkHeight: ns.NetworkHeight,
+			SampledHeight: ns.SampledHeight,
+			SyncLagBlocks: ns.SyncLagBlocks,
+		})
+	}
+}
+
+type historyResponse struct {
+	ResolutionSeconds float64                    `json:"resolution_seconds"`
+	Nodes             map[string][]historySample `json:"nodes"`
+}
+
+// ServeHTTP returns the samples of all nodes, or of the nodes given by the
+// node parameter, within the duration given by since, e.g. ?since=6h.
+func (h *history) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from := int64(0)
+	if since := q.Get("since"); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid since %q, expected a duration like 6h", since), http.StatusBadRequest)
+			return
+		}
+		from = time.Now().Add(-d).Unix()
+	}
+	wanted := make(map[string]bool)
+	for _, name := range q["node"] {
+		wanted[name] = true
+	}
+
+	h.mu.Lock()
+	resp := historyResponse{ResolutionSeconds: h.resolution.Seconds(), Nodes: make(map[string][]historySample)}
+	for name, ring := range h.nodes {
+		if len(wanted) == 0 || wanted[name] {
+			resp.Nodes[name] = ring.since(from)
+		}
+	}
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
+}
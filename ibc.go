@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	ibcChannelLabels = append(targetLabels, "port", "channel")
+	ibcClientLabels  = append(append([]string{}, ibcChannelLabels...), "client", "counterparty_chain")
+)
+
+var (
+	ibcChannelState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_ibc_channel_state",
+		Help: "State of the IBC channel: 1 init, 2 try-open, 3 open, 4 closed",
+	}, ibcChannelLabels)
+	ibcClientExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_ibc_client_expiry_timestamp_seconds",
+		Help: "Unix time the light client of the IBC channel expires at unless a relayer updates it",
+	}, ibcClientLabels)
+	ibcClientLastUpdate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_ibc_client_last_update_timestamp_seconds",
+		Help: "Unix time of the header the light client of the IBC channel was last updated to",
+	}, ibcClientLabels)
+	ibcClientFrozen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_ibc_client_frozen",
+		Help: "1 if the light client of the IBC channel is frozen after misbehaviour, 0 otherwise",
+	}, ibcClientLabels)
+)
+
+func init() {
+	addTargetMetrics(ibcChannelState, ibcClientExpiry, ibcClientLastUpdate, ibcClientFrozen)
+	registerCollector("ibc", newIBCCollector)
+}
+
+// parseIBCChannel splits an IBC channel given as port/channel, or as
+// channel on the transfer port.
+func parseIBCChannel(s string) (port, channel string, err error) {
+	port, channel = "transfer", s
+	if i := strings.Index(s, "/"); i >= 0 {
+		port, channel = s[:i], s[i+1:]
+	}
+	if port == "" || !strings.HasPrefix(channel, "channel-") {
+		return "", "", fmt.Errorf("invalid IBC channel %q, must be port/channel-N or channel-N", s)
+	}
+	return port, channel, nil
+}
+
+// ibcCollector exports the state of the target's IBC channels and the
+// expiry of the light clients they are built on. A client expires when no
+// relayer updates it within its trusting period, which closes the channel
+// for good.
+type ibcCollector struct {
+	client *cometrpc.Client
+	target target
+}
+
+func newIBCCollector(_ *celestiarpc.Client, t target) Collector {
+	if len(t.IBCChannels) == 0 || (t.AppGRPC == "" && t.ConsensusEndpoint == "") {
+		return nil
+	}
+	return &ibcCollector{client: consensusClient(t), target: t}
+}
+
+func (c *ibcCollector) Name() string { return "ibc" }
+
+func (c *ibcCollector) Collect(ctx context.Context) error {
+	var errs []error
+	for _, ch := range c.target.IBCChannels {
+		// The channels were checked when the target was configured.
+		port, channel, _ := parseIBCChannel(ch)
+		if err := c.collectChannel(ctx, port, channel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+func (c *ibcCollector) collectChannel(ctx context.Context, port, channel string) error {
+	t := c.target
+	ch, err := c.client.IBCChannel(ctx, port, channel)
+	if err != nil {
+		return err
+	}
+	ibcChannelState.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, port, channel).Set(float64(ch.State))
+
+	cl, err := c.client.IBCChannelClient(ctx, port, channel)
+	if err != nil {
+		return err
+	}
+	// Drop the series of the previous client after a client upgrade.
+	for _, m := range []*prometheus.GaugeVec{ibcClientExpiry, ibcClientLastUpdate, ibcClientFrozen} {
+		m.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint, "port": port, "channel": channel})
+	}
+	labels := []string{t.Name, t.Endpoint, t.P2PNetwork, port, channel, cl.ClientID, cl.ChainID}
+	ibcClientExpiry.WithLabelValues(labels...).Set(float64(cl.Expiry))
+	ibcClientLastUpdate.WithLabelValues(labels...).Set(float64(cl.LastUpdate))
+	ibcClientFrozen.WithLabelValues(labels...).Set(boolToFloat(cl.Frozen))
+	return nil
+}
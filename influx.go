@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// influxSink writes the metrics as line protocol to the v2 write API of
+// InfluxDB. Every series becomes a measurement named after the metric, with
+// the labels as tags and the sample in the "value" field.
+type influxSink struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func newInfluxSink(baseURL, org, bucket, token string) (*influxSink, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if org == "" || bucket == "" {
+		return nil, fmt.Errorf("org and bucket are required")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+	q := url.Values{}
+	q.Set("org", org)
+	q.Set("bucket", bucket)
+	q.Set("precision", "ms")
+	u.RawQuery = q.Encode()
+	return &influxSink{url: u.String(), token: token, httpClient: &http.Client{}}, nil
+}
+
+func (s *influxSink) push(ctx context.Context, families []*dto.MetricFamily) error {
+	body := encodeLineProtocol(families, time.Now())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+func encodeLineProtocol(families []*dto.MetricFamily, now time.Time) []byte {
+	ts := strconv.FormatInt(now.UnixMilli(), 10)
+	var buf bytes.Buffer
+	flattenFamilies(families, func(name string, labels []seriesLabel, value float64) {
+		// Line protocol has no representation for these.
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return
+		}
+		buf.WriteString(measurementEscaper.Replace(name))
+		for _, l := range labels {
+			// Empty tag values are rejected by InfluxDB.
+			if l.value == "" {
+				continue
+			}
+			buf.WriteByte(',')
+			buf.WriteString(tagEscaper.Replace(l.name))
+			buf.WriteByte('=')
+			buf.WriteString(tagEscaper.Replace(l.value))
+		}
+		buf.WriteString(" value=")
+		buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(ts)
+		buf.WriteByte('\n')
+	})
+	return buf.Bytes()
+}
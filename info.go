@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	nodeInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_info",
+		Help: "Version, API version, type and chain ID of the node, always 1",
+	}, append(targetLabels, "version", "api_version", "node_type", "chain_id"))
+
+	networkMismatch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_network_mismatch",
+		Help: "Whether the chain ID of the node's network head doesn't match the configured network (1) or does (0)",
+	}, append(targetLabels, "chain_id"))
+)
+
+func init() {
+	addTargetMetrics(nodeInfo, networkMismatch)
+	registerCollector("info", newInfoCollector)
+	requirePermission("info", "admin")
+}
+
+// infoCollector exports what the node reports about itself via node.Info,
+// plus the chain ID of its network head, which it checks against the
+// configured network.
+type infoCollector struct {
+	client   *celestiarpc.Client
+	target   target
+	mismatch bool
+}
+
+func newInfoCollector(client *celestiarpc.Client, t target) Collector {
+	return &infoCollector{client: client, target: t}
+}
+
+func (c *infoCollector) Name() string { return "info" }
+
+func (c *infoCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	info, err := client.Info(ctx)
+	if err != nil {
+		return err
+	}
+	head, err := client.NetworkHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	// celestia-node reports its release as the API version; a separate
+	// version is only used if the node sends one.
+	version := info.Version
+	if version == "" {
+		version = info.APIVersion
+	}
+	// Drop the series of the previous version after an upgrade.
+	nodeInfo.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	nodeInfo.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, version, info.APIVersion, info.Type.String(), head.Header.ChainID).Set(1)
+
+	chainID := head.Header.ChainID
+	mismatch := !networkMatches(t.P2PNetwork, chainID)
+	if mismatch && !c.mismatch {
+		log.Printf("WARNING: %s is on chain %s, but it is configured for network %s. Check --p2p.network and the node's configuration\n", t.Name, chainID, t.P2PNetwork)
+	} else if !mismatch && c.mismatch {
+		log.Printf("%s is on chain %s again, matching network %s\n", t.Name, chainID, t.P2PNetwork)
+	}
+	c.mismatch = mismatch
+	networkMismatch.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	networkMismatch.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, chainID).Set(boolToFloat(mismatch))
+	return nil
+}
+
+// networkMatches reports whether chainID belongs to the celestia-node
+// network name, e.g. chain mocha-4 to network mocha and chain celestia to
+// network celestia (mainnet).
+func networkMatches(network, chainID string) bool {
+	return chainID == network || strings.HasPrefix(chainID, network+"-")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	mempoolTxs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_mempool_txs",
+		Help: "Number of unconfirmed transactions in the mempool of the consensus node",
+	}, targetLabels)
+	mempoolBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_mempool_bytes",
+		Help: "Total size of the unconfirmed transactions in the mempool of the consensus node in bytes",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(mempoolTxs, mempoolBytes)
+	registerCollector("mempool", newMempoolCollector)
+}
+
+// mempoolCollector exports the size of the mempool of the consensus node at
+// the target's consensus endpoint. A growing mempool shows a backlog of
+// blob submissions before they miss blocks.
+type mempoolCollector struct {
+	client *cometrpc.Client
+	target target
+}
+
+func newMempoolCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.ConsensusEndpoint == "" {
+		return nil
+	}
+	return &mempoolCollector{client: consensusClient(t), target: t}
+}
+
+func (c *mempoolCollector) Name() string { return "mempool" }
+
+func (c *mempoolCollector) Collect(ctx context.Context) error {
+	t := c.target
+	m, err := c.client.UnconfirmedTxs(ctx)
+	if err != nil {
+		return err
+	}
+	mempoolTxs.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(m.Total))
+	mempoolBytes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(m.TotalBytes))
+	return nil
+}
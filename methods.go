@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var rpcMethodUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "celestia_rpc_method_up",
+	Help: "1 if the last call of the probed JSON-RPC method succeeded, 0 if the node answered with an error or didn't answer",
+}, append(targetLabels, "method"))
+
+func init() {
+	addTargetMetrics(rpcMethodUp)
+	registerCollector("methods", newMethodsCollector)
+}
+
+// validateProbeMethod checks a method of --methods.probe, a JSON-RPC method
+// name like das.SamplingStats.
+func validateProbeMethod(method string) error {
+	module, name, ok := strings.Cut(method, ".")
+	if !ok || module == "" || name == "" || strings.ContainsAny(method, " /") {
+		return fmt.Errorf("invalid method %q, must be a JSON-RPC method like das.SamplingStats", method)
+	}
+	return nil
+}
+
+// methodsCollector calls the ProbeMethods of the target without parameters
+// and exports whether each call succeeded, so a failing API module shows
+// up even while the node answers the methods of the other collectors.
+type methodsCollector struct {
+	client *celestiarpc.Client
+	target target
+}
+
+func newMethodsCollector(client *celestiarpc.Client, t target) Collector {
+	if len(t.ProbeMethods) == 0 {
+		return nil
+	}
+	return &methodsCollector{client: client, target: t}
+}
+
+func (c *methodsCollector) Name() string { return "methods" }
+
+func (c *methodsCollector) Collect(ctx context.Context) error {
+	t := c.target
+	var wg sync.WaitGroup
+	errs := make([]error, len(t.ProbeMethods))
+	for i, method := range t.ProbeMethods {
+		wg.Add(1)
+		go func(i int, method string) {
+			defer wg.Done()
+			errs[i] = c.client.Call(ctx, method, nil)
+			rpcMethodUp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, method).Set(boolToFloat(errs[i] == nil))
+		}(i, method)
+	}
+	wg.Wait()
+
+	// A failing method is what the metrics report; the collector only
+	// fails if the node couldn't be reached at all.
+	for _, err := range errs {
+		var rpcErr *celestiarpc.RPCError
+		if err == nil || errors.As(err, &rpcErr) {
+			return nil
+		}
+	}
+	return errs[0]
+}
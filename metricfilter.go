@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// filterGatherer drops the gathered metric families whose name doesn't
+// match include or matches exclude, like a keep and a drop relabel rule on
+// __name__. Either may be nil.
+type filterGatherer struct {
+	gatherer prometheus.Gatherer
+	include  *regexp.Regexp
+	exclude  *regexp.Regexp
+}
+
+// compileMetricFilter compiles a regular expression of --metrics.include or
+// --metrics.exclude. Like in relabel configs it has to match the whole
+// metric name. An empty expression gives nil.
+func compileMetricFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+	}
+	return re, nil
+}
+
+func (g filterGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if g.include == nil && g.exclude == nil {
+		return families, err
+	}
+	kept := families[:0]
+	for _, mf := range families {
+		name := mf.GetName()
+		if g.include != nil && !g.include.MatchString(name) {
+			continue
+		}
+		if g.exclude != nil && g.exclude.MatchString(name) {
+			continue
+		}
+		kept = append(kept, mf)
+	}
+	return kept, err
+}
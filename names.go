@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/spf13/pflag"
+)
+
+// metricNaming maps the metric names the collectors register, which are the
+// names of earlier releases (bridge_local_height, exporter_rpc_requests_total),
+// to the exported names. Unless legacy names are requested, all metrics of
+// the exporter are exported under prefix with a subsystem:
+// bridge_local_height becomes celestia_header_local_height{node_type="bridge"}
+// and exporter_rpc_requests_total celestia_exporter_rpc_requests_total.
+type metricNaming struct {
+	legacy bool
+	prefix string
+}
+
+var metricPrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// nodeTypeSubsystems are the subsystems of the per node type metrics; the
+// ones without one of them, like bridge_local_height, are header metrics.
+var nodeTypeSubsystems = []string{"header_", "das_", "p2p_", "eds_"}
+
+// addNamingFlags defines the flags selecting the metric names on fs.
+func addNamingFlags(fs *pflag.FlagSet) *metricNaming {
+	n := &metricNaming{}
+	fs.BoolVar(&n.legacy, "metrics.legacy-names", false, "export the metric names of earlier releases, e.g. bridge_local_height instead of celestia_header_local_height{node_type=\"bridge\"}")
+	fs.StringVar(&n.prefix, "metrics.prefix", "celestia", "prefix of the exported metric names, ignored with --metrics.legacy-names")
+	return n
+}
+
+func (n metricNaming) validate() error {
+	if !n.legacy && !metricPrefixPattern.MatchString(n.prefix) {
+		return fmt.Errorf("invalid metric prefix %q", n.prefix)
+	}
+	return nil
+}
+
+// name returns the exported name of the metric registered as registered
+// and, for the per node type metrics, the node type to add as node_type
+// label.
+func (n metricNaming) name(registered string) (name, nodeType string) {
+	if n.legacy {
+		return registered, ""
+	}
+	for _, nt := range []string{nodeTypeBridge, nodeTypeFull, nodeTypeLight} {
+		rest := strings.TrimPrefix(registered, nt+"_")
+		if rest == registered {
+			continue
+		}
+		subsystem := "header_"
+		for _, s := range nodeTypeSubsystems {
+			if strings.HasPrefix(rest, s) {
+				subsystem = ""
+			}
+		}
+		return n.prefix + "_" + subsystem + rest, nt
+	}
+	switch {
+	case strings.HasPrefix(registered, "celestia_"):
+		return n.prefix + "_" + strings.TrimPrefix(registered, "celestia_"), ""
+	case strings.HasPrefix(registered, "exporter_"):
+		return n.prefix + "_" + registered, ""
+	}
+	// Go runtime, process and promhttp metrics keep their standard names.
+	return registered, ""
+}
+
+// selector returns the PromQL selector of the metric registered as
+// registered with the label matchers, e.g. code="200".
+func (n metricNaming) selector(registered string, matchers ...string) string {
+	name, nodeType := n.name(registered)
+	if nodeType != "" {
+		matchers = append([]string{`node_type="` + nodeType + `"`}, matchers...)
+	}
+	if len(matchers) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// describe maps metric descriptions to the exported names, merging the
+// per node type metrics.
+func (n metricNaming) describe(metrics []metricInfo) []metricInfo {
+	var out []metricInfo
+	index := make(map[string]int)
+	for _, m := range metrics {
+		name, nodeType := n.name(m.name)
+		if _, ok := index[name]; ok {
+			continue
+		}
+		index[name] = len(out)
+		m.name = name
+		if nodeType != "" {
+			m.labels = append(append([]string{}, m.labels...), "node_type")
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// namingGatherer renames the gathered metrics according to naming.
+type namingGatherer struct {
+	gatherer prometheus.Gatherer
+	naming   metricNaming
+}
+
+func (g namingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if g.naming.legacy {
+		return families, err
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, mf := range families {
+		name, nodeType := g.naming.name(mf.GetName())
+		if nodeType != "" {
+			labelName := "node_type"
+			for _, m := range mf.Metric {
+				m.Label = append(m.Label, &dto.LabelPair{Name: &labelName, Value: &nodeType})
+				sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+			}
+		}
+		if merged, ok := byName[name]; ok {
+			merged.Metric = append(merged.Metric, mf.Metric...)
+			continue
+		}
+		mf.Name = &name
+		byName[name] = mf
+	}
+
+	renamed := make([]*dto.MetricFamily, 0, len(byName))
+	for _, name := range sortedKeys(byName) {
+		renamed = append(renamed, byName[name])
+	}
+	return renamed, err
+}
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are the labels of the exporter's own series, which the
+// constant labels of --metrics.labels must not override.
+var reservedLabels = map[string]bool{"node": true, "endpoint": true, "network": true, "node_type": true, "source": true}
+
+// parseMetricLabels parses the constant labels of --metrics.labels, a
+// comma-separated list of name=value pairs added to every exported series.
+func parseMetricLabels(list string) (prometheus.Labels, error) {
+	values, err := parseKeyValueList(list)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(prometheus.Labels, len(values))
+	for name, value := range values {
+		switch {
+		case !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__"):
+			return nil, fmt.Errorf("invalid label name %q", name)
+		case reservedLabels[name]:
+			return nil, fmt.Errorf("label %q is set by the exporter", name)
+		case value == "":
+			return nil, fmt.Errorf("label %q has no value", name)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
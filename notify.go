@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookNotifier posts every alert as JSON to a URL.
+type webhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (n *webhookNotifier) notify(ctx context.Context, a alert) error {
+	return postJSON(ctx, n.httpClient, n.url, a)
+}
+
+// notifyGroup posts the alerts of a node as one JSON object with the node
+// and the list of alerts.
+func (n *webhookNotifier) notifyGroup(ctx context.Context, node string, alerts []alert) error {
+	return postJSON(ctx, n.httpClient, n.url, struct {
+		Node   string  `json:"node"`
+		Alerts []alert `json:"alerts"`
+	}{node, alerts})
+}
+
+// telegramNotifier sends alerts as messages of a Telegram bot to a chat.
+type telegramNotifier struct {
+	botToken   string
+	chatID     string
+	httpClient *http.Client
+}
+
+func (n *telegramNotifier) notify(ctx context.Context, a alert) error {
+	return n.send(ctx, a.text())
+}
+
+func (n *telegramNotifier) notifyGroup(ctx context.Context, node string, alerts []alert) error {
+	return n.send(ctx, groupText(node, alerts))
+}
+
+func (n *telegramNotifier) send(ctx context.Context, text string) error {
+	msg := struct {
+		ChatID string `json:"chat_id"`
+		Text   string `json:"text"`
+	}{n.chatID, text}
+	err := postJSON(ctx, n.httpClient, "https://api.telegram.org/bot"+n.botToken+"/sendMessage", msg)
+	// The bot token is part of the URL, keep it out of the logs.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// discordNotifier sends alerts to a Discord channel via a webhook.
+type discordNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (n *discordNotifier) notify(ctx context.Context, a alert) error {
+	return n.send(ctx, a.text())
+}
+
+func (n *discordNotifier) notifyGroup(ctx context.Context, node string, alerts []alert) error {
+	return n.send(ctx, groupText(node, alerts))
+}
+
+func (n *discordNotifier) send(ctx context.Context, text string) error {
+	msg := struct {
+		Content string `json:"content"`
+	}{text}
+	err := postJSON(ctx, n.httpClient, n.url, msg)
+	// The webhook URL contains its token as well.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// pagerDutyNotifier triggers and resolves incidents via the PagerDuty
+// Events API v2. The alert key is used as dedup key, so a resolve closes
+// the incident its trigger opened.
+type pagerDutyNotifier struct {
+	routingKey string
+	httpClient *http.Client
+}
+
+func (n *pagerDutyNotifier) notify(ctx context.Context, a alert) error {
+	type payload struct {
+		Summary       string            `json:"summary"`
+		Source        string            `json:"source"`
+		Severity      string            `json:"severity"`
+		Component     string            `json:"component"`
+		Timestamp     time.Time         `json:"timestamp"`
+		CustomDetails map[string]string `json:"custom_details"`
+	}
+	event := struct {
+		RoutingKey  string   `json:"routing_key"`
+		EventAction string   `json:"event_action"`
+		DedupKey    string   `json:"dedup_key"`
+		Payload     *payload `json:"payload,omitempty"`
+	}{RoutingKey: n.routingKey, EventAction: "trigger", DedupKey: a.key()}
+	if a.Status == alertResolved {
+		event.EventAction = "resolve"
+	} else {
+		event.Payload = &payload{
+			Summary:       truncate(a.Summary, 1024),
+			Source:        a.Labels["node"],
+			Severity:      a.Severity, // warning and critical are PagerDuty severities as well
+			Component:     a.Rule,
+			Timestamp:     a.StartsAt,
+			CustomDetails: a.Labels,
+		}
+	}
+	return postJSON(ctx, n.httpClient, "https://events.pagerduty.com/v2/enqueue", event)
+}
+
+// opsgenieNotifier creates and closes Opsgenie alerts, using the alert key
+// as alias for deduplication.
+type opsgenieNotifier struct {
+	apiURL     string
+	apiKey     string
+	httpClient *http.Client
+}
+
+var opsgeniePriorities = map[string]string{
+	severityCritical: "P1",
+	severityWarning:  "P3",
+}
+
+func (n *opsgenieNotifier) notify(ctx context.Context, a alert) error {
+	alias := truncate(a.key(), 512)
+	if a.Status == alertResolved {
+		body := struct {
+			Source string `json:"source"`
+			Note   string `json:"note"`
+		}{"celestia-exporter", "resolved: " + a.Summary}
+		u := n.apiURL + "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		return n.post(ctx, u, body)
+	}
+
+	tags := []string{"celestia", a.Rule, a.Severity}
+	body := struct {
+		Message     string            `json:"message"`
+		Alias       string            `json:"alias"`
+		Description string            `json:"description"`
+		Priority    string            `json:"priority"`
+		Source      string            `json:"source"`
+		Tags        []string          `json:"tags"`
+		Details     map[string]string `json:"details"`
+	}{
+		Message:     truncate(a.Summary, 130),
+		Alias:       alias,
+		Description: a.Summary,
+		Priority:    opsgeniePriorities[a.Severity],
+		Source:      "celestia-exporter",
+		Tags:        tags,
+		Details:     a.Labels,
+	}
+	return n.post(ctx, n.apiURL+"/v2/alerts", body)
+}
+
+func (n *opsgenieNotifier) post(ctx context.Context, url string, v interface{}) error {
+	return postJSONWithHeader(ctx, n.httpClient, url, v, "Authorization", "GenieKey "+n.apiKey)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	return postJSONWithHeader(ctx, client, url, v, "", "")
+}
+
+// postJSONWithHeader posts v as JSON, with an additional header unless key
+// is empty.
+func postJSONWithHeader(ctx context.Context, client *http.Client, url string, v interface{}, key, value string) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The service name of the exporter's resource, unless set otherwise, and
+// the instrumentation scope, the module path, of its metrics and spans.
+const (
+	otlpServiceName = "celestia-exporter"
+	otlpScopeName   = "my-celestia-exporter"
+)
+
+// otlpSink pushes metrics to an OpenTelemetry collector using OTLP over
+// HTTP with the JSON encoding, which needs no OpenTelemetry dependencies.
+type otlpSink struct {
+	url        string
+	headers    map[string]string
+	resource   []otlpKeyValue
+	httpClient *http.Client
+	start      time.Time
+}
+
+// newOTLPSink returns a sink for endpoint, e.g. http://collector:4318. The
+// metrics path /v1/metrics is appended unless endpoint has a path.
+func newOTLPSink(endpoint string, headers, attributes map[string]string) (*otlpSink, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+
+	if _, ok := attributes["service.name"]; !ok {
+		attributes["service.name"] = otlpServiceName
+	}
+	var resource []otlpKeyValue
+	for _, k := range sortedKeys(attributes) {
+		resource = append(resource, otlpAttribute(k, attributes[k]))
+	}
+	return &otlpSink{
+		url:        u.String(),
+		headers:    headers,
+		resource:   resource,
+		httpClient: &http.Client{},
+		start:      time.Now(),
+	}, nil
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func otlpAttribute(k, v string) otlpKeyValue {
+	kv := otlpKeyValue{Key: k}
+	kv.Value.StringValue = v
+	return kv
+}
+
+// The types below mirror the JSON mapping of the OTLP metrics protobuf
+// messages. 64 bit integers are encoded as strings.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE, Prometheus counters
+// and histograms count from process start.
+const otlpCumulative = 2
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpNumberPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryPoint `json:"dataPoints"`
+}
+
+type otlpSummaryPoint struct {
+	Attributes        []otlpKeyValue     `json:"attributes,omitempty"`
+	StartTimeUnixNano string             `json:"startTimeUnixNano"`
+	TimeUnixNano      string             `json:"timeUnixNano"`
+	Count             string             `json:"count"`
+	Sum               float64            `json:"sum"`
+	QuantileValues    []otlpQuantileItem `json:"quantileValues"`
+}
+
+type otlpQuantileItem struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+func (s *otlpSink) push(ctx context.Context, families []*dto.MetricFamily) error {
+	body, err := json.Marshal(s.convert(families, time.Now()))
+	if err != nil {
+		return fmt.Errorf("encoding metrics: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (s *otlpSink) convert(families []*dto.MetricFamily, now time.Time) otlpRequest {
+	nowNano := strconv.FormatInt(now.UnixNano(), 10)
+	startNano := strconv.FormatInt(s.start.UnixNano(), 10)
+
+	var metrics []otlpMetric
+	for _, mf := range families {
+		m := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			m.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+			for _, pm := range mf.Metric {
+				m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberPoint{
+					Attributes: otlpLabels(pm), StartTimeUnixNano: startNano, TimeUnixNano: nowNano,
+					AsDouble: pm.GetCounter().GetValue(),
+				})
+			}
+		case dto.MetricType_HISTOGRAM:
+			m.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+			for _, pm := range mf.Metric {
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints, otlpHistogramFrom(pm, startNano, nowNano))
+			}
+		case dto.MetricType_SUMMARY:
+			m.Summary = &otlpSummary{}
+			for _, pm := range mf.Metric {
+				p := otlpSummaryPoint{
+					Attributes: otlpLabels(pm), StartTimeUnixNano: startNano, TimeUnixNano: nowNano,
+					Count: strconv.FormatUint(pm.GetSummary().GetSampleCount(), 10),
+					Sum:   pm.GetSummary().GetSampleSum(),
+				}
+				for _, q := range pm.GetSummary().GetQuantile() {
+					p.QuantileValues = append(p.QuantileValues, otlpQuantileItem{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, p)
+			}
+		default:
+			// Gauges and untyped metrics.
+			m.Gauge = &otlpGauge{}
+			for _, pm := range mf.Metric {
+				v := pm.GetGauge().GetValue()
+				if pm.Untyped != nil {
+					v = pm.GetUntyped().GetValue()
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberPoint{
+					Attributes: otlpLabels(pm), TimeUnixNano: nowNano, AsDouble: v,
+				})
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	rm := otlpResourceMetrics{ScopeMetrics: []otlpScopeMetrics{{Metrics: metrics}}}
+	rm.Resource.Attributes = s.resource
+	rm.ScopeMetrics[0].Scope.Name = otlpScopeName
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{rm}}
+}
+
+// otlpHistogramFrom converts the cumulative Prometheus buckets into the
+// per-bucket counts of OTLP, with the +Inf bucket implied by count.
+func otlpHistogramFrom(pm *dto.Metric, startNano, nowNano string) otlpHistogramPoint {
+	h := pm.GetHistogram()
+	p := otlpHistogramPoint{
+		Attributes: otlpLabels(pm), StartTimeUnixNano: startNano, TimeUnixNano: nowNano,
+		Count: strconv.FormatUint(h.GetSampleCount(), 10),
+		Sum:   h.GetSampleSum(),
+	}
+	var prev uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+		p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-prev, 10))
+		prev = b.GetCumulativeCount()
+	}
+	p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-prev, 10))
+	return p
+}
+
+func otlpLabels(pm *dto.Metric) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(pm.Label))
+	for _, l := range pm.Label {
+		kvs = append(kvs, otlpAttribute(l.GetName(), l.GetValue()))
+	}
+	return kvs
+}
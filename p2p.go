@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// p2pMetrics are the P2P connectivity metrics of one node type, named
+// <node type>_p2p_peers etc.
+type p2pMetrics struct {
+	peers                  *prometheus.GaugeVec
+	bandwidthBytes         *prometheus.GaugeVec
+	bandwidthRate          *prometheus.GaugeVec
+	protocolBandwidthBytes *prometheus.GaugeVec
+	protocolBandwidthRate  *prometheus.GaugeVec
+	natReachability        *prometheus.GaugeVec
+	peerConnections        *prometheus.CounterVec
+	peerDisconnections     *prometheus.CounterVec
+	peersByCountry         *prometheus.GaugeVec
+	peersByASN             *prometheus.GaugeVec
+	topASNShare            *prometheus.GaugeVec
+}
+
+func newP2PMetrics(namespace string) *p2pMetrics {
+	m := &p2pMetrics{
+		peers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "peers",
+			Help:      "Number of peers the node is connected to",
+		}, targetLabels),
+		bandwidthBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "bandwidth_bytes",
+			Help:      "Total bytes transferred by the node since it started, by direction",
+		}, append(targetLabels, "direction")),
+		bandwidthRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "bandwidth_rate_bytes_per_second",
+			Help:      "Current transfer rate of the node, by direction",
+		}, append(targetLabels, "direction")),
+		protocolBandwidthBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "protocol_bandwidth_bytes",
+			Help:      "Total bytes transferred for a libp2p protocol since the node started, by direction",
+		}, append(targetLabels, "protocol", "direction")),
+		protocolBandwidthRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "protocol_bandwidth_rate_bytes_per_second",
+			Help:      "Current transfer rate for a libp2p protocol, by direction",
+		}, append(targetLabels, "protocol", "direction")),
+		natReachability: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "nat_reachability",
+			Help:      "NAT reachability of the node: 0 unknown, 1 public, 2 private",
+		}, targetLabels),
+		peerConnections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "peer_connections_total",
+			Help:      "Number of peers seen connecting between two runs of the collector",
+		}, targetLabels),
+		peerDisconnections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "peer_disconnections_total",
+			Help:      "Number of peers seen disconnecting between two runs of the collector",
+		}, targetLabels),
+		peersByCountry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "peers_by_country",
+			Help:      "Number of connected peers by the country of their address, with --p2p.geoip.country-db",
+		}, append(targetLabels, "country")),
+		peersByASN: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "peers_by_asn",
+			Help:      "Number of connected peers by the autonomous system of their address, with --p2p.geoip.asn-db",
+		}, append(targetLabels, "asn", "as_org")),
+		topASNShare: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "p2p",
+			Name:      "peers_top_asn_share",
+			Help:      "Fraction of the connected peers in the autonomous system with the most peers, with --p2p.geoip.asn-db",
+		}, targetLabels),
+	}
+	addTargetMetrics(m.peers, m.bandwidthBytes, m.bandwidthRate,
+		m.protocolBandwidthBytes, m.protocolBandwidthRate, m.natReachability,
+		m.peerConnections, m.peerDisconnections, m.peersByCountry, m.peersByASN, m.topASNShare)
+	return m
+}
+
+var p2pMetricsByType = map[string]*p2pMetrics{
+	nodeTypeBridge: newP2PMetrics(nodeTypeBridge),
+	nodeTypeFull:   newP2PMetrics(nodeTypeFull),
+	nodeTypeLight:  newP2PMetrics(nodeTypeLight),
+}
+
+func init() {
+	registerCollector("p2p", newP2PCollector)
+	requirePermission("p2p", "admin")
+}
+
+// p2pCollector exports the peer count and churn, bandwidth usage and NAT
+// status and, with MaxMind databases, where the peers are.
+type p2pCollector struct {
+	client *celestiarpc.Client
+	target target
+
+	// peers are the IDs of the peers of the last run, nil before the
+	// first one.
+	peers map[string]bool
+}
+
+func newP2PCollector(client *celestiarpc.Client, t target) Collector {
+	return &p2pCollector{client: client, target: t}
+}
+
+func (c *p2pCollector) Name() string { return "p2p" }
+
+func (c *p2pCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	m := p2pMetricsByType[t.NodeType]
+	var errs []error
+
+	peers, err := client.Peers(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		m.peers.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(len(peers)))
+		c.countChurn(m, peers)
+		if peerGeoDB != nil {
+			if err := c.collectGeo(ctx, m, peers); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	stats, err := client.BandwidthStats(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		m.bandwidthBytes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "in").Set(float64(stats.TotalIn))
+		m.bandwidthBytes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "out").Set(float64(stats.TotalOut))
+		m.bandwidthRate.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "in").Set(stats.RateIn)
+		m.bandwidthRate.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "out").Set(stats.RateOut)
+	}
+
+	for _, protocol := range t.P2PProtocols {
+		stats, err := client.BandwidthForProtocol(ctx, protocol)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w (protocol %s)", err, protocol))
+			continue
+		}
+		m.protocolBandwidthBytes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, protocol, "in").Set(float64(stats.TotalIn))
+		m.protocolBandwidthBytes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, protocol, "out").Set(float64(stats.TotalOut))
+		m.protocolBandwidthRate.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, protocol, "in").Set(stats.RateIn)
+		m.protocolBandwidthRate.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, protocol, "out").Set(stats.RateOut)
+	}
+
+	nat, err := client.NATStatus(ctx)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		m.natReachability.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(nat))
+	}
+	return joinErrors(errs)
+}
+
+// countChurn counts the peers that connected and disconnected since the
+// last run. Peers that come and go within one scrape interval are missed.
+func (c *p2pCollector) countChurn(m *p2pMetrics, peers []string) {
+	t := c.target
+	current := make(map[string]bool, len(peers))
+	for _, id := range peers {
+		current[id] = true
+	}
+	connections := m.peerConnections.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork)
+	disconnections := m.peerDisconnections.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork)
+	if c.peers != nil {
+		for id := range current {
+			if !c.peers[id] {
+				connections.Inc()
+			}
+		}
+		for id := range c.peers {
+			if !current[id] {
+				disconnections.Inc()
+			}
+		}
+	}
+	c.peers = current
+}
+
+// collectGeo counts the peers by country and autonomous system. Peers with
+// only private addresses count as unknown.
+func (c *p2pCollector) collectGeo(ctx context.Context, m *p2pMetrics, peers []string) error {
+	t := c.target
+	infos, err := c.client.PeerInfos(ctx, peers)
+	if err != nil {
+		return err
+	}
+	countries := make(map[string]int)
+	type as struct{ number, org string }
+	systems := make(map[as]int)
+	for _, info := range infos {
+		country, asn, org := "unknown", "unknown", "unknown"
+		if ip := peerIP(ctx, info.Addrs); ip != nil {
+			country, asn, org = peerGeoDB.lookup(ip)
+		}
+		countries[country]++
+		systems[as{asn, org}]++
+	}
+
+	// Drop the series of countries and systems without peers left.
+	m.peersByCountry.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	m.peersByASN.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	if peerGeoDB.country != nil {
+		for country, n := range countries {
+			m.peersByCountry.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, country).Set(float64(n))
+		}
+	}
+	if peerGeoDB.asn != nil {
+		top := 0
+		for system, n := range systems {
+			m.peersByASN.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, system.number, system.org).Set(float64(n))
+			if system.number != "unknown" && n > top {
+				top = n
+			}
+		}
+		share := 0.0
+		if len(infos) > 0 {
+			share = float64(top) / float64(len(infos))
+		}
+		m.topASNShare.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(share)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package celestiarpc
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrUnauthorized is returned, wrapped, when the node rejects the request
+// with HTTP 401 or 403.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// TokenSource supplies the auth token sent with each request. Token is
+// called for every request, so implementations can pick up rotated tokens,
+// with the context of the request.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// RefreshableTokenSource is a TokenSource that can be told its token was
+// rejected. The client calls Refresh and retries the request once before
+// returning ErrUnauthorized.
+type RefreshableTokenSource interface {
+	TokenSource
+	Refresh()
+}
+
+// StaticToken is a TokenSource that always returns the same token.
+type StaticToken string
+
+// Token implements TokenSource.
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// WithTokenSource makes the client ask ts for the auth token of every
+// request, replacing the token passed to New.
+func WithTokenSource(ts TokenSource) Option {
+	return func(c *Client) {
+		c.tokens = ts
+	}
+}
+
+// WithAuthFailureHandler registers fn to be called whenever the node rejects
+// the auth token of a request to method, including requests that succeed
+// after a refresh.
+func WithAuthFailureHandler(fn func(method string)) Option {
+	return func(c *Client) {
+		c.onAuthFailure = fn
+	}
+}
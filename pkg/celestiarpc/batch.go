@@ -0,0 +1,199 @@
+package celestiarpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds of the period during which a node that rejected a batch request is
+// sent the calls one by one, before batching is tried again. The period
+// doubles with every rejection in a row.
+const (
+	minBatchBackoff = time.Minute
+	maxBatchBackoff = time.Hour
+)
+
+// BatchCall is one call of a Batch. Err is set by Batch if the call failed.
+type BatchCall struct {
+	Method string
+	Params []interface{}
+	// Result receives the decoded result, it may be nil.
+	Result interface{}
+	Err    error
+}
+
+// Batch sends calls as a single JSON-RPC 2.0 batch request, so that all
+// results come from one round trip. The error of every call is set in its
+// Err field; Batch returns the first of them. Nodes that reject batches are
+// sent the calls one by one for a while, see minBatchBackoff.
+func (c *Client) Batch(ctx context.Context, calls ...*BatchCall) error {
+	batched := time.Now().UnixNano() >= atomic.LoadInt64(&c.noBatchUntil)
+	if !batched || !c.batch(ctx, calls) {
+		anyOK := false
+		for _, call := range calls {
+			call.Err = c.Call(ctx, call.Method, call.Result, call.Params...)
+			anyOK = anyOK || call.Err == nil
+		}
+		if batched && anyOK {
+			// The node works, it just doesn't understand batches.
+			c.backOffBatch()
+		}
+	}
+	for _, call := range calls {
+		if call.Err != nil {
+			return call.Err
+		}
+	}
+	return nil
+}
+
+// backOffBatch stops batching for twice the previous period, within
+// minBatchBackoff and maxBatchBackoff.
+func (c *Client) backOffBatch() {
+	backoff := 2 * time.Duration(atomic.LoadInt64(&c.batchBackoff))
+	if backoff < minBatchBackoff {
+		backoff = minBatchBackoff
+	}
+	if backoff > maxBatchBackoff {
+		backoff = maxBatchBackoff
+	}
+	atomic.StoreInt64(&c.batchBackoff, int64(backoff))
+	atomic.StoreInt64(&c.noBatchUntil, time.Now().Add(backoff).UnixNano())
+}
+
+// batch sends calls as one batch request. It returns false without setting
+// any results if the node rejected the batch as such.
+func (c *Client) batch(ctx context.Context, calls []*BatchCall) bool {
+	start := time.Now()
+	report := func(status, attempts int) {
+		if c.onRequest == nil {
+			return
+		}
+		for _, call := range calls {
+			c.onRequest(RequestInfo{Method: call.Method, StatusCode: status, Duration: time.Since(start), Attempts: attempts, Err: call.Err})
+		}
+	}
+	fail := func(err error) {
+		for _, call := range calls {
+			call.Err = fmt.Errorf("%s: %w", call.Method, err)
+		}
+	}
+
+	if c.breaker != nil && !c.breaker.allow() {
+		fail(ErrCircuitOpen)
+		report(0, 0)
+		return true
+	}
+
+	methods := make([]string, len(calls))
+	reqs := make([]request, len(calls))
+	byID := make(map[uint64]*BatchCall, len(calls))
+	for i, call := range calls {
+		params := call.Params
+		if params == nil {
+			params = []interface{}{}
+		}
+		id := atomic.AddUint64(&c.nextID, 1)
+		reqs[i] = request{JSONRPC: "2.0", ID: id, Method: call.Method, Params: params}
+		byID[id] = call
+		methods[i] = call.Method
+	}
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		fail(fmt.Errorf("encoding request: %w", err))
+		report(0, 0)
+		return true
+	}
+
+	var attempts int
+	respBytes, status, err := c.send(ctx, strings.Join(methods, ","), body, retryable(methods...), &attempts)
+	if c.breaker != nil {
+		c.breaker.done(err != nil && unreachable(ctx, status))
+	}
+	var se *statusError
+	if errors.As(err, &se) && (se.code == http.StatusBadRequest || se.code == http.StatusUnsupportedMediaType) {
+		// Possibly a server without batch support refusing the array.
+		// Other errors, like a transient 500, are the node's and don't
+		// tell anything about its batch support.
+		return false
+	}
+	if err != nil {
+		fail(err)
+		report(status, attempts)
+		return true
+	}
+
+	var resps []response
+	if err := json.Unmarshal(respBytes, &resps); err != nil {
+		// A server without batch support answers with a single error
+		// object.
+		var single response
+		if json.Unmarshal(respBytes, &single) == nil && single.Error != nil {
+			return false
+		}
+		fail(&DecodeError{Method: "batch", What: "response", Err: err})
+		report(status, attempts)
+		return true
+	}
+	// The node understands batches, a later rejection starts over at
+	// minBatchBackoff.
+	atomic.StoreInt64(&c.batchBackoff, 0)
+	for i := range resps {
+		if call, ok := byID[resps[i].ID]; ok {
+			call.Err = resps[i].decode(call.Method, call.Result)
+			delete(byID, resps[i].ID)
+		}
+	}
+	for _, call := range byID {
+		call.Err = &DecodeError{Method: call.Method, What: "response", Err: errors.New("missing from batch response")}
+	}
+	report(status, attempts)
+	return true
+}
+
+// Heads returns the local and the network head from a single batch request,
+// so that both are sampled at the same time.
+func (c *Client) Heads(ctx context.Context) (local, network *ExtendedHeader, err error) {
+	local, network = &ExtendedHeader{}, &ExtendedHeader{}
+	err = c.Batch(ctx,
+		&BatchCall{Method: "header.LocalHead", Result: local},
+		&BatchCall{Method: "header.NetworkHead", Result: network},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return local, network, nil
+}
+
+// PeerInfos returns the addresses of the connected peers with the given IDs
+// from a single batch request (p2p.PeerInfo). Peers that disconnected in
+// the meantime are left out.
+func (c *Client) PeerInfos(ctx context.Context, ids []string) ([]PeerInfo, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	infos := make([]PeerInfo, len(ids))
+	calls := make([]*BatchCall, len(ids))
+	for i, id := range ids {
+		calls[i] = &BatchCall{Method: "p2p.PeerInfo", Params: []interface{}{id}, Result: &infos[i]}
+	}
+	err := c.Batch(ctx, calls...)
+	var found []PeerInfo
+	for i, call := range calls {
+		if call.Err == nil {
+			found = append(found, infos[i])
+		}
+	}
+	// Only fail if no peer could be looked up, a peer may just have
+	// disconnected.
+	if len(found) == 0 && err != nil {
+		return nil, err
+	}
+	return found, nil
+}
@@ -0,0 +1,183 @@
+package celestiarpc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Share layout and commitment parameters of celestia-app for share
+// version 0.
+const (
+	NamespaceSize = 29
+	ShareSize     = 512
+
+	shareInfoSize        = 1
+	sequenceLenSize      = 4
+	firstShareCapacity   = ShareSize - NamespaceSize - shareInfoSize - sequenceLenSize
+	continuationCapacity = ShareSize - NamespaceSize - shareInfoSize
+	subtreeRootThreshold = 64
+
+	// Gas parameters of the default gas estimate of PayForBlobs
+	// transactions.
+	gasPerBlobByte    = 8
+	txSizeCostPerByte = 10
+	bytesPerBlobInfo  = 70
+	pfbGasFixedCost   = 75000
+)
+
+// NewBlob returns a share version 0 blob of data under namespace, a full
+// 29 byte namespace, with its commitment, so that it can be fetched with
+// blob.Get once submitted with blob.Submit, which only returns the height.
+func NewBlob(namespace, data []byte) (*Blob, error) {
+	if len(namespace) != NamespaceSize {
+		return nil, fmt.Errorf("namespace must be %d bytes, got %d", NamespaceSize, len(namespace))
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("blob data must not be empty")
+	}
+	b := &Blob{Namespace: namespace, Data: data}
+	b.Commitment = commitment(namespace, splitShares(namespace, data))
+	return b, nil
+}
+
+// EstimateGas returns the gas celestia-node's default options of
+// blob.Submit allow a PayForBlobs transaction of blobs.
+func EstimateGas(blobs ...*Blob) uint64 {
+	gas := uint64(pfbGasFixedCost)
+	for _, b := range blobs {
+		gas += uint64(sharesNeeded(len(b.Data))) * ShareSize * gasPerBlobByte
+		gas += txSizeCostPerByte * bytesPerBlobInfo
+	}
+	return gas
+}
+
+// sharesNeeded returns the number of sparse shares that hold n bytes.
+func sharesNeeded(n int) int {
+	if n <= firstShareCapacity {
+		return 1
+	}
+	return 1 + (n-firstShareCapacity+continuationCapacity-1)/continuationCapacity
+}
+
+// splitShares splits data into sparse shares: the namespace, the info byte
+// with the share version and whether the share starts the blob, the blob
+// length in the first share and the data, padded with zeros.
+func splitShares(namespace, data []byte) [][]byte {
+	shares := make([][]byte, 0, sharesNeeded(len(data)))
+	for first := true; first || len(data) > 0; first = false {
+		share := make([]byte, 0, ShareSize)
+		share = append(share, namespace...)
+		capacity := continuationCapacity
+		if first {
+			share = append(share, 1)
+			share = binary.BigEndian.AppendUint32(share, uint32(len(data)))
+			capacity = firstShareCapacity
+		} else {
+			share = append(share, 0)
+		}
+		n := len(data)
+		if n > capacity {
+			n = capacity
+		}
+		share = append(share, data[:n]...)
+		data = data[n:]
+		shares = append(shares, share[:ShareSize])
+	}
+	return shares
+}
+
+// commitment returns the share commitment of the shares of a blob: the
+// Merkle root of the namespaced Merkle tree roots of the subtrees the
+// shares are split into, as in celestia-app's CreateCommitment.
+func commitment(namespace []byte, shares [][]byte) []byte {
+	width := subtreeWidth(len(shares))
+	var roots [][]byte
+	for len(shares) > 0 {
+		size := width
+		if len(shares) < width {
+			size = 1 << (bits.Len(uint(len(shares))) - 1)
+		}
+		leaves := make([][]byte, size)
+		for i, share := range shares[:size] {
+			leaves[i] = nmtLeaf(namespace, share)
+		}
+		roots = append(roots, nmtRoot(leaves))
+		shares = shares[size:]
+	}
+	return merkleRoot(roots)
+}
+
+// subtreeWidth returns the maximum number of shares under one subtree
+// root of a blob of n shares.
+func subtreeWidth(n int) int {
+	s := (n + subtreeRootThreshold - 1) / subtreeRootThreshold
+	w := roundUpPowerOfTwo(s)
+	// The blob fits in a square of the smallest size, no subtree needs to
+	// be wider than its rows.
+	square := 1
+	for square*square < n {
+		square++
+	}
+	if rows := roundUpPowerOfTwo(square); rows < w {
+		return rows
+	}
+	return w
+}
+
+func roundUpPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// nmtLeaf hashes a share into a namespaced Merkle tree leaf: its
+// namespace as the minimum and maximum, and the hash of the share
+// prefixed with the namespace.
+func nmtLeaf(namespace, share []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(namespace)
+	h.Write(share)
+	return append(append(append([]byte{}, namespace...), namespace...), h.Sum(nil)...)
+}
+
+// nmtRoot returns the root of the namespaced Merkle tree of leaves of the
+// same namespace, split like an RFC 6962 tree.
+func nmtRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	left, right := nmtRoot(leaves[:k]), nmtRoot(leaves[k:])
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	// All leaves share the namespace, so it is the minimum and maximum.
+	return append(append([]byte{}, left[:2*NamespaceSize]...), h.Sum(nil)...)
+}
+
+// merkleRoot returns the RFC 6962 Merkle root of items, like CometBFT's
+// merkle.HashFromByteSlices.
+func merkleRoot(items [][]byte) []byte {
+	switch len(items) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		sum := sha256.Sum256(append([]byte{0}, items[0]...))
+		return sum[:]
+	}
+	k := splitPoint(len(items))
+	sum := sha256.Sum256(append(append([]byte{1}, merkleRoot(items[:k])...), merkleRoot(items[k:])...))
+	return sum[:]
+}
+
+// splitPoint returns the largest power of two less than n, n > 1.
+func splitPoint(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
@@ -0,0 +1,144 @@
+package celestiarpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped, for requests rejected without
+// contacting the node because its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through, which decides
+	// whether the circuit closes or opens again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops requests to a node that keeps failing. It opens after
+// threshold consecutive transient failures and, after openDuration, lets a
+// probe request through to check whether the node has recovered.
+type CircuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+	onChange     func(CircuitState)
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a closed circuit breaker. onChange, if not nil,
+// is called with the new state on every state change.
+func NewCircuitBreaker(threshold int, openDuration time.Duration, onChange func(CircuitState)) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, openDuration: openDuration, onChange: onChange}
+}
+
+// WithCircuitBreaker makes the client send its requests through cb.
+func WithCircuitBreaker(cb *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = cb
+	}
+}
+
+// State returns the current state.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// allow reports whether a request may be sent. It must be followed by a
+// call to done if it returns true.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.openDuration {
+			return false
+		}
+		cb.setState(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.probing {
+			return false
+		}
+		cb.probing = true
+	}
+	return true
+}
+
+// done records the outcome of an allowed request.
+func (cb *CircuitBreaker) done(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == CircuitHalfOpen {
+		cb.probing = false
+		if failed {
+			cb.open()
+		} else {
+			cb.failures = 0
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+
+	if !failed {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.state == CircuitClosed && cb.failures >= cb.threshold {
+		cb.open()
+	}
+}
+
+func (cb *CircuitBreaker) open() {
+	cb.openedAt = time.Now()
+	cb.setState(CircuitOpen)
+}
+
+func (cb *CircuitBreaker) setState(s CircuitState) {
+	if cb.state == s {
+		return
+	}
+	cb.state = s
+	if cb.onChange != nil {
+		cb.onChange(s)
+	}
+}
+
+// unreachable reports whether a failed request means the node is down or
+// overloaded: no response was received, or a 429 or 5xx one. Requests
+// cancelled by the caller don't count.
+func unreachable(ctx context.Context, status int) bool {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
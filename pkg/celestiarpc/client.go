@@ -0,0 +1,256 @@
+// Package celestiarpc is a small client for the JSON-RPC API of
+// celestia-node (bridge, full and light nodes).
+package celestiarpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Client calls JSON-RPC methods on a celestia-node endpoint, or with
+// failover on one of the endpoints of a node.
+type Client struct {
+	endpoint   string
+	tokens     TokenSource
+	httpClient *http.Client
+	nextID     uint64
+
+	retry    RetryPolicy
+	breaker  *CircuitBreaker
+	limiter  *Limiter
+	failover *Failover
+	// noBatchUntil is the time, in Unix nanoseconds, until which calls
+	// are sent one by one after the node rejected a batch request, and
+	// batchBackoff the last such period.
+	noBatchUntil  int64
+	batchBackoff  int64
+	onAuthFailure func(method string)
+	onRequest     func(RequestInfo)
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used for requests. By default
+// http.DefaultClient is used.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// RequestInfo describes a finished request, see WithRequestHook.
+type RequestInfo struct {
+	Method string
+	// StatusCode is the HTTP status of the response, or 0 if the request
+	// failed before a response was received.
+	StatusCode int
+	Duration   time.Duration
+	// Attempts is the number of HTTP requests made, more than 1 if the
+	// request was retried.
+	Attempts int
+	// Err is the error returned to the caller, including JSON-RPC and
+	// decoding errors of successful HTTP requests.
+	Err error
+}
+
+// WithRequestHook registers fn to be called after every request, for
+// example to record metrics.
+func WithRequestHook(fn func(RequestInfo)) Option {
+	return func(c *Client) {
+		c.onRequest = fn
+	}
+}
+
+// New returns a client for endpoint, authenticating with authToken if it is
+// not empty.
+func New(endpoint, authToken string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:   endpoint,
+		tokens:     StaticToken(authToken),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Endpoint returns the endpoint the client talks to, the primary one with
+// failover.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// setAuthHeader adds the bearer token, if any, to h.
+func (c *Client) setAuthHeader(ctx context.Context, h http.Header) error {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("getting auth token: %w", err)
+	}
+	if token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Call invokes method with params and decodes the result into result, which
+// may be nil if the result is not needed.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	start := time.Now()
+	if c.breaker != nil && !c.breaker.allow() {
+		err := fmt.Errorf("%s: %w", method, ErrCircuitOpen)
+		if c.onRequest != nil {
+			c.onRequest(RequestInfo{Method: method, Err: err})
+		}
+		return err
+	}
+
+	var attempts int
+	status, err := c.call(ctx, method, result, params, &attempts)
+	if c.breaker != nil {
+		// Only failures to get a response count, a node answering with an
+		// error is up.
+		c.breaker.done(err != nil && unreachable(ctx, status))
+	}
+	if c.onRequest != nil {
+		c.onRequest(RequestInfo{Method: method, StatusCode: status, Duration: time.Since(start), Attempts: attempts, Err: err})
+	}
+	return err
+}
+
+func (c *Client) call(ctx context.Context, method string, result interface{}, params []interface{}, attempts *int) (int, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	reqBytes, err := json.Marshal(request{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.nextID, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: encoding request: %w", method, err)
+	}
+
+	respBytes, status, err := c.send(ctx, method, reqBytes, retryable(method), attempts)
+	if err != nil {
+		return status, fmt.Errorf("%s: %w", method, err)
+	}
+
+	var rpcResp response
+	if err := json.Unmarshal(respBytes, &rpcResp); err != nil {
+		return status, &DecodeError{Method: method, What: "response", Err: err}
+	}
+	return status, rpcResp.decode(method, result)
+}
+
+// send posts a request body, retrying transient failures if retry is set
+// and refreshing the auth token if it is rejected. A rejected request was
+// not processed, so it is always sent again with the new token. method is
+// only used for reporting auth failures.
+func (c *Client) send(ctx context.Context, method string, body []byte, retry bool, attempts *int) ([]byte, int, error) {
+	respBytes, status, n, err := c.postRetrying(ctx, body, retry)
+	*attempts += n
+	if errors.Is(err, ErrUnauthorized) {
+		if c.onAuthFailure != nil {
+			c.onAuthFailure(method)
+		}
+		if rts, ok := c.tokens.(RefreshableTokenSource); ok {
+			rts.Refresh()
+			respBytes, status, n, err = c.postRetrying(ctx, body, retry)
+			*attempts += n
+		}
+	}
+	return respBytes, status, err
+}
+
+// decode returns the error of the response, or decodes its result into
+// result unless it is nil.
+func (r *response) decode(method string, result interface{}) error {
+	if r.Error != nil {
+		return &RPCError{Method: method, Code: r.Error.Code, Message: r.Error.Message}
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(r.Result, result); err != nil {
+		return &DecodeError{Method: method, What: "result", Err: err}
+	}
+	return nil
+}
+
+// post sends a request body to the endpoint, or with failover to the
+// endpoint in use, and returns the response body and HTTP status code,
+// which is 0 if no response was received.
+func (c *Client) post(ctx context.Context, body []byte) ([]byte, int, error) {
+	if c.limiter != nil {
+		release, err := c.limiter.acquire(ctx)
+		if err != nil {
+			return nil, 0, fmt.Errorf("waiting for rate limit: %w", err)
+		}
+		defer release()
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if err := c.setAuthHeader(ctx, header); err != nil {
+		return nil, 0, err
+	}
+	if c.failover != nil {
+		return c.postFailover(ctx, header, body)
+	}
+	return c.postTo(ctx, c.endpoint, header, body)
+}
+
+// postTo sends a request body with header to endpoint.
+func (c *Client) postTo(ctx context.Context, endpoint string, header http.Header, body []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header = header.Clone()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, resp.StatusCode, fmt.Errorf("%w: HTTP status %s", ErrUnauthorized, resp.Status)
+	default:
+		return nil, resp.StatusCode, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("reading response body: %w", err)
+	}
+	return respBytes, resp.StatusCode, nil
+}
@@ -0,0 +1,122 @@
+package celestiarpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureNode answers every JSON-RPC method in results with its result and
+// all others with the method not found error of go-jsonrpc.
+func fixtureNode(t *testing.T, results map[string]json.RawMessage) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if result, ok := results[req.Method]; ok {
+			resp["result"] = result
+		} else {
+			resp["error"] = map[string]interface{}{"code": CodeMethodNotFound, "message": "method '" + req.Method + "' not found"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL, "")
+}
+
+func readFixture(t *testing.T, name string) json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeFixtures(t *testing.T) {
+	c := fixtureNode(t, map[string]json.RawMessage{
+		"header.LocalHead":  readFixture(t, "extended_header.json"),
+		"das.SamplingStats": readFixture(t, "sampling_stats.json"),
+		"state.Balance":     readFixture(t, "balance.json"),
+	})
+	ctx := context.Background()
+
+	h, err := c.LocalHead(ctx)
+	if err != nil {
+		t.Fatalf("LocalHead: %v", err)
+	}
+	if h.Height() != 412873 || h.Header.ChainID != "blockspacerace-0" || h.Header.Time.Unix() != 1683192096 {
+		t.Errorf("LocalHead = height %d, chain %q, time %v", h.Height(), h.Header.ChainID, h.Header.Time)
+	}
+	if want := "8D2F0B4E6A8C1E3F5B7D9A0C2E4F6B8D0A2C4E6F8B0D2A4C6E8F0B2D4A6C8E0F"; h.Hash() != want {
+		t.Errorf("Hash = %s, want %s", h.Hash(), want)
+	}
+	if want := "6F4A39D0B2E5E5C8AC3C1E9A4F7B0D2E8C1A5B3D7F9E0C2A4B6D8F0A1C3E5B7D"; h.Header.LastBlockID.Hash != want {
+		t.Errorf("LastBlockID.Hash = %s, want %s", h.Header.LastBlockID.Hash, want)
+	}
+
+	s, err := c.SamplingStats(ctx)
+	if err != nil {
+		t.Fatalf("SamplingStats: %v", err)
+	}
+	if s.SampledChainHead != 412870 || s.CatchupHead != 412870 || s.NetworkHead != 412873 || !s.CatchUpDone || !s.IsRunning || s.Concurrency != 1 {
+		t.Errorf("SamplingStats = %+v", s)
+	}
+	if s.Failed["412011"] != 2 {
+		t.Errorf("Failed = %v, want 412011 failed twice", s.Failed)
+	}
+	if len(s.Workers) != 1 || s.Workers[0] != (WorkerStats{JobType: "recent", Curr: 412871, From: 412871, To: 412873}) {
+		t.Errorf("Workers = %+v", s.Workers)
+	}
+
+	b, err := c.Balance(ctx)
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	if *b != (Balance{Denom: "utia", Amount: "98765432"}) {
+		t.Errorf("Balance = %+v", b)
+	}
+}
+
+func TestMethodNotFound(t *testing.T) {
+	c := fixtureNode(t, nil)
+	_, err := c.SamplingStats(context.Background())
+	if !errors.Is(err, ErrMethodNotFound) {
+		t.Fatalf("SamplingStats error = %v, want ErrMethodNotFound", err)
+	}
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Method != "das.SamplingStats" || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("SamplingStats error = %#v, want an RPCError of das.SamplingStats", err)
+	}
+	if errors.Is(err, ErrInvalidParams) {
+		t.Errorf("SamplingStats error %v matches ErrInvalidParams", err)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	// A header of an API version with numeric heights.
+	c := fixtureNode(t, map[string]json.RawMessage{
+		"header.LocalHead": json.RawMessage(`{"header":{"height":412873}}`),
+	})
+	_, err := c.LocalHead(context.Background())
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("LocalHead error = %v, want a DecodeError", err)
+	}
+	if decodeErr.Method != "header.LocalHead" || decodeErr.What != "result" {
+		t.Errorf("DecodeError = %+v, want the result of header.LocalHead", decodeErr)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("DecodeError doesn't wrap the json error: %v", decodeErr.Err)
+	}
+}
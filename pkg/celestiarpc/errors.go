@@ -0,0 +1,64 @@
+package celestiarpc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Errors matched by errors.Is against an *RPCError with the corresponding
+// code, e.g. errors.Is(err, ErrMethodNotFound) when a node doesn't
+// implement a method.
+var (
+	ErrMethodNotFound = errors.New("method not found")
+	ErrInvalidParams  = errors.New("invalid params")
+)
+
+// RPCError is an error returned by the node in the error object of a
+// JSON-RPC response.
+type RPCError struct {
+	Method  string
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: rpc error %d: %s", e.Method, e.Code, e.Message)
+}
+
+// Is matches the sentinel errors for the well-known codes.
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case ErrMethodNotFound:
+		return e.Code == CodeMethodNotFound
+	case ErrInvalidParams:
+		return e.Code == CodeInvalidParams
+	}
+	return false
+}
+
+// DecodeError is returned when the response of the node can't be decoded,
+// typically because the node speaks a different API version.
+type DecodeError struct {
+	Method string
+	// What is the part of the response that failed, e.g. "response" or
+	// "result".
+	What string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: unmarshaling %s: %v", e.Method, e.What, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
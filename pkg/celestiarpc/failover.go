@@ -0,0 +1,121 @@
+package celestiarpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Failover switches a client between its endpoint, the primary, and
+// fallback endpoints of the same logical node. Requests go to the endpoint
+// in use; when it can't be reached the client moves on to the next one.
+// While a fallback is in use the primary is tried again every recheck
+// interval, and the client switches back as soon as it answers.
+type Failover struct {
+	endpoints []string
+	recheck   time.Duration
+	onSwitch  func(endpoint string, index int)
+
+	mu        sync.Mutex
+	active    int
+	lastCheck time.Time
+}
+
+// NewFailover returns a Failover to the fallbacks, in order. onSwitch, if
+// not nil, is called with the endpoint switched to and its index, 0 for
+// the primary.
+func NewFailover(fallbacks []string, recheck time.Duration, onSwitch func(endpoint string, index int)) *Failover {
+	return &Failover{
+		endpoints: append([]string{""}, fallbacks...),
+		recheck:   recheck,
+		onSwitch:  onSwitch,
+	}
+}
+
+// WithFailover makes the client fail over to the endpoints of f. A nil f
+// disables failover.
+func WithFailover(f *Failover) Option {
+	return func(c *Client) {
+		if f == nil {
+			return
+		}
+		f.endpoints[0] = c.endpoint
+		c.failover = f
+	}
+}
+
+// Active returns the endpoint in use and its index, 0 for the primary.
+func (f *Failover) Active() (string, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.endpoints[f.active], f.active
+}
+
+// order returns the indexes of the endpoints to try, starting with the one
+// in use, or with the primary if it is due for a recheck.
+func (f *Failover) order() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	start := f.active
+	if start != 0 && time.Since(f.lastCheck) >= f.recheck {
+		start = 0
+		f.lastCheck = time.Now()
+	}
+	order := make([]int, 0, len(f.endpoints))
+	for i := range f.endpoints {
+		order = append(order, (start+i)%len(f.endpoints))
+	}
+	return order
+}
+
+// answered records that endpoint i answered a request.
+func (f *Failover) answered(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i != f.active {
+		f.switchTo(i)
+	}
+}
+
+// unreachable records that endpoint i couldn't be reached; if it was in
+// use, the next one is.
+func (f *Failover) unreachable(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if i == f.active {
+		f.switchTo((i + 1) % len(f.endpoints))
+	}
+}
+
+func (f *Failover) switchTo(i int) {
+	f.active = i
+	f.lastCheck = time.Now()
+	if f.onSwitch != nil {
+		f.onSwitch(f.endpoints[i], i)
+	}
+}
+
+// postFailover posts body to the endpoints of the failover in turn until
+// one of them answers, and returns the result of the last attempt.
+func (c *Client) postFailover(ctx context.Context, header http.Header, body []byte) ([]byte, int, error) {
+	var (
+		respBytes []byte
+		status    int
+		err       error
+	)
+	for _, i := range c.failover.order() {
+		respBytes, status, err = c.postTo(ctx, c.failover.endpoints[i], header, body)
+		if err == nil || !unreachable(ctx, status) {
+			c.failover.answered(i)
+			return respBytes, status, err
+		}
+		c.failover.unreachable(i)
+		// Without time left the next endpoint can't be tried, it is used
+		// for the next request.
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return respBytes, status, err
+}
@@ -0,0 +1,98 @@
+package celestiarpc
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter caps the request rate and the number of concurrent requests to a
+// node, so that many collectors can't overwhelm it. Retries count as
+// separate requests.
+type Limiter struct {
+	// rate is the number of requests per second, 0 for no limit; slots
+	// holds a value for every request in flight, nil for no limit.
+	rate  float64
+	burst float64
+	slots chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter allowing qps requests per second, with
+// bursts of up to qps requests, and maxInFlight concurrent requests. 0
+// disables the respective limit.
+func NewLimiter(qps float64, maxInFlight int) *Limiter {
+	l := &Limiter{rate: qps}
+	if qps > 0 {
+		l.burst = math.Max(1, math.Floor(qps))
+		l.tokens = l.burst
+	}
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+	return l
+}
+
+// WithLimiter makes the client wait for l before every HTTP request. By
+// default requests are not limited.
+func WithLimiter(l *Limiter) Option {
+	return func(c *Client) {
+		c.limiter = l
+	}
+}
+
+// acquire waits until a request may be sent and returns the function that
+// must be called once it is done.
+func (l *Limiter) acquire(ctx context.Context) (release func(), err error) {
+	if err := l.waitToken(ctx); err != nil {
+		return nil, err
+	}
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// waitToken takes a token from the bucket, waiting for it to be refilled if
+// it is empty.
+func (l *Limiter) waitToken(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	// Reserve the token right away; a negative balance is the queue of
+	// waiting requests.
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
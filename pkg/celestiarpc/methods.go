@@ -0,0 +1,195 @@
+package celestiarpc
+
+import (
+	"context"
+	"fmt"
+)
+
+// LocalHead returns the node's local chain head (header.LocalHead).
+func (c *Client) LocalHead(ctx context.Context) (*ExtendedHeader, error) {
+	var h ExtendedHeader
+	if err := c.Call(ctx, "header.LocalHead", &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// NetworkHead returns the network head as seen by the node
+// (header.NetworkHead).
+func (c *Client) NetworkHead(ctx context.Context) (*ExtendedHeader, error) {
+	var h ExtendedHeader
+	if err := c.Call(ctx, "header.NetworkHead", &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// GetByHeight returns the header at height (header.GetByHeight).
+func (c *Client) GetByHeight(ctx context.Context, height uint64) (*ExtendedHeader, error) {
+	var h ExtendedHeader
+	if err := c.Call(ctx, "header.GetByHeight", &h, height); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// SamplingStats returns the state of the node's DAS routine
+// (das.SamplingStats).
+func (c *Client) SamplingStats(ctx context.Context) (*SamplingStats, error) {
+	var s SamplingStats
+	if err := c.Call(ctx, "das.SamplingStats", &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Info returns the node's type and API version (node.Info).
+func (c *Client) Info(ctx context.Context) (*NodeInfo, error) {
+	var info NodeInfo
+	if err := c.Call(ctx, "node.Info", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Peers returns the IDs of the peers the node is connected to (p2p.Peers).
+func (c *Client) Peers(ctx context.Context) ([]string, error) {
+	var peers []string
+	if err := c.Call(ctx, "p2p.Peers", &peers); err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+// BandwidthStats returns the node's total bandwidth usage
+// (p2p.BandwidthStats).
+func (c *Client) BandwidthStats(ctx context.Context) (*BandwidthStats, error) {
+	var stats BandwidthStats
+	if err := c.Call(ctx, "p2p.BandwidthStats", &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// BandwidthForProtocol returns the bandwidth usage of a single libp2p
+// protocol (p2p.BandwidthForProtocol).
+func (c *Client) BandwidthForProtocol(ctx context.Context, protocol string) (*BandwidthStats, error) {
+	var stats BandwidthStats
+	if err := c.Call(ctx, "p2p.BandwidthForProtocol", &stats, protocol); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// NATStatus returns the node's reachability as determined by AutoNAT
+// (p2p.NATStatus).
+func (c *Client) NATStatus(ctx context.Context) (Reachability, error) {
+	var r Reachability
+	if err := c.Call(ctx, "p2p.NATStatus", &r); err != nil {
+		return ReachabilityUnknown, err
+	}
+	return r, nil
+}
+
+// AccountAddress returns the address of the node's default account
+// (state.AccountAddress).
+func (c *Client) AccountAddress(ctx context.Context) (string, error) {
+	var addr string
+	if err := c.Call(ctx, "state.AccountAddress", &addr); err != nil {
+		return "", err
+	}
+	return addr, nil
+}
+
+// Balance returns the balance of the node's default account
+// (state.Balance).
+func (c *Client) Balance(ctx context.Context) (*Balance, error) {
+	var b Balance
+	if err := c.Call(ctx, "state.Balance", &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// BalanceForAddress returns the balance of addr (state.BalanceForAddress).
+func (c *Client) BalanceForAddress(ctx context.Context, addr string) (*Balance, error) {
+	var b Balance
+	if err := c.Call(ctx, "state.BalanceForAddress", &b, addr); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SubmitPayForBlob submits blobs in a PayForBlobs transaction with the
+// node's default gas settings and waits for it to be included
+// (state.SubmitPayForBlob).
+func (c *Client) SubmitPayForBlob(ctx context.Context, blobs []*Blob) (*TxResponse, error) {
+	var resp TxResponse
+	if err := c.Call(ctx, "state.SubmitPayForBlob", &resp, blobs, struct{}{}); err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("state.SubmitPayForBlob: transaction %s failed with code %d: %s", resp.TxHash, resp.Code, resp.RawLog)
+	}
+	return &resp, nil
+}
+
+// SubmitBlob submits blobs with the node's default gas settings, waits for
+// them to be included and returns the height of the block (blob.Submit).
+// Create the blobs with NewBlob to fetch them by commitment afterwards.
+func (c *Client) SubmitBlob(ctx context.Context, blobs []*Blob) (uint64, error) {
+	var height uint64
+	// Null options are the defaults, whichever type the node's API version
+	// takes.
+	if err := c.Call(ctx, "blob.Submit", &height, blobs, nil); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// GetAllBlobs returns all blobs under the namespaces at height
+// (blob.GetAll).
+func (c *Client) GetAllBlobs(ctx context.Context, height uint64, namespaces [][]byte) ([]*Blob, error) {
+	var blobs []*Blob
+	if err := c.Call(ctx, "blob.GetAll", &blobs, height, namespaces); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// GetBlob returns the blob with commitment under namespace at height
+// (blob.Get).
+func (c *Client) GetBlob(ctx context.Context, height uint64, namespace, commitment []byte) (*Blob, error) {
+	var b Blob
+	if err := c.Call(ctx, "blob.Get", &b, height, namespace, commitment); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SharesAvailable checks that the shares of the block at height are
+// available, from the node's own store or the network
+// (share.SharesAvailable).
+func (c *Client) SharesAvailable(ctx context.Context, height uint64) error {
+	return c.Call(ctx, "share.SharesAvailable", nil, height)
+}
+
+// GetEDS returns the extended data square of the block at height
+// (share.GetEDS).
+func (c *Client) GetEDS(ctx context.Context, height uint64) (*ExtendedDataSquare, error) {
+	var eds ExtendedDataSquare
+	if err := c.Call(ctx, "share.GetEDS", &eds, height); err != nil {
+		return nil, err
+	}
+	return &eds, nil
+}
+
+// GetShare returns the share at row and col of the extended data square at
+// height (share.GetShare).
+func (c *Client) GetShare(ctx context.Context, height uint64, row, col int) ([]byte, error) {
+	var share []byte
+	if err := c.Call(ctx, "share.GetShare", &share, height, row, col); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
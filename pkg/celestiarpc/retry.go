@@ -0,0 +1,124 @@
+package celestiarpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how often and how fast failed requests are retried.
+// Only transient failures are retried: network errors and HTTP 429, 502,
+// 503 and 504. JSON-RPC errors, other HTTP errors and cancelled contexts
+// are returned right away, as are all failures of the methods in
+// nonIdempotent.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for every
+	// further retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter randomizes each delay by up to this fraction, e.g. 0.2 for
+	// +/-20%, so that retries of several exporters don't synchronize.
+	Jitter float64
+}
+
+// WithRetryPolicy makes the client retry transient failures according to p.
+// By default requests are not retried.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// delay returns the backoff before retry number n, starting at 1.
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// nonIdempotent are the methods that submit transactions. The node may have
+// broadcast one before the failure, so a retry could submit it, and pay
+// its fees, twice.
+var nonIdempotent = map[string]bool{
+	"blob.Submit":                     true,
+	"state.SubmitPayForBlob":          true,
+	"state.SubmitTx":                  true,
+	"state.Transfer":                  true,
+	"state.Delegate":                  true,
+	"state.Undelegate":                true,
+	"state.BeginRedelegate":           true,
+	"state.CancelUnbondingDelegation": true,
+	"state.GrantFee":                  true,
+	"state.RevokeGrantFee":            true,
+}
+
+// retryable reports whether failures of a request calling methods may be
+// retried.
+func retryable(methods ...string) bool {
+	for _, method := range methods {
+		if nonIdempotent[method] {
+			return false
+		}
+	}
+	return true
+}
+
+// statusError is returned, wrapped, for non-OK HTTP responses.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "non-OK HTTP status: " + e.status
+}
+
+// transient reports whether a failed post is worth retrying.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrUnauthorized) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		switch se.code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Everything else failed before a complete response was received.
+	return true
+}
+
+// postRetrying posts body, retrying transient failures if retry is set. It
+// returns the number of attempts made along with the result of the last
+// one.
+func (c *Client) postRetrying(ctx context.Context, body []byte, retry bool) ([]byte, int, int, error) {
+	for attempt := 1; ; attempt++ {
+		respBytes, status, err := c.post(ctx, body)
+		if err == nil || !retry || attempt >= c.retry.MaxAttempts || !transient(ctx, err) {
+			return respBytes, status, attempt, err
+		}
+
+		timer := time.NewTimer(c.retry.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, status, attempt, fmt.Errorf("%w (giving up retrying: %v)", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
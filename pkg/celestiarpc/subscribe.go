@@ -0,0 +1,223 @@
+package celestiarpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsURL turns an http(s) endpoint into the matching ws(s) URL.
+func wsURL(endpoint string) string {
+	switch {
+	case strings.HasPrefix(endpoint, "https://"):
+		return "wss://" + strings.TrimPrefix(endpoint, "https://")
+	case strings.HasPrefix(endpoint, "http://"):
+		return "ws://" + strings.TrimPrefix(endpoint, "http://")
+	}
+	return endpoint
+}
+
+// maxEarlyNotifications bounds the notifications kept while the response
+// to header.Subscribe is outstanding.
+const maxEarlyNotifications = 16
+
+// The subscription pings the node every wsPingInterval and fails if it
+// received nothing, not even a pong, for wsReadTimeout, so a half-open
+// connection is noticed and re-established.
+const (
+	wsPingInterval = 30 * time.Second
+	wsReadTimeout  = 2*wsPingInterval + 15*time.Second
+)
+
+type wsMessage struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SubscribeHeaders calls header.Subscribe over WebSocket and invokes onHeader
+// for each header the node pushes. It blocks until ctx is done, in which case
+// it returns nil, or the subscription fails.
+//
+// The node streams values using the go-jsonrpc channel protocol: the call
+// returns a channel ID, followed by xrpc.ch.val notifications for it.
+func (c *Client) SubscribeHeaders(ctx context.Context, onHeader func(*ExtendedHeader)) error {
+	header := http.Header{}
+	if err := c.setAuthHeader(ctx, header); err != nil {
+		return fmt.Errorf("header.Subscribe: %w", err)
+	}
+
+	// Use the TLS and proxy settings of the HTTP client. The default
+	// dialer, like the default transport, uses the proxy from the
+	// environment.
+	dialer := *websocket.DefaultDialer
+	if tr, ok := c.httpClient.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = tr.TLSClientConfig
+		dialer.Proxy = tr.Proxy
+	}
+
+	if c.breaker != nil && !c.breaker.allow() {
+		err := fmt.Errorf("header.Subscribe: %w", ErrCircuitOpen)
+		if c.onRequest != nil {
+			c.onRequest(RequestInfo{Method: "header.Subscribe", Err: err})
+		}
+		return err
+	}
+	start := time.Now()
+	conn, resp, err := c.dial(ctx, &dialer, header)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	if c.breaker != nil {
+		c.breaker.done(err != nil && unreachable(ctx, status))
+	}
+	if c.onRequest != nil {
+		c.onRequest(RequestInfo{Method: "header.Subscribe", StatusCode: status, Duration: time.Since(start), Attempts: 1, Err: err})
+	}
+	if err != nil {
+		if status == http.StatusUnauthorized || status == http.StatusForbidden {
+			if c.onAuthFailure != nil {
+				c.onAuthFailure("header.Subscribe")
+			}
+			if rts, ok := c.tokens.(RefreshableTokenSource); ok {
+				rts.Refresh()
+			}
+			return fmt.Errorf("header.Subscribe: %w: HTTP status %s", ErrUnauthorized, resp.Status)
+		}
+		return fmt.Errorf("header.Subscribe: dialing: %w", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	})
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ping.C:
+				// A failed ping surfaces as a failed read.
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPingInterval))
+			case <-closed:
+				return
+			}
+		}
+	}()
+
+	const subscribeID = 1
+	err = conn.WriteJSON(request{
+		JSONRPC: "2.0",
+		ID:      subscribeID,
+		Method:  "header.Subscribe",
+		Params:  []interface{}{},
+	})
+	if err != nil {
+		return fmt.Errorf("header.Subscribe: sending request: %w", err)
+	}
+
+	deliver := func(msg wsMessage) error {
+		var h ExtendedHeader
+		if err := json.Unmarshal(msg.Params[1], &h); err != nil {
+			return &DecodeError{Method: "header.Subscribe", What: "header", Err: err}
+		}
+		onHeader(&h)
+		return nil
+	}
+
+	var chanID json.RawMessage
+	// go-jsonrpc may push the first values before the response carrying
+	// the channel ID, the latest of them are kept until it arrives.
+	var early []wsMessage
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("header.Subscribe: reading: %w", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+
+		switch {
+		case msg.ID != nil && *msg.ID == subscribeID:
+			if msg.Error != nil {
+				return &RPCError{Method: "header.Subscribe", Code: msg.Error.Code, Message: msg.Error.Message}
+			}
+			chanID = msg.Result
+			for _, m := range early {
+				if string(m.Params[0]) != string(chanID) {
+					continue
+				}
+				if err := deliver(m); err != nil {
+					return err
+				}
+			}
+			early = nil
+		case msg.Method == "xrpc.ch.val" && len(msg.Params) == 2 && chanID == nil:
+			if len(early) == maxEarlyNotifications {
+				early = early[1:]
+			}
+			early = append(early, msg)
+		case msg.Method == "xrpc.ch.val" && len(msg.Params) == 2 && string(msg.Params[0]) == string(chanID):
+			if err := deliver(msg); err != nil {
+				return err
+			}
+		case msg.Method == "xrpc.ch.close" && len(msg.Params) >= 1 && chanID != nil && string(msg.Params[0]) == string(chanID):
+			return fmt.Errorf("header.Subscribe: subscription closed by node")
+		}
+	}
+}
+
+// dial opens the WebSocket connection to the endpoint, or with failover to
+// the endpoints in turn like post, within the rate limit.
+func (c *Client) dial(ctx context.Context, dialer *websocket.Dialer, header http.Header) (*websocket.Conn, *http.Response, error) {
+	if c.limiter != nil {
+		release, err := c.limiter.acquire(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("waiting for rate limit: %w", err)
+		}
+		defer release()
+	}
+	if c.failover == nil {
+		return dialer.DialContext(ctx, wsURL(c.endpoint), header)
+	}
+	var (
+		conn *websocket.Conn
+		resp *http.Response
+		err  error
+	)
+	for _, i := range c.failover.order() {
+		conn, resp, err = dialer.DialContext(ctx, wsURL(c.failover.endpoints[i]), header)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		if err == nil || !unreachable(ctx, status) {
+			c.failover.answered(i)
+			return conn, resp, err
+		}
+		c.failover.unreachable(i)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return conn, resp, err
+}
@@ -0,0 +1,189 @@
+package celestiarpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExtendedHeader is the subset of celestia-node's ExtendedHeader the client
+// decodes.
+type ExtendedHeader struct {
+	Header RawHeader `json:"header"`
+	Commit Commit    `json:"commit"`
+}
+
+// RawHeader is the Tendermint header embedded in an ExtendedHeader.
+type RawHeader struct {
+	ChainID     string    `json:"chain_id"`
+	Height      uint64    `json:"height,string"`
+	Time        time.Time `json:"time"`
+	LastBlockID BlockID   `json:"last_block_id"`
+}
+
+// Commit is the commit for the block of an ExtendedHeader.
+type Commit struct {
+	BlockID BlockID `json:"block_id"`
+}
+
+// BlockID identifies a block by its hash.
+type BlockID struct {
+	Hash string `json:"hash"`
+}
+
+// Height is a shortcut for h.Header.Height.
+func (h *ExtendedHeader) Height() uint64 {
+	return h.Header.Height
+}
+
+// Hash returns the hash of the header's block, as committed to by the
+// commit.
+func (h *ExtendedHeader) Hash() string {
+	return h.Commit.BlockID.Hash
+}
+
+// SamplingStats is the result of das.SamplingStats.
+type SamplingStats struct {
+	SampledChainHead uint64         `json:"head_of_sampled_chain"`
+	CatchupHead      uint64         `json:"head_of_catchup"`
+	NetworkHead      uint64         `json:"network_head_height"`
+	Failed           map[string]int `json:"failed,omitempty"`
+	Workers          []WorkerStats  `json:"workers,omitempty"`
+	Concurrency      int            `json:"concurrency"`
+	CatchUpDone      bool           `json:"catch_up_done"`
+	IsRunning        bool           `json:"is_running"`
+}
+
+// WorkerStats describes a single DAS worker.
+type WorkerStats struct {
+	JobType string `json:"job_type"`
+	Curr    uint64 `json:"current"`
+	From    uint64 `json:"from"`
+	To      uint64 `json:"to"`
+	ErrMsg  string `json:"error,omitempty"`
+}
+
+// NodeType is the type of a celestia-node.
+type NodeType int
+
+// Node types as numbered by celestia-node.
+const (
+	NodeTypeLight NodeType = iota + 1
+	NodeTypeFull
+	NodeTypeBridge
+)
+
+func (t NodeType) String() string {
+	switch t {
+	case NodeTypeLight:
+		return "light"
+	case NodeTypeFull:
+		return "full"
+	case NodeTypeBridge:
+		return "bridge"
+	}
+	return "unknown"
+}
+
+// UnmarshalJSON accepts both the numeric and the string form of a node type.
+func (t *NodeType) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*t = NodeType(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid node type %s", data)
+	}
+	switch strings.ToLower(s) {
+	case "light":
+		*t = NodeTypeLight
+	case "full":
+		*t = NodeTypeFull
+	case "bridge":
+		*t = NodeTypeBridge
+	default:
+		return fmt.Errorf("unknown node type %q", s)
+	}
+	return nil
+}
+
+// NodeInfo is the result of node.Info.
+type NodeInfo struct {
+	Type       NodeType `json:"type"`
+	APIVersion string   `json:"api_version"`
+	// Version is not sent by current celestia-node releases, which report
+	// their version as APIVersion.
+	Version string `json:"version,omitempty"`
+}
+
+// BandwidthStats is the result of p2p.BandwidthStats and
+// p2p.BandwidthForProtocol.
+type BandwidthStats struct {
+	TotalIn  int64   `json:"TotalIn"`
+	TotalOut int64   `json:"TotalOut"`
+	RateIn   float64 `json:"RateIn"`
+	RateOut  float64 `json:"RateOut"`
+}
+
+// PeerInfo is the result of p2p.PeerInfo: a peer and its multiaddrs, e.g.
+// /ip4/1.2.3.4/tcp/2121.
+type PeerInfo struct {
+	ID    string   `json:"ID"`
+	Addrs []string `json:"Addrs"`
+}
+
+// Reachability is the NAT status reported by p2p.NATStatus.
+type Reachability int
+
+// Reachability values as defined by libp2p.
+const (
+	ReachabilityUnknown Reachability = iota
+	ReachabilityPublic
+	ReachabilityPrivate
+)
+
+func (r Reachability) String() string {
+	switch r {
+	case ReachabilityPublic:
+		return "public"
+	case ReachabilityPrivate:
+		return "private"
+	}
+	return "unknown"
+}
+
+// Balance is an amount of a single denomination, as returned by
+// state.Balance.
+type Balance struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+// Blob is a blob of data published under a namespace. Namespace is the
+// full 29 byte namespace including the version byte.
+type Blob struct {
+	Namespace    []byte `json:"namespace"`
+	Data         []byte `json:"data"`
+	ShareVersion uint32 `json:"share_version"`
+	Commitment   []byte `json:"commitment,omitempty"`
+}
+
+// ExtendedDataSquare is the erasure coded data square of a block, as
+// returned by share.GetEDS, with the shares row by row.
+type ExtendedDataSquare struct {
+	DataSquare [][]byte `json:"data_square"`
+}
+
+// TxResponse is the subset of the transaction result returned by
+// state.SubmitPayForBlob the client decodes.
+type TxResponse struct {
+	Height    int64  `json:"height"`
+	TxHash    string `json:"txhash"`
+	Code      uint32 `json:"code"`
+	RawLog    string `json:"raw_log"`
+	GasWanted int64  `json:"gas_wanted"`
+	GasUsed   int64  `json:"gas_used"`
+}
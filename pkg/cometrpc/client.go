@@ -0,0 +1,113 @@
+// Package cometrpc is a small client for the RPC of a CometBFT (Tendermint)
+// consensus node, including the Cosmos SDK queries needed to monitor a
+// validator.
+package cometrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Client calls JSON-RPC methods on a single CometBFT RPC endpoint.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	nextID     uint64
+	grpc       *GRPCClient
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used for requests. By default
+// http.DefaultClient is used.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// New returns a client for endpoint, e.g. http://localhost:26657.
+func New(endpoint string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:   endpoint,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Endpoint returns the endpoint the client talks to.
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+type request struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      uint64                 `json:"id"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	} `json:"error"`
+}
+
+// Call invokes method with the named params and decodes the result into
+// result.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params map[string]interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	reqBytes, err := json.Marshal(request{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.nextID, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: encoding request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(reqBytes))
+	if err != nil {
+		return fmt.Errorf("%s: creating request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: non-OK HTTP status: %s", method, resp.Status)
+	}
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: reading response body: %w", method, err)
+	}
+
+	var rpcResp response
+	if err := json.Unmarshal(respBytes, &rpcResp); err != nil {
+		return fmt.Errorf("%s: unmarshaling response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("%s: rpc error %d: %s %s", method, rpcResp.Error.Code, rpcResp.Error.Message, rpcResp.Error.Data)
+	}
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("%s: unmarshaling result: %w", method, err)
+	}
+	return nil
+}
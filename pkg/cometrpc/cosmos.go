@@ -0,0 +1,425 @@
+package cometrpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Cosmos SDK queries below are sent as ABCI queries with hand-encoded
+// protobuf messages, so that no SDK dependency is needed. Only the fields
+// the exporter uses are decoded.
+
+// StakingValidator returns the staking state of the validator with the
+// given operator address (cosmos.staking.v1beta1.Query/Validator).
+func (c *Client) StakingValidator(ctx context.Context, operatorAddress string) (*StakingValidator, error) {
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, operatorAddress)
+	resp, err := c.query(ctx, "/cosmos.staking.v1beta1.Query/Validator", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var v StakingValidator
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { v.OperatorAddress = string(f.bytes); return nil },
+				2: func(f field) error {
+					// google.protobuf.Any wrapping a PubKey message.
+					return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						2: func(f field) error {
+							return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+								1: func(f field) error { v.ConsensusPubKey = f.bytes; return nil },
+							})
+						},
+					})
+				},
+				3: func(f field) error { v.Jailed = f.varint != 0; return nil },
+				4: func(f field) error { v.Status = int32(f.varint); return nil },
+				5: func(f field) error { v.Tokens = string(f.bytes); return nil },
+				10: func(f field) error {
+					return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						1: func(f field) error {
+							return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+								1: func(f field) (err error) {
+									v.CommissionRate, err = parseDec(string(f.bytes))
+									return err
+								},
+							})
+						},
+					})
+				},
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding validator %s: %w", operatorAddress, err)
+	}
+	if v.OperatorAddress == "" {
+		return nil, fmt.Errorf("validator %s not found", operatorAddress)
+	}
+	return &v, nil
+}
+
+// SigningInfo returns the liveness record of the validator with the bech32
+// consensus address (cosmos.slashing.v1beta1.Query/SigningInfo).
+func (c *Client) SigningInfo(ctx context.Context, consAddress string) (*SigningInfo, error) {
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, consAddress)
+	resp, err := c.query(ctx, "/cosmos.slashing.v1beta1.Query/SigningInfo", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var si SigningInfo
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { si.Address = string(f.bytes); return nil },
+				2: func(f field) error { si.StartHeight = int64(f.varint); return nil },
+				3: func(f field) error { si.IndexOffset = int64(f.varint); return nil },
+				4: func(f field) error {
+					return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						1: func(f field) error { si.JailedUntil = int64(f.varint); return nil },
+					})
+				},
+				5: func(f field) error { si.Tombstoned = f.varint != 0; return nil },
+				6: func(f field) error { si.MissedBlocksCounter = int64(f.varint); return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing info of %s: %w", consAddress, err)
+	}
+	return &si, nil
+}
+
+// SlashingParams returns the parameters of the slashing module
+// (cosmos.slashing.v1beta1.Query/Params).
+func (c *Client) SlashingParams(ctx context.Context) (*SlashingParams, error) {
+	resp, err := c.query(ctx, "/cosmos.slashing.v1beta1.Query/Params", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var p SlashingParams
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { p.SignedBlocksWindow = int64(f.varint); return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding slashing params: %w", err)
+	}
+	return &p, nil
+}
+
+// StakingPool returns the bonded and not bonded stake of the network
+// (cosmos.staking.v1beta1.Query/Pool).
+func (c *Client) StakingPool(ctx context.Context) (*StakingPool, error) {
+	resp, err := c.query(ctx, "/cosmos.staking.v1beta1.Query/Pool", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var p StakingPool
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) (err error) {
+					p.NotBondedTokens, err = parseInt(string(f.bytes))
+					return err
+				},
+				2: func(f field) (err error) {
+					p.BondedTokens, err = parseInt(string(f.bytes))
+					return err
+				},
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding staking pool: %w", err)
+	}
+	return &p, nil
+}
+
+// pageRequest encodes a cosmos.base.query.v1beta1.PageRequest continuing at
+// key.
+func pageRequest(key []byte, limit uint64, countTotal bool) []byte {
+	var req []byte
+	if len(key) > 0 {
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, key)
+	}
+	req = protowire.AppendTag(req, 3, protowire.VarintType)
+	req = protowire.AppendVarint(req, limit)
+	if countTotal {
+		req = protowire.AppendTag(req, 4, protowire.VarintType)
+		req = protowire.AppendVarint(req, 1)
+	}
+	return req
+}
+
+// validatorPageRequest encodes a query by validator address with a page
+// request.
+func validatorPageRequest(operatorAddress string, page []byte) []byte {
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, operatorAddress)
+	req = protowire.AppendTag(req, 2, protowire.BytesType)
+	return protowire.AppendBytes(req, page)
+}
+
+// DelegatorCount returns the number of delegations to the validator with
+// the given operator address
+// (cosmos.staking.v1beta1.Query/ValidatorDelegations).
+func (c *Client) DelegatorCount(ctx context.Context, operatorAddress string) (int64, error) {
+	req := validatorPageRequest(operatorAddress, pageRequest(nil, 1, true))
+	resp, err := c.query(ctx, "/cosmos.staking.v1beta1.Query/ValidatorDelegations", req)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		2: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				2: func(f field) error { total = int64(f.varint); return nil },
+			})
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("decoding delegations of %s: %w", operatorAddress, err)
+	}
+	return total, nil
+}
+
+// UnbondingTokens returns the stake in utia being unbonded from the
+// validator with the given operator address, walking all pages
+// (cosmos.staking.v1beta1.Query/ValidatorUnbondingDelegations).
+func (c *Client) UnbondingTokens(ctx context.Context, operatorAddress string) (float64, error) {
+	var total float64
+	var key []byte
+	for {
+		req := validatorPageRequest(operatorAddress, pageRequest(key, 500, false))
+		resp, err := c.query(ctx, "/cosmos.staking.v1beta1.Query/ValidatorUnbondingDelegations", req)
+		if err != nil {
+			return 0, err
+		}
+
+		key = nil
+		err = decodeFields(resp, map[protowire.Number]func(field) error{
+			// UnbondingDelegation, whose entries hold the balance still
+			// unbonding.
+			1: func(f field) error {
+				return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+					3: func(f field) error {
+						return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+							4: func(f field) error {
+								balance, err := parseInt(string(f.bytes))
+								total += balance
+								return err
+							},
+						})
+					},
+				})
+			},
+			2: func(f field) error {
+				return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+					1: func(f field) error { key = f.bytes; return nil },
+				})
+			},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("decoding unbonding delegations of %s: %w", operatorAddress, err)
+		}
+		if len(key) == 0 {
+			return total, nil
+		}
+	}
+}
+
+// ValidatorRewards returns the outstanding rewards and the accumulated
+// commission of the validator with the given operator address
+// (cosmos.distribution.v1beta1.Query/ValidatorOutstandingRewards and
+// ValidatorCommission).
+func (c *Client) ValidatorRewards(ctx context.Context, operatorAddress string) (*ValidatorRewards, error) {
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, operatorAddress)
+
+	var r ValidatorRewards
+	for _, q := range []struct {
+		path   string
+		amount *float64
+	}{
+		{"/cosmos.distribution.v1beta1.Query/ValidatorOutstandingRewards", &r.Outstanding},
+		{"/cosmos.distribution.v1beta1.Query/ValidatorCommission", &r.Commission},
+	} {
+		resp, err := c.query(ctx, q.path, req)
+		if err != nil {
+			return nil, err
+		}
+		// Both responses wrap a message with the repeated DecCoins in
+		// field 1.
+		err = decodeFields(resp, map[protowire.Number]func(field) error{
+			1: func(f field) error {
+				return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+					1: func(f field) error {
+						amount, err := decodeUtia(f.bytes)
+						*q.amount += amount
+						return err
+					},
+				})
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("decoding rewards of %s: %w", operatorAddress, err)
+		}
+	}
+	return &r, nil
+}
+
+// decodeUtia returns the amount of a DecCoin if its denom is utia, and 0
+// otherwise.
+func decodeUtia(coin []byte) (float64, error) {
+	var denom, amount string
+	err := decodeFields(coin, map[protowire.Number]func(field) error{
+		1: func(f field) error { denom = string(f.bytes); return nil },
+		2: func(f field) error { amount = string(f.bytes); return nil },
+	})
+	if err != nil || denom != "utia" {
+		return 0, err
+	}
+	return parseDec(amount)
+}
+
+// SupplyOf returns the total supply of denom, e.g. utia
+// (cosmos.bank.v1beta1.Query/SupplyOf).
+func (c *Client) SupplyOf(ctx context.Context, denom string) (float64, error) {
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, denom)
+	resp, err := c.query(ctx, "/cosmos.bank.v1beta1.Query/SupplyOf", req)
+	if err != nil {
+		return 0, err
+	}
+
+	var supply float64
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				2: func(f field) (err error) {
+					supply, err = parseInt(string(f.bytes))
+					return err
+				},
+			})
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("decoding supply of %s: %w", denom, err)
+	}
+	return supply, nil
+}
+
+// BlobParams returns the parameters of the blob module
+// (celestia.blob.v1.Query/Params).
+func (c *Client) BlobParams(ctx context.Context) (*BlobParams, error) {
+	resp, err := c.query(ctx, "/celestia.blob.v1.Query/Params", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var p BlobParams
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { p.GasPerBlobByte = uint32(f.varint); return nil },
+				2: func(f field) error { p.GovMaxSquareSize = f.varint; return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding blob params: %w", err)
+	}
+	return &p, nil
+}
+
+// ConsensusAddress returns the hex address and the bech32 consensus address
+// with the given prefix (e.g. celestiavalcons) of an ed25519 consensus
+// public key.
+func ConsensusAddress(pubKey []byte, prefix string) (hexAddr, bech32Addr string, err error) {
+	sum := sha256.Sum256(pubKey)
+	addr := sum[:20]
+	bech32Addr, err = bech32Encode(prefix, addr)
+	if err != nil {
+		return "", "", err
+	}
+	return fmt.Sprintf("%X", addr), bech32Addr, nil
+}
+
+// parseDec parses a Cosmos SDK Dec, which is encoded as an integer string
+// with 18 implied decimal places.
+func parseDec(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing decimal %q: %w", s, err)
+	}
+	return f / 1e18, nil
+}
+
+// parseInt parses a Cosmos SDK Int, an arbitrary precision integer string
+// such as a token amount.
+func parseInt(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing integer %q: %w", s, err)
+	}
+	return f, nil
+}
+
+// field is a decoded protobuf field; bytes is set for length-delimited
+// fields and varint for varint fields.
+type field struct {
+	bytes  []byte
+	varint uint64
+}
+
+// decodeFields walks the protobuf message b and calls the handler for each
+// field number it finds. Fields without a handler are skipped.
+func decodeFields(b []byte, handlers map[protowire.Number]func(field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var f field
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if h, ok := handlers[num]; ok {
+			if err := h(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
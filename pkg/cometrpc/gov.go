@@ -0,0 +1,91 @@
+package cometrpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// proposalStatusVotingPeriod is PROPOSAL_STATUS_VOTING_PERIOD of the gov
+// module.
+const proposalStatusVotingPeriod = 2
+
+// ActiveProposals returns the governance proposals in their voting period,
+// walking all pages (cosmos.gov.v1.Query/Proposals).
+func (c *Client) ActiveProposals(ctx context.Context) ([]Proposal, error) {
+	var proposals []Proposal
+	var key []byte
+	for {
+		req := protowire.AppendTag(nil, 1, protowire.VarintType)
+		req = protowire.AppendVarint(req, proposalStatusVotingPeriod)
+		req = protowire.AppendTag(req, 4, protowire.BytesType)
+		req = protowire.AppendBytes(req, pageRequest(key, 100, false))
+		resp, err := c.query(ctx, "/cosmos.gov.v1.Query/Proposals", req)
+		if err != nil {
+			return nil, err
+		}
+
+		key = nil
+		err = decodeFields(resp, map[protowire.Number]func(field) error{
+			1: func(f field) error {
+				var p Proposal
+				err := decodeFields(f.bytes, map[protowire.Number]func(field) error{
+					1: func(f field) error { p.ID = f.varint; return nil },
+					9: func(f field) error {
+						return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+							1: func(f field) error { p.VotingEndTime = int64(f.varint); return nil },
+						})
+					},
+				})
+				proposals = append(proposals, p)
+				return err
+			},
+			2: func(f field) error {
+				return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+					1: func(f field) error { key = f.bytes; return nil },
+				})
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("decoding proposals: %w", err)
+		}
+		if len(key) == 0 {
+			return proposals, nil
+		}
+	}
+}
+
+// Voted reports whether the account address voted on the proposal
+// (cosmos.gov.v1.Query/Vote).
+func (c *Client) Voted(ctx context.Context, proposalID uint64, voter string) (bool, error) {
+	req := protowire.AppendTag(nil, 1, protowire.VarintType)
+	req = protowire.AppendVarint(req, proposalID)
+	req = protowire.AppendTag(req, 2, protowire.BytesType)
+	req = protowire.AppendString(req, voter)
+	resp, err := c.query(ctx, "/cosmos.gov.v1.Query/Vote", req)
+	if err != nil {
+		// The gov module answers with an error rather than an empty
+		// vote if there is none.
+		if strings.Contains(err.Error(), "not found") {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(resp) > 0, nil
+}
+
+// AccountAddress returns the account address controlling the validator
+// with the given operator address, e.g. celestia1... for
+// celestiavaloper1..., which is the address the validator votes with.
+func AccountAddress(operatorAddress string) (string, error) {
+	hrp, data, err := bech32Decode(operatorAddress)
+	if err != nil {
+		return "", fmt.Errorf("decoding %s: %w", operatorAddress, err)
+	}
+	if !strings.HasSuffix(hrp, "valoper") {
+		return "", fmt.Errorf("%s is not an operator address", operatorAddress)
+	}
+	return bech32Encode(strings.TrimSuffix(hrp, "valoper"), data)
+}
@@ -0,0 +1,128 @@
+package cometrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// GRPCClient sends the Cosmos SDK queries to the gRPC server of
+// celestia-app (port 9090 by default) instead of as ABCI queries through
+// the CometBFT RPC. The messages stay hand-encoded, the connection only
+// carries their bytes.
+type GRPCClient struct {
+	target string
+	conn   *grpc.ClientConn
+}
+
+// DialGRPC returns a client for the gRPC server at address, see
+// ParseGRPCAddress. The connection is established lazily.
+func DialGRPC(address string) (*GRPCClient, error) {
+	target, useTLS, err := ParseGRPCAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds), grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{target: address, conn: conn}, nil
+}
+
+// ParseGRPCAddress parses a gRPC address, given as host:port or
+// http://host:port for plaintext and https://host:port for TLS, into the
+// host and port to dial.
+func ParseGRPCAddress(address string) (target string, useTLS bool, err error) {
+	if !strings.Contains(address, "://") {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", false, fmt.Errorf("invalid gRPC address %q: %w", address, err)
+		}
+		return address, false, nil
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid gRPC address %q", address)
+	}
+	switch u.Scheme {
+	case "http":
+		return u.Host, false, nil
+	case "https":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		return host, true, nil
+	}
+	return "", false, fmt.Errorf("invalid gRPC address %q, the scheme must be http or https", address)
+}
+
+// Target returns the address the client talks to.
+func (g *GRPCClient) Target() string {
+	return g.target
+}
+
+// Query calls the unary gRPC method, e.g.
+// /cosmos.staking.v1beta1.Query/Validator, with the encoded request and
+// returns the encoded response.
+func (g *GRPCClient) Query(ctx context.Context, method string, req []byte) ([]byte, error) {
+	var resp []byte
+	if err := g.conn.Invoke(ctx, method, req, &resp); err != nil {
+		return nil, fmt.Errorf("gRPC %s: %w", method, err)
+	}
+	return resp, nil
+}
+
+// Close closes the connection.
+func (g *GRPCClient) Close() error {
+	return g.conn.Close()
+}
+
+// rawCodec passes the already encoded protobuf messages through.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// WithAppGRPC makes the client send the Cosmos SDK queries to g. A client
+// with an empty endpoint then only supports these queries.
+func WithAppGRPC(g *GRPCClient) Option {
+	return func(c *Client) {
+		c.grpc = g
+	}
+}
+
+// query runs a Cosmos SDK query, via gRPC if configured and else as ABCI
+// query.
+func (c *Client) query(ctx context.Context, path string, data []byte) ([]byte, error) {
+	if c.grpc != nil {
+		return c.grpc.Query(ctx, path, data)
+	}
+	return c.ABCIQuery(ctx, path, data)
+}
@@ -0,0 +1,130 @@
+package cometrpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const tendermintClientStateType = "/ibc.lightclients.tendermint.v1.ClientState"
+
+// portChannelRequest encodes a query by port and channel ID.
+func portChannelRequest(port, channel string) []byte {
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, port)
+	req = protowire.AppendTag(req, 2, protowire.BytesType)
+	return protowire.AppendString(req, channel)
+}
+
+// IBCChannel returns the state of the IBC channel on port
+// (ibc.core.channel.v1.Query/Channel).
+func (c *Client) IBCChannel(ctx context.Context, port, channel string) (*IBCChannel, error) {
+	resp, err := c.query(ctx, "/ibc.core.channel.v1.Query/Channel", portChannelRequest(port, channel))
+	if err != nil {
+		return nil, err
+	}
+
+	var ch IBCChannel
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { ch.State = int32(f.varint); return nil },
+				3: func(f field) error {
+					return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						2: func(f field) error { ch.CounterpartyChannel = string(f.bytes); return nil },
+					})
+				},
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding channel %s/%s: %w", port, channel, err)
+	}
+	return &ch, nil
+}
+
+// IBCChannelClient returns the light client the IBC channel on port is
+// built on, which has to be a Tendermint client
+// (ibc.core.channel.v1.Query/ChannelClientState and
+// ibc.core.client.v1.Query/ConsensusState).
+func (c *Client) IBCChannelClient(ctx context.Context, port, channel string) (*IBCClient, error) {
+	resp, err := c.query(ctx, "/ibc.core.channel.v1.Query/ChannelClientState", portChannelRequest(port, channel))
+	if err != nil {
+		return nil, err
+	}
+
+	var cl IBCClient
+	var clientType string
+	var trustingPeriod int64
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		// IdentifiedClientState with the client state as Any.
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { cl.ClientID = string(f.bytes); return nil },
+				2: func(f field) error {
+					return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						1: func(f field) error { clientType = string(f.bytes); return nil },
+						2: func(f field) error {
+							return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+								1: func(f field) error { cl.ChainID = string(f.bytes); return nil },
+								3: func(f field) error {
+									return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+										1: func(f field) error { trustingPeriod = int64(f.varint); return nil },
+									})
+								},
+								6: func(f field) error {
+									return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+										2: func(f field) error { cl.Frozen = f.varint != 0; return nil },
+									})
+								},
+								7: func(f field) error {
+									return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+										2: func(f field) error { cl.LatestHeight = int64(f.varint); return nil },
+									})
+								},
+							})
+						},
+					})
+				},
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding client state of channel %s/%s: %w", port, channel, err)
+	}
+	if clientType != tendermintClientStateType {
+		return nil, fmt.Errorf("channel %s/%s: unsupported client type %q", port, channel, clientType)
+	}
+
+	req := protowire.AppendTag(nil, 1, protowire.BytesType)
+	req = protowire.AppendString(req, cl.ClientID)
+	req = protowire.AppendTag(req, 4, protowire.VarintType)
+	req = protowire.AppendVarint(req, 1)
+	resp, err = c.query(ctx, "/ibc.core.client.v1.Query/ConsensusState", req)
+	if err != nil {
+		return nil, err
+	}
+	var updated int64
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				2: func(f field) error {
+					return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						1: func(f field) error {
+							return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+								1: func(f field) error { updated = int64(f.varint); return nil },
+							})
+						},
+					})
+				},
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding consensus state of client %s: %w", cl.ClientID, err)
+	}
+	cl.LastUpdate = updated
+	cl.Expiry = updated + trustingPeriod
+	return &cl, nil
+}
@@ -0,0 +1,147 @@
+package cometrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Status returns the node's status (status).
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var s Status
+	if err := c.Call(ctx, "status", &s, nil); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Block returns the block at height (block).
+func (c *Client) Block(ctx context.Context, height int64) (*Block, error) {
+	var res struct {
+		Block Block `json:"block"`
+	}
+	params := map[string]interface{}{"height": fmt.Sprint(height)}
+	if err := c.Call(ctx, "block", &res, params); err != nil {
+		return nil, err
+	}
+	return &res.Block, nil
+}
+
+// Commit returns the height and the hash of the block at height, or of the
+// latest block if height is 0 (commit).
+func (c *Client) Commit(ctx context.Context, height int64) (*BlockID, error) {
+	var res struct {
+		SignedHeader struct {
+			Commit struct {
+				Height  int64 `json:"height,string"`
+				BlockID struct {
+					Hash string `json:"hash"`
+				} `json:"block_id"`
+			} `json:"commit"`
+		} `json:"signed_header"`
+	}
+	var params map[string]interface{}
+	if height > 0 {
+		params = map[string]interface{}{"height": fmt.Sprint(height)}
+	}
+	if err := c.Call(ctx, "commit", &res, params); err != nil {
+		return nil, err
+	}
+	commit := res.SignedHeader.Commit
+	return &BlockID{Height: commit.Height, Hash: commit.BlockID.Hash}, nil
+}
+
+// BlockResults returns the events emitted while executing the block at
+// height (block_results).
+func (c *Client) BlockResults(ctx context.Context, height int64) (*BlockResults, error) {
+	var res struct {
+		Height              int64   `json:"height,string"`
+		BeginBlockEvents    []Event `json:"begin_block_events"`
+		EndBlockEvents      []Event `json:"end_block_events"`
+		FinalizeBlockEvents []Event `json:"finalize_block_events"`
+	}
+	params := map[string]interface{}{"height": fmt.Sprint(height)}
+	if err := c.Call(ctx, "block_results", &res, params); err != nil {
+		return nil, err
+	}
+	br := &BlockResults{Height: res.Height}
+	for _, events := range [][]Event{res.BeginBlockEvents, res.EndBlockEvents, res.FinalizeBlockEvents} {
+		for _, e := range events {
+			br.Events = append(br.Events, e.decoded())
+		}
+	}
+	return br, nil
+}
+
+// UnconfirmedTxs returns the size of the mempool (num_unconfirmed_txs).
+func (c *Client) UnconfirmedTxs(ctx context.Context) (*Mempool, error) {
+	var m Mempool
+	if err := c.Call(ctx, "num_unconfirmed_txs", &m, nil); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validators returns one page of the active validator set at the latest
+// height (validators). Pages start at 1.
+func (c *Client) Validators(ctx context.Context, page, perPage int) (*ValidatorSet, error) {
+	var vs ValidatorSet
+	params := map[string]interface{}{
+		"page":     fmt.Sprint(page),
+		"per_page": fmt.Sprint(perPage),
+	}
+	if err := c.Call(ctx, "validators", &vs, params); err != nil {
+		return nil, err
+	}
+	return &vs, nil
+}
+
+// ValidatorByAddress looks up the validator with the hex encoded consensus
+// address in the active set. It returns nil if the validator is not in the
+// active set.
+func (c *Client) ValidatorByAddress(ctx context.Context, address string) (*Validator, error) {
+	const perPage = 100
+	for page, seen := 1, 0; ; page++ {
+		vs, err := c.Validators(ctx, page, perPage)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vs.Validators {
+			if strings.EqualFold(v.Address, address) {
+				return &vs.Validators[i], nil
+			}
+		}
+		seen += len(vs.Validators)
+		if len(vs.Validators) == 0 || seen >= vs.Total {
+			return nil, nil
+		}
+	}
+}
+
+type abciQueryResult struct {
+	Response struct {
+		Code  uint32 `json:"code"`
+		Log   string `json:"log"`
+		Value []byte `json:"value"`
+	} `json:"response"`
+}
+
+// ABCIQuery runs an ABCI query against the application at the latest height
+// (abci_query) and returns the raw response value, e.g. a protobuf encoded
+// gRPC query response.
+func (c *Client) ABCIQuery(ctx context.Context, path string, data []byte) ([]byte, error) {
+	var res abciQueryResult
+	params := map[string]interface{}{
+		"path":  path,
+		"data":  hex.EncodeToString(data),
+		"prove": false,
+	}
+	if err := c.Call(ctx, "abci_query", &res, params); err != nil {
+		return nil, err
+	}
+	if res.Response.Code != 0 {
+		return nil, fmt.Errorf("abci_query %s: code %d: %s", path, res.Response.Code, res.Response.Log)
+	}
+	return res.Response.Value, nil
+}
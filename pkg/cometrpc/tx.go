@@ -0,0 +1,163 @@
+package cometrpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// msgPayForBlobsType is the type URL of the celestia-app message paying for
+// blobs.
+const msgPayForBlobsType = "/celestia.blob.v1.MsgPayForBlobs"
+
+// indexWrapperTypeID marks the block transactions of celestia-app that pay
+// for blobs: the transaction wrapped together with the share indexes of its
+// blobs (celestia.core.v1.blob.IndexWrapper).
+const indexWrapperTypeID = "INDX"
+
+// PayForBlobs is the fee of a transaction paying for blobs.
+type PayForBlobs struct {
+	// Blobs is the number of blobs the transaction paid for.
+	Blobs int
+	// Fee is the fee paid in utia.
+	Fee      float64
+	GasLimit uint64
+}
+
+// DecodePayForBlobs decodes a transaction of a block. It returns nil if the
+// transaction doesn't pay for blobs.
+func DecodePayForBlobs(tx []byte) (*PayForBlobs, error) {
+	var inner []byte
+	var typeID string
+	err := decodeFields(tx, map[protowire.Number]func(field) error{
+		1: func(f field) error { inner = f.bytes; return nil },
+		3: func(f field) error { typeID = string(f.bytes); return nil },
+	})
+	if err != nil || typeID != indexWrapperTypeID {
+		// Not wrapped, so the transaction has no blobs.
+		return nil, nil
+	}
+
+	// cosmos.tx.v1beta1.TxRaw
+	var body, authInfo []byte
+	err = decodeFields(inner, map[protowire.Number]func(field) error{
+		1: func(f field) error { body = f.bytes; return nil },
+		2: func(f field) error { authInfo = f.bytes; return nil },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding transaction: %w", err)
+	}
+
+	var pfb PayForBlobs
+	err = decodeFields(body, map[protowire.Number]func(field) error{
+		// google.protobuf.Any messages
+		1: func(f field) error {
+			var typeURL string
+			var value []byte
+			err := decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { typeURL = string(f.bytes); return nil },
+				2: func(f field) error { value = f.bytes; return nil },
+			})
+			if err != nil || typeURL != msgPayForBlobsType {
+				return err
+			}
+			return decodeFields(value, map[protowire.Number]func(field) error{
+				// namespaces, one per blob
+				2: func(f field) error { pfb.Blobs++; return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding transaction body: %w", err)
+	}
+	if pfb.Blobs == 0 {
+		return nil, nil
+	}
+
+	err = decodeFields(authInfo, map[protowire.Number]func(field) error{
+		2: func(f field) error {
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error {
+					var denom, amount string
+					err := decodeFields(f.bytes, map[protowire.Number]func(field) error{
+						1: func(f field) error { denom = string(f.bytes); return nil },
+						2: func(f field) error { amount = string(f.bytes); return nil },
+					})
+					if err != nil || denom != "utia" {
+						return err
+					}
+					a, err := strconv.ParseFloat(amount, 64)
+					if err != nil {
+						return fmt.Errorf("parsing fee %q: %w", amount, err)
+					}
+					pfb.Fee += a
+					return nil
+				},
+				2: func(f field) error { pfb.GasLimit = f.varint; return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding transaction fee: %w", err)
+	}
+	return &pfb, nil
+}
+
+// MinGasPrice returns the minimum gas price in utia the consensus node
+// accepts transactions with (cosmos.base.node.v1beta1.Service/Config).
+func (c *Client) MinGasPrice(ctx context.Context) (float64, error) {
+	resp, err := c.query(ctx, "/cosmos.base.node.v1beta1.Service/Config", nil)
+	if err != nil {
+		return 0, err
+	}
+	var price string
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error { price = string(f.bytes); return nil },
+	})
+	if err != nil {
+		return 0, fmt.Errorf("decoding node config: %w", err)
+	}
+	return parseGasPrice(price)
+}
+
+// NetworkMinGasPrice returns the minimum gas price in utia of the network,
+// which applies in addition to the one of the node
+// (celestia.minfee.v1.Query/NetworkMinGasPrice). celestia-app only has it
+// from v2 on.
+func (c *Client) NetworkMinGasPrice(ctx context.Context) (float64, error) {
+	resp, err := c.query(ctx, "/celestia.minfee.v1.Query/NetworkMinGasPrice", nil)
+	if err != nil {
+		return 0, err
+	}
+	var price float64
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) (err error) {
+			price, err = parseDec(string(f.bytes))
+			return err
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("decoding network min gas price: %w", err)
+	}
+	return price, nil
+}
+
+// parseGasPrice parses the utia amount of a list of decimal coins such as
+// 0.002000000000000000utia. Without a utia amount the price is 0.
+func parseGasPrice(coins string) (float64, error) {
+	for _, coin := range strings.Split(coins, ",") {
+		amount := strings.TrimSuffix(strings.TrimSpace(coin), "utia")
+		if amount == strings.TrimSpace(coin) || amount == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing gas price %q: %w", coins, err)
+		}
+		return price, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,250 @@
+package cometrpc
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// Status is the result of the status method.
+type Status struct {
+	NodeInfo struct {
+		Network string `json:"network"`
+		Moniker string `json:"moniker"`
+	} `json:"node_info"`
+	SyncInfo struct {
+		LatestBlockHeight int64 `json:"latest_block_height,string"`
+		CatchingUp        bool  `json:"catching_up"`
+	} `json:"sync_info"`
+	ValidatorInfo struct {
+		Address     string `json:"address"`
+		VotingPower int64  `json:"voting_power,string"`
+	} `json:"validator_info"`
+}
+
+// Mempool is the result of the num_unconfirmed_txs method.
+type Mempool struct {
+	Total      int64 `json:"total,string"`
+	TotalBytes int64 `json:"total_bytes,string"`
+}
+
+// Validator is a member of the active validator set.
+type Validator struct {
+	// Address is the hex encoded consensus address.
+	Address     string `json:"address"`
+	VotingPower int64  `json:"voting_power,string"`
+}
+
+// ValidatorSet is one page of the result of the validators method.
+type ValidatorSet struct {
+	BlockHeight int64       `json:"block_height,string"`
+	Validators  []Validator `json:"validators"`
+	Total       int         `json:"total,string"`
+}
+
+// StakingValidator is the state of a validator in the staking module.
+type StakingValidator struct {
+	OperatorAddress string
+	// ConsensusPubKey is the raw ed25519 consensus public key.
+	ConsensusPubKey []byte
+	Jailed          bool
+	// Status is the bond status: 1 unbonded, 2 unbonding, 3 bonded.
+	Status int32
+	Tokens string
+	// CommissionRate is the current commission as a fraction, e.g. 0.05.
+	CommissionRate float64
+}
+
+// SigningInfo is the liveness record of a validator in the slashing module.
+type SigningInfo struct {
+	Address     string
+	StartHeight int64
+	IndexOffset int64
+	// JailedUntil is the Unix time until which the validator stays
+	// jailed, 0 or less if it was never jailed.
+	JailedUntil         int64
+	Tombstoned          bool
+	MissedBlocksCounter int64
+}
+
+// SlashingParams are the parameters of the slashing module.
+type SlashingParams struct {
+	SignedBlocksWindow int64
+}
+
+// StakingPool is the stake of all validators in utia.
+type StakingPool struct {
+	BondedTokens    float64
+	NotBondedTokens float64
+}
+
+// ValidatorRewards are the rewards of a validator in the distribution
+// module that haven't been withdrawn, in utia.
+type ValidatorRewards struct {
+	// Outstanding is the rewards of the validator and its delegators.
+	Outstanding float64
+	// Commission is the part of Outstanding that is the validator's
+	// commission.
+	Commission float64
+}
+
+// BlobParams are the parameters of the blob module of celestia-app.
+type BlobParams struct {
+	GasPerBlobByte   uint32
+	GovMaxSquareSize uint64
+}
+
+// BlockID identifies the block at a height by its hash.
+type BlockID struct {
+	Height int64
+	Hash   string
+}
+
+// Block is the subset of a block the client decodes: who proposed it, its
+// transactions and which validators signed the previous block.
+type Block struct {
+	Header struct {
+		Height int64 `json:"height,string"`
+		// ProposerAddress is the hex encoded consensus address of the
+		// proposer.
+		ProposerAddress string `json:"proposer_address"`
+	} `json:"header"`
+	Data struct {
+		Txs [][]byte `json:"txs"`
+	} `json:"data"`
+	LastCommit struct {
+		Height     int64             `json:"height,string"`
+		Signatures []CommitSignature `json:"signatures"`
+	} `json:"last_commit"`
+}
+
+// Block ID flags of a commit signature.
+const (
+	BlockIDFlagAbsent = 1
+	BlockIDFlagCommit = 2
+	BlockIDFlagNil    = 3
+)
+
+// CommitSignature is a validator's vote for a block.
+type CommitSignature struct {
+	BlockIDFlag      int    `json:"block_id_flag"`
+	ValidatorAddress string `json:"validator_address"`
+}
+
+// Signed reports whether the hex encoded consensus address voted for the
+// committed block.
+func (b *Block) Signed(address string) bool {
+	for _, sig := range b.LastCommit.Signatures {
+		if sig.BlockIDFlag == BlockIDFlagCommit && strings.EqualFold(sig.ValidatorAddress, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockResults are the block events of the result of the block_results
+// method: those of BeginBlock and EndBlock up to CometBFT 0.37 and those of
+// FinalizeBlock since 0.38. Events of transactions are not included.
+type BlockResults struct {
+	Height int64
+	Events []Event
+}
+
+// Event is an ABCI event, e.g. a slash of the slashing module.
+type Event struct {
+	Type       string           `json:"type"`
+	Attributes []EventAttribute `json:"attributes"`
+}
+
+// EventAttribute is a key-value pair of an event.
+type EventAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Attribute returns the value of the first attribute with key and whether
+// the event has one.
+func (e Event) Attribute(key string) (string, bool) {
+	for _, a := range e.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// decoded returns the event with base64 decoded attributes if they are
+// encoded, as CometBFT 0.34, the base of celestia-core, returns them.
+// Keys are identifiers, so they are only taken as encoded if all of them
+// decode to one.
+func (e Event) decoded() Event {
+	if len(e.Attributes) == 0 {
+		return e
+	}
+	attrs := make([]EventAttribute, len(e.Attributes))
+	for i, a := range e.Attributes {
+		key, err := base64.StdEncoding.DecodeString(a.Key)
+		if err != nil || !isIdentifier(string(key)) {
+			return e
+		}
+		value, err := base64.StdEncoding.DecodeString(a.Value)
+		if err != nil {
+			return e
+		}
+		attrs[i] = EventAttribute{Key: string(key), Value: string(value)}
+	}
+	return Event{Type: e.Type, Attributes: attrs}
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Proposal is a governance proposal of the gov module.
+type Proposal struct {
+	ID uint64
+	// VotingEndTime is the Unix time the voting period ends.
+	VotingEndTime int64
+}
+
+// Upgrade is a scheduled upgrade of the network.
+type Upgrade struct {
+	// Name is the name of the upgrade plan, or v<app version> for an
+	// upgrade of the signal module.
+	Name string
+	// Height is the height the upgrade takes effect at: the chain halts
+	// there for an upgrade plan and switches to the new app version for a
+	// signalled upgrade.
+	Height int64
+}
+
+// IBCChannelOpen is the state of an open IBC channel.
+const IBCChannelOpen = 3
+
+// IBCChannel is the state of an IBC channel end.
+type IBCChannel struct {
+	// State is the channel state: 1 init, 2 try-open, 3 open, 4 closed.
+	State               int32
+	CounterpartyChannel string
+}
+
+// IBCClient is an IBC Tendermint light client of another chain.
+type IBCClient struct {
+	ClientID string
+	// ChainID is the chain the client tracks.
+	ChainID      string
+	LatestHeight int64
+	Frozen       bool
+	// LastUpdate is the Unix time of the header of the latest update.
+	LastUpdate int64
+	// Expiry is the Unix time the client expires at unless it is updated:
+	// LastUpdate plus the trusting period.
+	Expiry int64
+}
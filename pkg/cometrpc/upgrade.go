@@ -0,0 +1,58 @@
+package cometrpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// UpgradePlan returns the software upgrade scheduled by governance in the
+// upgrade module, or nil if there is none
+// (cosmos.upgrade.v1beta1.Query/CurrentPlan).
+func (c *Client) UpgradePlan(ctx context.Context) (*Upgrade, error) {
+	resp, err := c.query(ctx, "/cosmos.upgrade.v1beta1.Query/CurrentPlan", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *Upgrade
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			u = &Upgrade{}
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { u.Name = string(f.bytes); return nil },
+				3: func(f field) error { u.Height = int64(f.varint); return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding upgrade plan: %w", err)
+	}
+	return u, nil
+}
+
+// SignalledUpgrade returns the upgrade to a new app version that the
+// validators agreed on through the signal module of celestia-app v2 and
+// later, or nil if there is none (celestia.signal.v1.Query/GetUpgrade).
+func (c *Client) SignalledUpgrade(ctx context.Context) (*Upgrade, error) {
+	resp, err := c.query(ctx, "/celestia.signal.v1.Query/GetUpgrade", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *Upgrade
+	err = decodeFields(resp, map[protowire.Number]func(field) error{
+		1: func(f field) error {
+			u = &Upgrade{}
+			return decodeFields(f.bytes, map[protowire.Number]func(field) error{
+				1: func(f field) error { u.Name = fmt.Sprintf("v%d", f.varint); return nil },
+				2: func(f field) error { u.Height = int64(f.varint); return nil },
+			})
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding signalled upgrade: %w", err)
+	}
+	return u, nil
+}
@@ -0,0 +1,449 @@
+// Package mocknode simulates a celestia-node behind its JSON-RPC API. The
+// chain of a Node advances in real time, one block every block time, and
+// the node now and then stops syncing for some blocks and catches up again
+// afterwards, so dashboards and alert rules can be developed, and the
+// exporter tested end to end, without a real node.
+package mocknode
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// startHeight is the network height of a new Node.
+const startHeight = 1500000
+
+// blobRetention is the number of blocks the blobs submitted to a Node are
+// kept for.
+const blobRetention = 1000
+
+// Node is a simulated celestia-node. It is an http.Handler serving the
+// JSON-RPC API of the node, including batch requests; methods it doesn't
+// simulate are answered with the method not found error.
+type Node struct {
+	nodeType  celestiarpc.NodeType
+	chainID   string
+	blockTime time.Duration
+	// genesis is the time of height 0, so that the network height is the
+	// number of block times since.
+	genesis time.Time
+	// account is the address of the node's account.
+	account string
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	network  uint64
+	local    uint64
+	sampled  uint64
+	stall    int
+	peers    []string
+	bytesIn  float64
+	bytesOut float64
+	balance  int64
+	blobs    map[uint64][]*celestiarpc.Blob
+}
+
+// New returns a simulated node of type nodeType on the chain chainID. The
+// seed makes the simulation, e.g. when the node falls behind, repeatable.
+func New(nodeType celestiarpc.NodeType, chainID string, blockTime time.Duration, seed int64) *Node {
+	n := &Node{
+		nodeType:  nodeType,
+		chainID:   chainID,
+		blockTime: blockTime,
+		genesis:   time.Now().Add(-startHeight * blockTime),
+		account:   fmt.Sprintf("celestia1mock%s%x", nodeType, seed),
+		rand:      rand.New(rand.NewSource(seed)),
+		network:   startHeight,
+		local:     startHeight,
+		sampled:   startHeight,
+		balance:   42000000,
+		blobs:     make(map[uint64][]*celestiarpc.Blob),
+	}
+	for i := 0; i < n.peerRange()[0]+n.rand.Intn(5); i++ {
+		n.peers = append(n.peers, n.newPeerID())
+	}
+	return n
+}
+
+// peerRange returns the minimum and the maximum number of peers of the
+// node type.
+func (n *Node) peerRange() [2]int {
+	switch n.nodeType {
+	case celestiarpc.NodeTypeBridge:
+		return [2]int{30, 60}
+	case celestiarpc.NodeTypeFull:
+		return [2]int{15, 40}
+	}
+	return [2]int{4, 12}
+}
+
+// rates returns the bytes per second the node type receives and sends.
+func (n *Node) rates() (in, out float64) {
+	switch n.nodeType {
+	case celestiarpc.NodeTypeBridge:
+		return 2 << 20, 6 << 20
+	case celestiarpc.NodeTypeFull:
+		return 1 << 20, 512 << 10
+	}
+	return 64 << 10, 8 << 10
+}
+
+func (n *Node) newPeerID() string {
+	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	id := make([]byte, 44)
+	for i := range id {
+		id[i] = alphabet[n.rand.Intn(len(alphabet))]
+	}
+	return "12D3KooW" + string(id)
+}
+
+// advance simulates the blocks produced since the last call, the caller
+// holds n.mu. While the node stalls its local head stays where it is,
+// afterwards it syncs up to four blocks per block until it has caught up.
+func (n *Node) advance(now time.Time) {
+	network := uint64(now.Sub(n.genesis) / n.blockTime)
+	for ; n.network < network; n.network++ {
+		switch {
+		case n.stall > 0:
+			n.stall--
+		case n.local+1 >= n.network && n.rand.Intn(100) == 0:
+			n.stall = 5 + n.rand.Intn(20)
+		default:
+			n.local += 4
+			if n.local > n.network+1 {
+				n.local = n.network + 1
+			}
+		}
+		// Sampling trails the local head by a block or two.
+		if n.local > n.sampled+uint64(n.rand.Intn(3)) {
+			n.sampled = n.local - uint64(n.rand.Intn(2))
+		}
+
+		peers := n.peerRange()
+		switch d := n.rand.Intn(5) - 2; {
+		case d > 0 && len(n.peers) < peers[1]:
+			n.peers = append(n.peers, n.newPeerID())
+		case d < 0 && len(n.peers) > peers[0]:
+			i := n.rand.Intn(len(n.peers))
+			n.peers = append(n.peers[:i], n.peers[i+1:]...)
+		}
+
+		in, out := n.rates()
+		jitter := 0.5 + n.rand.Float64()
+		n.bytesIn += in * jitter * n.blockTime.Seconds()
+		n.bytesOut += out * jitter * n.blockTime.Seconds()
+	}
+}
+
+func (n *Node) hash(height uint64) string {
+	return fmt.Sprintf("%X", sha256.Sum256([]byte(fmt.Sprintf("%s/%d", n.chainID, height))))
+}
+
+func (n *Node) header(height uint64) *celestiarpc.ExtendedHeader {
+	h := &celestiarpc.ExtendedHeader{}
+	h.Header.ChainID = n.chainID
+	h.Header.Height = height
+	h.Header.Time = n.genesis.Add(time.Duration(height) * n.blockTime).UTC()
+	h.Header.LastBlockID.Hash = n.hash(height - 1)
+	h.Commit.BlockID.Hash = n.hash(height)
+	return h
+}
+
+// share returns a fake share of a height, 512 bytes like a real one.
+func (n *Node) share(height uint64, row, col int) []byte {
+	share := make([]byte, 512)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%d/%d", n.chainID, height, row, col)))
+	for i := range share {
+		share[i] = sum[i%len(sum)]
+	}
+	return share
+}
+
+// errHeightNotFound is the error of celestia-node for heights above its
+// local head.
+func errHeightNotFound(height, local uint64) *rpcError {
+	return &rpcError{Code: 1, Message: fmt.Sprintf("header: given height is from the future: networkHeight: %d, requestedHeight: %d", local, height)}
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// storeBlobs keeps blobs as included at height and forgets those older
+// than blobRetention.
+func (n *Node) storeBlobs(height uint64, blobs []*celestiarpc.Blob) {
+	n.blobs[height] = append(n.blobs[height], blobs...)
+	for h := range n.blobs {
+		if h+blobRetention < n.local {
+			delete(n.blobs, h)
+		}
+	}
+}
+
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var out interface{}
+	var batch []request
+	if err := json.Unmarshal(body, &batch); err == nil {
+		resps := make([]response, len(batch))
+		for i, req := range batch {
+			resps[i] = n.handle(req)
+		}
+		out = resps
+	} else {
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			out = response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: celestiarpc.CodeParseError, Message: err.Error()}}
+		} else {
+			out = n.handle(req)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func (n *Node) handle(req request) response {
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	result, err := n.call(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err
+	} else {
+		resp.Result = result
+	}
+	return resp
+}
+
+// call answers method, decoding its params into the arguments of the
+// method.
+func (n *Node) call(method string, params []json.RawMessage) (interface{}, *rpcError) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.advance(time.Now())
+
+	var (
+		height uint64
+		arg    string
+	)
+	decode := func(args ...interface{}) *rpcError {
+		if len(params) < len(args) {
+			return &rpcError{Code: celestiarpc.CodeInvalidParams, Message: fmt.Sprintf("expected %d params, got %d", len(args), len(params))}
+		}
+		for i, a := range args {
+			if err := json.Unmarshal(params[i], a); err != nil {
+				return &rpcError{Code: celestiarpc.CodeInvalidParams, Message: fmt.Sprintf("unmarshaling param %d: %v", i, err)}
+			}
+		}
+		return nil
+	}
+
+	switch method {
+	case "node.Info":
+		return celestiarpc.NodeInfo{Type: n.nodeType, APIVersion: "v0.20.4-mock"}, nil
+	case "header.LocalHead":
+		return n.header(n.local), nil
+	case "header.NetworkHead":
+		return n.header(n.network), nil
+	case "header.GetByHeight":
+		if err := decode(&height); err != nil {
+			return nil, err
+		}
+		if height == 0 || height > n.local {
+			return nil, errHeightNotFound(height, n.local)
+		}
+		return n.header(height), nil
+	case "das.SamplingStats":
+		if n.nodeType == celestiarpc.NodeTypeBridge {
+			return nil, &rpcError{Code: 1, Message: "moddas: dasing is not available on bridge nodes"}
+		}
+		return celestiarpc.SamplingStats{
+			SampledChainHead: n.sampled,
+			CatchupHead:      n.sampled,
+			NetworkHead:      n.network,
+			Workers: []celestiarpc.WorkerStats{
+				{JobType: "recent", Curr: n.sampled, From: n.sampled, To: n.local},
+			},
+			Concurrency: 16,
+			CatchUpDone: n.sampled+2 >= n.network,
+			IsRunning:   true,
+		}, nil
+	case "p2p.Peers":
+		return append([]string(nil), n.peers...), nil
+	case "p2p.PeerInfo":
+		if err := decode(&arg); err != nil {
+			return nil, err
+		}
+		for i, id := range n.peers {
+			if id == arg {
+				// Addresses of the documentation ranges, which don't
+				// belong to anyone.
+				return celestiarpc.PeerInfo{ID: id, Addrs: []string{fmt.Sprintf("/ip4/203.0.113.%d/tcp/2121", i%254+1)}}, nil
+			}
+		}
+		return nil, &rpcError{Code: 1, Message: "peer not found"}
+	case "p2p.BandwidthStats":
+		in, out := n.rates()
+		return celestiarpc.BandwidthStats{TotalIn: int64(n.bytesIn), TotalOut: int64(n.bytesOut), RateIn: in, RateOut: out}, nil
+	case "p2p.BandwidthForProtocol":
+		if err := decode(&arg); err != nil {
+			return nil, err
+		}
+		// Every protocol gets a stable share of the traffic.
+		share := float64(sha256.Sum256([]byte(arg))[0]%20+1) / 100
+		in, out := n.rates()
+		return celestiarpc.BandwidthStats{
+			TotalIn:  int64(n.bytesIn * share),
+			TotalOut: int64(n.bytesOut * share),
+			RateIn:   in * share,
+			RateOut:  out * share,
+		}, nil
+	case "p2p.NATStatus":
+		return celestiarpc.ReachabilityPublic, nil
+	case "state.AccountAddress":
+		return n.account, nil
+	case "state.Balance":
+		return celestiarpc.Balance{Denom: "utia", Amount: fmt.Sprint(n.balance)}, nil
+	case "state.BalanceForAddress":
+		if err := decode(&arg); err != nil {
+			return nil, err
+		}
+		return celestiarpc.Balance{Denom: "utia", Amount: fmt.Sprint(n.balance)}, nil
+	case "state.SubmitPayForBlob":
+		var blobs []*celestiarpc.Blob
+		if err := decode(&blobs); err != nil {
+			return nil, err
+		}
+		// Like celestia-node, the blobs are only answered once they are
+		// included, here in the last block the node synced.
+		included := n.local
+		for _, b := range blobs {
+			sum := sha256.Sum256(append(append([]byte{}, b.Namespace...), b.Data...))
+			b.Commitment = sum[:]
+		}
+		n.storeBlobs(included, blobs)
+		const gasUsed = 80000
+		n.balance -= gasUsed * 2 / 1000
+		return celestiarpc.TxResponse{
+			Height:    int64(included),
+			TxHash:    n.hash(included)[:32],
+			GasWanted: gasUsed * 11 / 10,
+			GasUsed:   gasUsed,
+		}, nil
+	case "blob.Submit":
+		var blobs []*celestiarpc.Blob
+		if err := decode(&blobs); err != nil {
+			return nil, err
+		}
+		// The commitments are computed like celestia-node does, so blobs
+		// are found by the commitments clients compute.
+		included := n.local
+		for i, b := range blobs {
+			nb, err := celestiarpc.NewBlob(b.Namespace, b.Data)
+			if err != nil {
+				return nil, &rpcError{Code: 1, Message: err.Error()}
+			}
+			blobs[i] = nb
+		}
+		n.storeBlobs(included, blobs)
+		n.balance -= int64(celestiarpc.EstimateGas(blobs...)) * 2 / 1000
+		return included, nil
+	case "blob.GetAll":
+		var namespaces [][]byte
+		if err := decode(&height, &namespaces); err != nil {
+			return nil, err
+		}
+		if height > n.local {
+			return nil, errHeightNotFound(height, n.local)
+		}
+		var found []*celestiarpc.Blob
+		for _, b := range n.blobs[height] {
+			for _, ns := range namespaces {
+				if string(ns) == string(b.Namespace) {
+					found = append(found, b)
+				}
+			}
+		}
+		if len(found) == 0 {
+			return nil, &rpcError{Code: 1, Message: "blob: not found"}
+		}
+		return found, nil
+	case "blob.Get":
+		var namespace, commitment []byte
+		if err := decode(&height, &namespace, &commitment); err != nil {
+			return nil, err
+		}
+		if height > n.local {
+			return nil, errHeightNotFound(height, n.local)
+		}
+		for _, b := range n.blobs[height] {
+			if string(b.Namespace) == string(namespace) && string(b.Commitment) == string(commitment) {
+				return b, nil
+			}
+		}
+		return nil, &rpcError{Code: 1, Message: "blob: not found"}
+	case "share.SharesAvailable":
+		if err := decode(&height); err != nil {
+			return nil, err
+		}
+		if height == 0 || height > n.local {
+			return nil, errHeightNotFound(height, n.local)
+		}
+		return nil, nil
+	case "share.GetShare":
+		var row, col int
+		if err := decode(&height, &row, &col); err != nil {
+			return nil, err
+		}
+		if height == 0 || height > n.local {
+			return nil, errHeightNotFound(height, n.local)
+		}
+		return n.share(height, row, col), nil
+	case "share.GetEDS":
+		if err := decode(&height); err != nil {
+			return nil, err
+		}
+		if height == 0 || height > n.local {
+			return nil, errHeightNotFound(height, n.local)
+		}
+		// The smallest square, 2x2 original shares extended to 4x4.
+		eds := celestiarpc.ExtendedDataSquare{}
+		for row := 0; row < 4; row++ {
+			for col := 0; col < 4; col++ {
+				eds.DataSquare = append(eds.DataSquare, n.share(height, row, col))
+			}
+		}
+		return eds, nil
+	}
+	return nil, &rpcError{Code: celestiarpc.CodeMethodNotFound, Message: fmt.Sprintf("method '%s' not found", method)}
+}
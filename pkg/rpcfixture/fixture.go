@@ -0,0 +1,268 @@
+// Package rpcfixture records the JSON-RPC exchanges with a celestia-node to
+// a fixture file and replays them, so integration tests run against real
+// node behavior deterministically and bug reports can include the
+// responses of the node that showed the bug.
+//
+// A fixture holds one exchange per line, in the order the node answered
+// them, with the method, the params and the result or the error of the
+// node. Authorization headers are never recorded.
+package rpcfixture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+// Exchange is a single call of a method and the node's answer, a line of
+// a fixture.
+type Exchange struct {
+	Time   time.Time       `json:"time"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// decodeBatch decodes a batch of JSON-RPC messages into v, a pointer to a
+// slice, or a single message into one, and reports whether data was a
+// batch.
+func decodeBatch(data []byte, v interface{}, one interface{}) (bool, error) {
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		return true, json.Unmarshal(data, v)
+	}
+	return false, json.Unmarshal(data, one)
+}
+
+// Recorder is an http.Handler proxying JSON-RPC requests to a node and
+// writing every exchange to a fixture.
+type Recorder struct {
+	upstream string
+	client   *http.Client
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder returns a Recorder forwarding the requests, with their
+// headers, to the node at upstream with client and writing the exchanges
+// to w.
+func NewRecorder(upstream string, client *http.Client, w io.Writer) *Recorder {
+	return &Recorder{upstream: upstream, client: client, enc: json.NewEncoder(w)}
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed, only JSON-RPC over HTTP POST is recorded", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, rec.upstream, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+	resp, err := rec.client.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		rec.record(body, respBody)
+	}
+	for _, h := range []string{"Content-Type", "Content-Length"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+}
+
+// record writes the exchanges of a request and its response, matched by
+// JSON-RPC ID. Requests and responses that aren't valid JSON-RPC are left
+// out.
+func (rec *Recorder) record(reqBody, respBody []byte) {
+	var reqs []request
+	var req request
+	if batch, err := decodeBatch(reqBody, &reqs, &req); err != nil {
+		return
+	} else if !batch {
+		reqs = []request{req}
+	}
+	var resps []response
+	var resp response
+	if batch, err := decodeBatch(respBody, &resps, &resp); err != nil {
+		return
+	} else if !batch {
+		resps = []response{resp}
+	}
+	byID := make(map[string]response, len(resps))
+	for _, resp := range resps {
+		byID[string(resp.ID)] = resp
+	}
+
+	now := time.Now().UTC()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, req := range reqs {
+		resp, ok := byID[string(req.ID)]
+		if !ok {
+			continue
+		}
+		rec.enc.Encode(Exchange{Time: now, Method: req.Method, Params: normalizeParams(req.Params), Result: resp.Result, Error: resp.Error})
+	}
+}
+
+// normalizeParams returns params in compact form, with missing params as
+// an empty list, so that equal params of recorded and replayed requests
+// compare equal.
+func normalizeParams(params json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if len(params) == 0 || json.Compact(&buf, params) != nil || buf.String() == "null" {
+		return json.RawMessage("[]")
+	}
+	return buf.Bytes()
+}
+
+// Replayer is an http.Handler answering JSON-RPC requests with the
+// responses of a fixture. The calls of a method with the same params are
+// answered with the recorded responses in turn, the last one once they are
+// used up, so the heights of a node advance as they did while recording
+// and then stay. Methods that weren't recorded are answered with the
+// method not found error.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]Exchange
+	methods   map[string]bool
+}
+
+// Load reads a fixture written by a Recorder.
+func Load(r io.Reader) (*Replayer, error) {
+	rp := &Replayer{exchanges: make(map[string][]Exchange), methods: make(map[string]bool)}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var ex Exchange
+		if err := json.Unmarshal(sc.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ex.Method == "" {
+			return nil, fmt.Errorf("line %d: no method", line)
+		}
+		key := ex.Method + " " + string(normalizeParams(ex.Params))
+		rp.exchanges[key] = append(rp.exchanges[key], ex)
+		rp.methods[ex.Method] = true
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(rp.methods) == 0 {
+		return nil, fmt.Errorf("no exchanges")
+	}
+	return rp, nil
+}
+
+func (rp *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var out interface{}
+	var reqs []request
+	var req request
+	batch, err := decodeBatch(body, &reqs, &req)
+	switch {
+	case err != nil:
+		out = response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: rpcError(celestiarpc.CodeParseError, err.Error())}
+	case batch:
+		resps := make([]response, len(reqs))
+		for i, req := range reqs {
+			resps[i] = rp.answer(req)
+		}
+		out = resps
+	default:
+		out = rp.answer(req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+func (rp *Replayer) answer(req request) response {
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	params := normalizeParams(req.Params)
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	key := req.Method + " " + string(params)
+	exchanges := rp.exchanges[key]
+	switch {
+	case len(exchanges) > 0:
+		ex := exchanges[0]
+		if len(exchanges) > 1 {
+			rp.exchanges[key] = exchanges[1:]
+		}
+		resp.Result, resp.Error = ex.Result, ex.Error
+		if resp.Result == nil && resp.Error == nil {
+			resp.Result = json.RawMessage("null")
+		}
+	case rp.methods[req.Method]:
+		resp.Error = rpcError(1, fmt.Sprintf("rpcfixture: no recorded response to %s with params %s", req.Method, params))
+	default:
+		resp.Error = rpcError(celestiarpc.CodeMethodNotFound, fmt.Sprintf("method '%s' not found", req.Method))
+	}
+	return resp
+}
+
+func rpcError(code int, message string) json.RawMessage {
+	data, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, message})
+	return data
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	probePeerUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_probe_peer_up",
+		Help: "1 if the TCP connect to the probed peer succeeded, 0 otherwise",
+	}, append(targetLabels, "peer"))
+	probePeerRTT = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "celestia_probe_peer_rtt_seconds",
+		Help:    "Time the TCP connect to the probed peer took",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, append(targetLabels, "peer"))
+)
+
+func init() {
+	addTargetMetrics(probePeerUp, probePeerRTT)
+	registerCollector("probe", newProbeCollector)
+}
+
+// probeAddress returns the host:port to dial for a peer given as TCP
+// multiaddr, e.g. /dns4/da-bootstrapper-1.celestia-bootstrap.net/tcp/2121
+// with an optional /p2p/<id>, or as host:port.
+func probeAddress(peer string) (string, error) {
+	if !strings.HasPrefix(peer, "/") {
+		if _, _, err := net.SplitHostPort(peer); err != nil {
+			return "", fmt.Errorf("invalid peer %q: %w", peer, err)
+		}
+		return peer, nil
+	}
+	parts := strings.Split(peer, "/")
+	if len(parts) < 5 || parts[3] != "tcp" {
+		return "", fmt.Errorf("invalid peer %q, must be a TCP multiaddr like /dns4/host/tcp/2121 or host:port", peer)
+	}
+	switch parts[1] {
+	case "ip4", "ip6", "dns", "dns4", "dns6":
+		return net.JoinHostPort(parts[2], parts[4]), nil
+	}
+	return "", fmt.Errorf("invalid peer %q, unsupported protocol %s", peer, parts[1])
+}
+
+// probeCollector dials the bootstrappers and trusted peers of the target
+// from the exporter. When the node loses its peers while these stay
+// reachable, the problem is the node's rather than the network's.
+type probeCollector struct {
+	target target
+}
+
+func newProbeCollector(_ *celestiarpc.Client, t target) Collector {
+	if len(t.ProbePeers) == 0 {
+		return nil
+	}
+	return &probeCollector{target: t}
+}
+
+func (c *probeCollector) Name() string { return "probe" }
+
+func (c *probeCollector) Collect(ctx context.Context) error {
+	t := c.target
+	var wg sync.WaitGroup
+	for _, peer := range t.ProbePeers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			// The peers were checked when the target was configured.
+			address, _ := probeAddress(peer)
+			start := time.Now()
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", address)
+			if err != nil {
+				probePeerUp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, peer).Set(0)
+				return
+			}
+			rtt := time.Since(start)
+			conn.Close()
+			probePeerUp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, peer).Set(1)
+			probePeerRTT.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, peer).Observe(rtt.Seconds())
+		}(peer)
+	}
+	wg.Wait()
+	// An unreachable peer is what the metrics report, not a failure of
+	// the collector.
+	return nil
+}
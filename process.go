@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	processUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_up",
+		Help: "Whether the node process was found (1) or not (0)",
+	}, targetLabels)
+	processCPU = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_cpu_seconds",
+		Help: "User and system CPU time spent by the node process in seconds",
+	}, targetLabels)
+	processRSS = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_resident_memory_bytes",
+		Help: "Resident memory size of the node process in bytes",
+	}, targetLabels)
+	processOpenFDs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_open_fds",
+		Help: "Number of open file descriptors of the node process",
+	}, targetLabels)
+	processMaxFDs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_max_fds",
+		Help: "Maximum number of open file descriptors of the node process",
+	}, targetLabels)
+	processStartTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_start_time_seconds",
+		Help: "Start time of the node process as a Unix time",
+	}, targetLabels)
+	processUptime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_node_process_uptime_seconds",
+		Help: "Seconds since the node process started",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(processUp, processCPU, processRSS, processOpenFDs, processMaxFDs, processStartTime, processUptime)
+	registerCollector("process", newProcessCollector)
+}
+
+// processCollector reads the resource usage of the node process from /proc,
+// so one scrape covers both the node and its host. The process is looked up
+// on every run, by PIDFile or else by ProcessName, to follow restarts.
+type processCollector struct {
+	target target
+}
+
+func newProcessCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.PIDFile == "" && t.ProcessName == "" {
+		return nil
+	}
+	return &processCollector{target: t}
+}
+
+func (c *processCollector) Name() string { return "process" }
+
+func (c *processCollector) Collect(ctx context.Context) error {
+	t := c.target
+	proc, err := c.find()
+	if err != nil {
+		processUp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(0)
+		return err
+	}
+
+	stat, err := proc.Stat()
+	if err != nil {
+		processUp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(0)
+		return fmt.Errorf("reading stat of process %d: %w", proc.PID, err)
+	}
+	processUp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(1)
+	processCPU.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(stat.CPUTime())
+	processRSS.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(stat.ResidentMemory()))
+	if start, err := stat.StartTime(); err == nil {
+		processStartTime.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(start)
+		processUptime.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(time.Now().UnixNano())/1e9 - start)
+	}
+
+	// Reading the file descriptors of a process of another user requires
+	// root or CAP_SYS_PTRACE.
+	fds, err := proc.FileDescriptorsLen()
+	if err != nil {
+		return fmt.Errorf("reading file descriptors of process %d: %w", proc.PID, err)
+	}
+	processOpenFDs.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(fds))
+	if limits, err := proc.Limits(); err == nil {
+		processMaxFDs.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(limits.OpenFiles))
+	}
+	return nil
+}
+
+// find returns the node process.
+func (c *processCollector) find() (procfs.Proc, error) {
+	t := c.target
+	if t.PIDFile != "" {
+		data, err := os.ReadFile(t.PIDFile)
+		if err != nil {
+			return procfs.Proc{}, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return procfs.Proc{}, fmt.Errorf("invalid pid file %s: %w", t.PIDFile, err)
+		}
+		return procfs.NewProc(pid)
+	}
+
+	procs, err := procfs.AllProcs()
+	if err != nil {
+		return procfs.Proc{}, err
+	}
+	var found []procfs.Proc
+	for _, p := range procs {
+		if processNamed(p, t.ProcessName) {
+			found = append(found, p)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return procfs.Proc{}, fmt.Errorf("no process named %q found", t.ProcessName)
+	case 1:
+		return found[0], nil
+	}
+	return procfs.Proc{}, fmt.Errorf("%d processes named %q found, select one with --node.pid-file", len(found), t.ProcessName)
+}
+
+// processNamed reports whether the command name or the executable in the
+// command line of p is name.
+func processNamed(p procfs.Proc, name string) bool {
+	if comm, err := p.Comm(); err == nil && comm == name {
+		return true
+	}
+	cmdline, err := p.CmdLine()
+	return err == nil && len(cmdline) > 0 && filepath.Base(cmdline[0]) == name
+}
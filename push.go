@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pushgatewaySink replaces the metrics of its job on a Prometheus
+// Pushgateway with every push.
+type pushgatewaySink struct {
+	url      string
+	job      string
+	instance string
+}
+
+func (s *pushgatewaySink) push(ctx context.Context, families []*dto.MetricFamily) error {
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return families, nil })
+	p := push.New(s.url, s.job).Gatherer(g)
+	if s.instance != "" {
+		p = p.Grouping("instance", s.instance)
+	}
+	return p.PushContext(ctx)
+}
+
+// remoteWriteSink sends the metrics to a Prometheus remote_write endpoint,
+// e.g. of Prometheus, Mimir or VictoriaMetrics.
+type remoteWriteSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (s *remoteWriteSink) push(ctx context.Context, families []*dto.MetricFamily) error {
+	body := snappy.Encode(nil, encodeWriteRequest(families, time.Now()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes the families as a remote_write WriteRequest
+// protobuf message.
+func encodeWriteRequest(families []*dto.MetricFamily, now time.Time) []byte {
+	ts := now.UnixMilli()
+	var req []byte
+	flattenFamilies(families, func(name string, labels []seriesLabel, value float64) {
+		all := append([]seriesLabel{{"__name__", name}}, labels...)
+		sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+		var series []byte
+		for _, l := range all {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, lb)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(ts))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, series)
+	})
+	return req
+}
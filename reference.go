@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	referenceHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_reference_height",
+		Help: "Latest height of the reference endpoint",
+	}, targetLabels)
+	referenceHeightDelta = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_reference_height_delta",
+		Help: "Local height of the node minus the latest height of the reference endpoint, negative while the node is behind",
+	}, targetLabels)
+	referenceHashMatch = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_reference_hash_match",
+		Help: "1 if the node and the reference endpoint have the same block hash at the highest height both have, 0 otherwise",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(referenceHeight, referenceHeightDelta, referenceHashMatch)
+	registerCollector("reference", newReferenceCollector)
+}
+
+// referenceCollector compares the node with a trusted CometBFT RPC, e.g. a
+// public one: a growing height delta shows a node stuck on a bad peer set
+// even while it reports itself synced to its peers' head, and differing
+// block hashes a node on a fork.
+type referenceCollector struct {
+	client    *celestiarpc.Client
+	reference *cometrpc.Client
+	target    target
+
+	mismatch bool
+}
+
+func newReferenceCollector(client *celestiarpc.Client, t target) Collector {
+	if t.ReferenceEndpoint == "" {
+		return nil
+	}
+	return &referenceCollector{client: client, reference: cometrpc.New(t.ReferenceEndpoint), target: t}
+}
+
+func (c *referenceCollector) Name() string { return "reference" }
+
+func (c *referenceCollector) Collect(ctx context.Context) error {
+	t := c.target
+	local, err := c.client.LocalHead(ctx)
+	if err != nil {
+		return err
+	}
+	ref, err := c.reference.Commit(ctx, 0)
+	if err != nil {
+		return err
+	}
+	referenceHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(ref.Height))
+	referenceHeightDelta.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(int64(local.Height()) - ref.Height))
+
+	// Compare the hashes at the highest height both have.
+	localHash, refHash := local.Hash(), ref.Hash
+	height := int64(local.Height())
+	switch {
+	case height > ref.Height:
+		h, err := c.client.GetByHeight(ctx, uint64(ref.Height))
+		if err != nil {
+			return err
+		}
+		height, localHash = ref.Height, h.Hash()
+	case height < ref.Height:
+		b, err := c.reference.Commit(ctx, height)
+		if err != nil {
+			return err
+		}
+		refHash = b.Hash
+	}
+	match := strings.EqualFold(localHash, refHash)
+	if !match && !c.mismatch {
+		log.Printf("WARNING: block hash of %s at height %d is %s, the reference endpoint has %s\n", t.Name, height, localHash, refHash)
+	} else if match && c.mismatch {
+		log.Printf("Block hash of %s at height %d matches the reference endpoint again\n", t.Name, height)
+	}
+	c.mismatch = !match
+	referenceHashMatch.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(boolToFloat(match))
+	return nil
+}
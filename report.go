@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"my-celestia-exporter/pkg/s3"
+)
+
+// reportUploader uploads the status of the exporter, as served by
+// /api/v1/status, and the state file to an S3 bucket, so a whole fleet can
+// be audited from the bucket without scraping every host. Under
+// prefix/instance/ it writes status.json, overwritten with every upload,
+// reports/<time>.json, kept for the history, and state.json if
+// --state.file is set.
+type reportUploader struct {
+	client   *s3.Client
+	bucket   string
+	prefix   string
+	instance string
+	status   *statusAPI
+	state    *stateStore
+}
+
+func newReportUploader(client *s3.Client, url, instance string, status *statusAPI, state *stateStore) (*reportUploader, error) {
+	bucket, prefix, err := s3.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &reportUploader{
+		client:   client,
+		bucket:   bucket,
+		prefix:   prefix + instance + "/",
+		instance: instance,
+		status:   status,
+		state:    state,
+	}, nil
+}
+
+// run uploads the report every interval until ctx is done.
+func (u *reportUploader) run(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				uploadCtx, cancel := context.WithTimeout(ctx, interval)
+				err := u.upload(uploadCtx, now)
+				cancel()
+				if err != nil {
+					log.Printf("Error uploading the report to s3://%s/%s: %v\n", u.bucket, u.prefix, err)
+					sinkPushes.WithLabelValues("s3", "failure").Inc()
+					continue
+				}
+				sinkPushes.WithLabelValues("s3", "success").Inc()
+			}
+		}
+	}()
+}
+
+func (u *reportUploader) upload(ctx context.Context, now time.Time) error {
+	s, err := u.status.report()
+	if err != nil {
+		return fmt.Errorf("building the report: %w", err)
+	}
+	report, err := json.MarshalIndent(struct {
+		Instance string    `json:"instance"`
+		Time     time.Time `json:"time"`
+		*apiStatus
+	}{u.instance, now.UTC(), s}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := u.client.Put(ctx, u.bucket, u.prefix+"reports/"+now.UTC().Format("20060102T150405Z")+".json", report, "application/json"); err != nil {
+		return err
+	}
+	if err := u.client.Put(ctx, u.bucket, u.prefix+"status.json", report, "application/json"); err != nil {
+		return err
+	}
+
+	if u.state.path == "" {
+		return nil
+	}
+	state, err := u.state.contents()
+	if err != nil {
+		return fmt.Errorf("encoding the state: %w", err)
+	}
+	return u.client.Put(ctx, u.bucket, u.prefix+"state.json", state, "application/json")
+}
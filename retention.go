@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	earliestAvailableHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_earliest_available_height",
+		Help: "Lowest height whose header and shares the node still serves, 1 on archival nodes",
+	}, targetLabels)
+	retentionWindowBlocks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_retention_window_blocks",
+		Help: "Number of blocks from the earliest available height to the local head",
+	}, targetLabels)
+	retentionWindowSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_retention_window_seconds",
+		Help: "Time between the blocks at the earliest available height and at the local head",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(earliestAvailableHeight, retentionWindowBlocks, retentionWindowSeconds)
+	registerCollector("retention", newRetentionCollector)
+}
+
+// retentionCollector finds the earliest height bridge and full nodes still
+// serve, so that operators of archival nodes notice unintended pruning. The
+// first run binary-searches the heights up to the local head; as pruning
+// only ever moves the earliest height up, later runs check the previous
+// earliest height and only search above it once it is gone.
+type retentionCollector struct {
+	client *celestiarpc.Client
+	target target
+
+	earliest *celestiarpc.ExtendedHeader
+}
+
+func newRetentionCollector(client *celestiarpc.Client, t target) Collector {
+	if !t.collectsHeaders() {
+		return nil
+	}
+	return &retentionCollector{client: client, target: t}
+}
+
+func (c *retentionCollector) Name() string { return "retention" }
+
+func (c *retentionCollector) Collect(ctx context.Context) error {
+	t := c.target
+	head, err := c.client.LocalHead(ctx)
+	if err != nil {
+		return err
+	}
+
+	from := uint64(1)
+	pruned := true
+	if c.earliest != nil {
+		h, err := c.available(ctx, c.earliest.Height())
+		if err != nil {
+			return err
+		}
+		from, pruned = c.earliest.Height()+1, h == nil
+	}
+	if pruned {
+		earliest, err := c.search(ctx, from, head)
+		if err != nil {
+			return err
+		}
+		c.earliest = earliest
+	}
+
+	earliestAvailableHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(c.earliest.Height()))
+	retentionWindowBlocks.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(head.Height() - c.earliest.Height() + 1))
+	retentionWindowSeconds.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(head.Header.Time.Sub(c.earliest.Header.Time).Seconds())
+	return nil
+}
+
+// search returns the header of the lowest available height from lo up to
+// head.
+func (c *retentionCollector) search(ctx context.Context, lo uint64, head *celestiarpc.ExtendedHeader) (*celestiarpc.ExtendedHeader, error) {
+	hi := head.Height()
+	if lo > hi {
+		lo = hi
+	}
+	earliest, err := c.available(ctx, hi)
+	if err != nil {
+		return nil, err
+	}
+	if earliest == nil {
+		return nil, fmt.Errorf("shares of the local head at height %d are not available", hi)
+	}
+	// The heights from hi up are available, the ones below lo are not.
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		h, err := c.available(ctx, mid)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			hi, earliest = mid, h
+		} else {
+			lo = mid + 1
+		}
+	}
+	return earliest, nil
+}
+
+// available returns the header at height if the node serves it and the
+// first share of its extended data square, nil if the node answers that
+// either is not available.
+func (c *retentionCollector) available(ctx context.Context, height uint64) (*celestiarpc.ExtendedHeader, error) {
+	h, err := c.client.GetByHeight(ctx, height)
+	if err != nil {
+		if nodeError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, err := c.client.GetShare(ctx, height, 0, 0); err != nil {
+		if nodeError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return h, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+var (
+	rpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "exporter_rpc_requests_total",
+		Help: "Number of JSON-RPC requests made to the node, by method and HTTP status code (0 if no response was received)",
+	}, append(targetLabels, "method", "code"))
+
+	rpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "exporter_rpc_errors_total",
+		Help: "Number of failed JSON-RPC requests, including JSON-RPC and decoding errors, by method",
+	}, append(targetLabels, "method"))
+
+	rpcRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "exporter_rpc_retries_total",
+		Help: "Number of times a JSON-RPC request was retried after a transient failure, by method",
+	}, append(targetLabels, "method"))
+
+	circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "exporter_circuit_state",
+		Help: "State of the circuit breaker of the node endpoint: 0 closed, 1 open, 2 half-open",
+	}, targetLabels)
+
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "exporter_rpc_duration_seconds",
+		Help:    "Duration of JSON-RPC requests made to the node, by method",
+		Buckets: prometheus.DefBuckets,
+	}, append(targetLabels, "method"))
+)
+
+func init() {
+	addTargetMetrics(rpcRequests, rpcErrors, rpcRetries, rpcDuration, circuitState)
+}
+
+// observeRPC returns a request hook recording the RPC metrics of t.
+func observeRPC(t target) func(celestiarpc.RequestInfo) {
+	return func(info celestiarpc.RequestInfo) {
+		rpcRequests.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, info.Method, strconv.Itoa(info.StatusCode)).Inc()
+		duration := rpcDuration.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, info.Method)
+		if rpcTracer == nil {
+			duration.Observe(info.Duration.Seconds())
+		} else if traceID := rpcTracer.record(t, info); traceID == "" {
+			duration.Observe(info.Duration.Seconds())
+		} else {
+			duration.(prometheus.ExemplarObserver).ObserveWithExemplar(info.Duration.Seconds(), prometheus.Labels{"trace_id": traceID})
+		}
+		if info.Attempts > 1 {
+			rpcRetries.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, info.Method).Add(float64(info.Attempts - 1))
+		}
+		if info.Err != nil {
+			rpcErrors.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, info.Method).Inc()
+		}
+	}
+}
+
+// newCircuitBreaker returns the circuit breaker for t, which exports its
+// state and logs its changes, or nil if threshold is 0.
+func newCircuitBreaker(t target, threshold int, openDuration time.Duration) *celestiarpc.CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	circuitState.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(celestiarpc.CircuitClosed))
+	return celestiarpc.NewCircuitBreaker(threshold, openDuration, func(s celestiarpc.CircuitState) {
+		circuitState.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(s))
+		switch s {
+		case celestiarpc.CircuitOpen:
+			log.Printf("Circuit breaker of %s opened, pausing requests for %s\n", t.Name, openDuration)
+		case celestiarpc.CircuitClosed:
+			log.Printf("Circuit breaker of %s closed, %s is reachable again\n", t.Name, t.Name)
+		}
+	})
+}
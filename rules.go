@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string         `yaml:"name"`
+	Rules []alertingRule `yaml:"rules"`
+}
+
+type alertingRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type ruleThresholds struct {
+	syncLag       int
+	minBalance    float64
+	upgradeBlocks int
+	ibcExpiry     time.Duration
+	snapshotAge   time.Duration
+	downFor       time.Duration
+	stuckFor      time.Duration
+	forDur        time.Duration
+}
+
+// buildRules returns Prometheus alerting rules for the given metrics, which
+// have their registered names, using the metric names of n. Rules are only
+// generated for metrics that exist, e.g. the sync lag rule once per node type
+// with header metrics.
+func buildRules(metrics []metricInfo, th ruleThresholds, n metricNaming) ruleFile {
+	forDur := promDuration(th.forDur)
+	var rules []alertingRule
+	has := func(name string) bool {
+		for _, m := range metrics {
+			if m.name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	if has("exporter_rpc_requests_total") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaNodeDown",
+			Expr:   "sum by (node, endpoint, network) (rate(" + n.selector("exporter_rpc_requests_total", `code="200"`) + "[5m])) == 0",
+			For:    promDuration(th.downFor),
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "Celestia node {{ $labels.node }} is down",
+				"description": "The exporter got no response from {{ $labels.endpoint }} for " + promDuration(th.downFor) + ".",
+			},
+		})
+	}
+
+	if has("exporter_last_success_timestamp_seconds") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaCollectorStuck",
+			Expr:   fmt.Sprintf("time() - %s > %s", n.selector("exporter_last_success_timestamp_seconds"), formatFloat(th.stuckFor.Seconds())),
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "The {{ $labels.collector }} collector of {{ $labels.node }} is stuck",
+				"description": "The {{ $labels.collector }} collector of {{ $labels.node }} has not succeeded for " + promDuration(th.stuckFor) + ", its metrics show old values.",
+			},
+		})
+	}
+
+	for _, nodeType := range []string{nodeTypeBridge, nodeTypeFull, nodeTypeLight} {
+		title := strings.ToUpper(nodeType[:1]) + nodeType[1:]
+		if name := nodeType + "_sync_lag_blocks"; has(name) {
+			rules = append(rules, alertingRule{
+				Alert:  "Celestia" + title + "NodeSyncLag",
+				Expr:   fmt.Sprintf("%s > %d", n.selector(name), th.syncLag),
+				For:    forDur,
+				Labels: map[string]string{"severity": severityWarning},
+				Annotations: map[string]string{
+					"summary":     "Celestia " + nodeType + " node {{ $labels.node }} is not synced",
+					"description": "{{ $labels.node }} is {{ $value }} blocks behind the network head.",
+				},
+			})
+		}
+		if name := nodeType + "_height_stale"; has(name) {
+			rules = append(rules, alertingRule{
+				Alert:  "Celestia" + title + "NodeHeightStale",
+				Expr:   n.selector(name) + " == 1",
+				For:    forDur,
+				Labels: map[string]string{"severity": severityWarning},
+				Annotations: map[string]string{
+					"summary":     "Heights of Celestia " + nodeType + " node {{ $labels.node }} are stale",
+					"description": "The exporter could not fetch the heights of {{ $labels.node }} for " + forDur + ", the height metrics show old values.",
+				},
+			})
+		}
+		if name := nodeType + "_header_gap_detected"; has(name) {
+			rules = append(rules, alertingRule{
+				Alert:  "Celestia" + title + "NodeMissedHeaders",
+				Expr:   n.selector(name) + " == 1",
+				For:    forDur,
+				Labels: map[string]string{"severity": severityWarning},
+				Annotations: map[string]string{
+					"summary":     "Celestia " + nodeType + " node {{ $labels.node }} has gaps in its header chain",
+					"description": "The most recent headers of {{ $labels.node }} are not linked by height and hash.",
+				},
+			})
+		}
+	}
+
+	if has("celestia_wallet_balance_utia") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaLowBalance",
+			Expr:   fmt.Sprintf("%s < %s", n.selector("celestia_wallet_balance_utia"), formatFloat(th.minBalance)),
+			For:    forDur,
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "Low balance of {{ $labels.address }}",
+				"description": "{{ $labels.address }} on {{ $labels.node }} holds {{ $value }} utia, below " + formatFloat(th.minBalance) + " utia.",
+			},
+		})
+	}
+
+	if has("celestia_validator_jailed") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaValidatorJailed",
+			Expr:   n.selector("celestia_validator_jailed") + " == 1",
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "Celestia validator {{ $labels.validator }} is jailed",
+				"description": "{{ $labels.validator }} was jailed after a slash and no longer signs blocks until it is unjailed.",
+			},
+		})
+	}
+
+	if has("celestia_validator_slashes_total") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaValidatorSlashed",
+			Expr:   "increase(" + n.selector("celestia_validator_slashes_total") + "[10m]) > 0",
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "Celestia validator {{ $labels.validator }} was slashed",
+				"description": "{{ $labels.validator }} was slashed for {{ $labels.reason }} in the last 10 minutes.",
+			},
+		})
+	}
+
+	if has("celestia_gov_validator_voted") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaGovVoteMissing",
+			Expr:   n.selector("celestia_gov_validator_voted") + " == 0 and on (node, endpoint, proposal) (" + n.selector("celestia_gov_proposal_voting_end_timestamp_seconds") + " - time() < 86400)",
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "Celestia validator {{ $labels.validator }} has not voted on proposal {{ $labels.proposal }}",
+				"description": "The voting period of proposal {{ $labels.proposal }} ends in less than a day and {{ $labels.validator }} has not voted.",
+			},
+		})
+	}
+
+	if has("celestia_upgrade_blocks_remaining") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaUpgradeSoon",
+			Expr:   fmt.Sprintf("%s <= %d", n.selector("celestia_upgrade_blocks_remaining"), th.upgradeBlocks),
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "Celestia upgrade {{ $labels.upgrade }} is near",
+				"description": "Upgrade {{ $labels.upgrade }} of {{ $labels.network }} takes effect in {{ $value }} blocks, the nodes need to run a release supporting it.",
+			},
+		})
+	}
+
+	if has("celestia_ibc_channel_state") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaIBCChannelNotOpen",
+			Expr:   fmt.Sprintf("%s != %d", n.selector("celestia_ibc_channel_state"), cometrpc.IBCChannelOpen),
+			For:    forDur,
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "IBC channel {{ $labels.port }}/{{ $labels.channel }} is not open",
+				"description": "IBC channel {{ $labels.port }}/{{ $labels.channel }} of {{ $labels.network }} is in state {{ $value }}.",
+			},
+		})
+	}
+
+	if has("celestia_ibc_client_expiry_timestamp_seconds") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaIBCClientExpiring",
+			Expr:   fmt.Sprintf("%s - time() < %s", n.selector("celestia_ibc_client_expiry_timestamp_seconds"), formatFloat(th.ibcExpiry.Seconds())),
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "IBC client {{ $labels.client }} to {{ $labels.counterparty_chain }} is about to expire",
+				"description": "The light client of channel {{ $labels.port }}/{{ $labels.channel }} expires within " + promDuration(th.ibcExpiry) + " unless a relayer updates it.",
+			},
+		})
+	}
+
+	if has("celestia_ibc_client_frozen") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaIBCClientFrozen",
+			Expr:   n.selector("celestia_ibc_client_frozen") + " == 1",
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "IBC client {{ $labels.client }} to {{ $labels.counterparty_chain }} is frozen",
+				"description": "The light client of channel {{ $labels.port }}/{{ $labels.channel }} was frozen after misbehaviour of {{ $labels.counterparty_chain }}.",
+			},
+		})
+	}
+
+	if has("celestia_probe_peer_up") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaBootstrappersUnreachable",
+			Expr:   "max by (node, endpoint, network) (" + n.selector("celestia_probe_peer_up") + ") == 0",
+			For:    forDur,
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "No bootstrapper of {{ $labels.network }} is reachable",
+				"description": "The exporter can't connect to any of the bootstrappers and trusted peers probed for {{ $labels.node }}, the node can't find new peers either.",
+			},
+		})
+	}
+
+	if has("celestia_rpc_method_up") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaRPCMethodFailing",
+			Expr:   n.selector("celestia_rpc_method_up") + " == 0",
+			For:    forDur,
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.method }} fails on {{ $labels.node }}",
+				"description": "Calls of {{ $labels.method }} on {{ $labels.node }} fail while the node is up, the API module behind it may be broken.",
+			},
+		})
+	}
+
+	if has("celestia_snapshot_age_seconds") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaSnapshotStale",
+			Expr:   fmt.Sprintf("%s > %s", n.selector("celestia_snapshot_age_seconds"), formatFloat(th.snapshotAge.Seconds())),
+			Labels: map[string]string{"severity": severityWarning},
+			Annotations: map[string]string{
+				"summary":     "The newest snapshot of {{ $labels.node }} is old",
+				"description": "No snapshot of {{ $labels.node }} was taken in the last " + promDuration(th.snapshotAge) + ", the backup job may have stopped.",
+			},
+		})
+	}
+
+	if has("celestia_reorg_detected_total") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaReorgDetected",
+			Expr:   "increase(" + n.selector("celestia_reorg_detected_total") + "[10m]) > 0",
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "Reorg on {{ $labels.node }}",
+				"description": "Heights {{ $labels.node }} already had headers for came back with different hashes in the last 10 minutes, a reorg of depth {{ $labels.depth }}.",
+			},
+		})
+	}
+
+	if has("celestia_reference_hash_match") {
+		rules = append(rules, alertingRule{
+			Alert:  "CelestiaReferenceHashMismatch",
+			Expr:   n.selector("celestia_reference_hash_match") + " == 0",
+			For:    forDur,
+			Labels: map[string]string{"severity": severityCritical},
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.node }} is on a fork",
+				"description": "The block hash of {{ $labels.node }} differs from the one of the reference endpoint at the same height, the node follows a different chain.",
+			},
+		})
+	}
+
+	// The halt detection is part of every exporter, not of a collector.
+	rules = append(rules, alertingRule{
+		Alert:  "CelestiaChainHalted",
+		Expr:   n.selector("celestia_chain_halted") + " == 1",
+		Labels: map[string]string{"severity": severityCritical},
+		Annotations: map[string]string{
+			"summary":     "Celestia network {{ $labels.network }} is halted",
+			"description": "The network head of {{ $labels.network }} stopped advancing on all reachable nodes, the chain rather than a node is stuck.",
+		},
+	})
+
+	return ruleFile{Groups: []ruleGroup{{Name: "celestia-exporter", Rules: rules}}}
+}
+
+// promDuration formats d in the duration syntax of PromQL, which doesn't
+// accept the compound form of time.Duration.String.
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond)
+}
+
+// newRulesCommand returns the rules subcommand, which writes Prometheus
+// alerting rules for the exporter's metrics.
+func newRulesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Write Prometheus alerting rules for the exported metrics",
+		Args:  cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	syncLag := fs.Int("sync.lag-threshold", 5, "number of blocks behind the network head the sync lag alerts fire at")
+	minBalance := fs.Float64("min-balance", 1000000, "balance in utia below which the low balance alert fires")
+	ibcExpiry := fs.Duration("ibc-client-expiry", 48*time.Hour, "time before the expiry of an IBC client the client expiring alert fires at")
+	upgradeBlocks := fs.Int("upgrade-blocks", 1000, "number of blocks before a scheduled upgrade the upgrade alert fires at")
+	snapshotAge := fs.Duration("snapshot-age", 26*time.Hour, "age of the newest snapshot of a node the stale snapshot alert fires at")
+	downFor := fs.Duration("down-for", 5*time.Minute, "time a node has to be unreachable before the node down alert fires")
+	stuckFor := fs.Duration("stuck-for", 15*time.Minute, "time a collector has to go without a successful run before the collector stuck alert fires")
+	forDur := fs.Duration("for", 5*time.Minute, "time the other conditions have to hold before their alerts fire")
+	output := fs.String("output", "-", "file to write the rules to, - for stdout")
+	naming := addNamingFlags(fs)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := naming.validate(); err != nil {
+			return err
+		}
+		metrics, err := describeTargetMetrics()
+		if err != nil {
+			return err
+		}
+		rules := buildRules(metrics, ruleThresholds{syncLag: *syncLag, minBalance: *minBalance, upgradeBlocks: *upgradeBlocks, ibcExpiry: *ibcExpiry, snapshotAge: *snapshotAge, downFor: *downFor, stuckFor: *stuckFor, forDur: *forDur}, *naming)
+		return writeOutput(*output, func(w io.Writer) error {
+			enc := yaml.NewEncoder(w)
+			enc.SetIndent(2)
+			if err := enc.Encode(rules); err != nil {
+				return err
+			}
+			return enc.Close()
+		})
+	}
+	return cmd
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+// maintenance are the nodes under maintenance, set via /api/v1/silence or
+// the silence command. The alert engine doesn't fire alerts of these nodes
+// and celestia_target_maintenance marks them for dashboards and Prometheus
+// rules. Silences don't survive restarts of the exporter.
+var maintenance = &silenceStore{silences: make(map[string]silence)}
+
+var targetMaintenanceDesc = prometheus.NewDesc("celestia_target_maintenance",
+	"1 while the node is under maintenance, its alerts are silenced", []string{"node"}, nil)
+
+func init() {
+	addExporterMetrics(maintenance)
+}
+
+// silence marks a node as under maintenance until EndsAt.
+type silence struct {
+	Node     string    `json:"node"`
+	Comment  string    `json:"comment,omitempty"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type silenceStore struct {
+	mu       sync.Mutex
+	silences map[string]silence
+}
+
+// set silences s.Node, replacing an earlier silence of the node.
+func (s *silenceStore) set(sil silence) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silences[sil.Node] = sil
+}
+
+// remove ends the silence of node and reports whether there was one.
+func (s *silenceStore) remove(node string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sil, ok := s.silences[node]
+	delete(s.silences, node)
+	return ok && now.Before(sil.EndsAt)
+}
+
+// active returns the silences that haven't ended at now, by node, and
+// forgets the ended ones.
+func (s *silenceStore) active(now time.Time) []silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var active []silence
+	for node, sil := range s.silences {
+		if !now.Before(sil.EndsAt) {
+			delete(s.silences, node)
+			continue
+		}
+		active = append(active, sil)
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Node < active[j].Node })
+	return active
+}
+
+// silenced reports whether node is under maintenance at now.
+func (s *silenceStore) silenced(node string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sil, ok := s.silences[node]
+	return ok && now.Before(sil.EndsAt)
+}
+
+func (s *silenceStore) Describe(ch chan<- *prometheus.Desc) {
+	ch <- targetMaintenanceDesc
+}
+
+func (s *silenceStore) Collect(ch chan<- prometheus.Metric) {
+	for _, sil := range s.active(time.Now()) {
+		ch <- prometheus.MustNewConstMetric(targetMaintenanceDesc, prometheus.GaugeValue, 1, sil.Node)
+	}
+}
+
+// silenceRequest is the body of POST /api/v1/silence.
+type silenceRequest struct {
+	Node     string `json:"node"`
+	Duration string `json:"duration"`
+	Comment  string `json:"comment"`
+}
+
+// ServeHTTP serves /api/v1/silence: GET lists the active silences, POST
+// silences a node for a duration and DELETE /api/v1/silence?node=<node>
+// ends its silence.
+func (s *silenceStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	switch r.Method {
+	case http.MethodGet:
+		active := s.active(now)
+		if active == nil {
+			active = []silence{}
+		}
+		writeJSON(w, active)
+	case http.MethodPost:
+		var req silenceRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid duration %q, must be positive, e.g. 2h", req.Duration), http.StatusBadRequest)
+			return
+		}
+		if req.Node == "" {
+			http.Error(w, "node is required", http.StatusBadRequest)
+			return
+		}
+		sil := silence{Node: req.Node, Comment: req.Comment, StartsAt: now, EndsAt: now.Add(d)}
+		s.set(sil)
+		log.Printf("Node %s under maintenance until %s: %s\n", sil.Node, sil.EndsAt.Format(time.RFC3339), sil.Comment)
+		writeJSON(w, sil)
+	case http.MethodDelete:
+		node := r.URL.Query().Get("node")
+		if !s.remove(node, now) {
+			http.Error(w, fmt.Sprintf("node %q is not under maintenance", node), http.StatusNotFound)
+			return
+		}
+		log.Printf("Maintenance of node %s ended\n", node)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func newSilenceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "silence",
+		Short: "Put a node under maintenance, silencing its alerts, or list the silences",
+		Long: "Puts the node of --node under maintenance for --duration via /api/v1/silence of a running exporter: " +
+			"its alerts are silenced and celestia_target_maintenance is set for it. With --expire the maintenance " +
+			"ends early, without --node the active silences are listed.",
+		Args: cobra.NoArgs,
+	}
+	fs := cmd.Flags()
+	exporterURL := fs.String("url", "http://localhost:8380", "URL of the exporter")
+	node := fs.String("node", "", "name of the node to put under maintenance")
+	duration := fs.Duration("duration", time.Hour, "how long the node is under maintenance")
+	comment := fs.String("comment", "", "reason of the maintenance, e.g. the planned upgrade")
+	expire := fs.Bool("expire", false, "end the maintenance of --node")
+	bearerToken := fs.String("bearer-token", "", "bearer token of the exporter, see --web.auth.bearer-token")
+	basicAuth := fs.String("basic-auth", "", "user:password of the exporter, see --web.auth.users-file")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		u := strings.TrimSuffix(*exporterURL, "/") + "/api/v1/silence"
+		var req *http.Request
+		var err error
+		switch {
+		case *node == "" && *expire:
+			return fmt.Errorf("--expire requires --node")
+		case *node == "":
+			req, err = http.NewRequestWithContext(cmd.Context(), http.MethodGet, u, nil)
+		case *expire:
+			req, err = http.NewRequestWithContext(cmd.Context(), http.MethodDelete, u+"?node="+url.QueryEscape(*node), nil)
+		default:
+			if *duration <= 0 {
+				return fmt.Errorf("invalid --duration %s, must be positive", *duration)
+			}
+			body, _ := json.Marshal(silenceRequest{Node: *node, Duration: duration.String(), Comment: *comment})
+			req, err = http.NewRequestWithContext(cmd.Context(), http.MethodPost, u, bytes.NewReader(body))
+			if req != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if *bearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+*bearerToken)
+		} else if user, password, ok := strings.Cut(*basicAuth, ":"); ok {
+			req.SetBasicAuth(user, password)
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+		}
+
+		out := cmd.OutOrStdout()
+		switch req.Method {
+		case http.MethodDelete:
+			fmt.Fprintf(out, "Maintenance of %s ended\n", *node)
+		case http.MethodPost:
+			var sil silence
+			if err := json.Unmarshal(data, &sil); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s is under maintenance until %s\n", sil.Node, sil.EndsAt.Local().Format(time.RFC3339))
+		default:
+			var silences []silence
+			if err := json.Unmarshal(data, &silences); err != nil {
+				return err
+			}
+			if len(silences) == 0 {
+				fmt.Fprintln(out, "No node is under maintenance")
+				return nil
+			}
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "NODE\tUNTIL\tCOMMENT")
+			for _, sil := range silences {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", sil.Node, sil.EndsAt.Local().Format(time.RFC3339), sil.Comment)
+			}
+			tw.Flush()
+		}
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var sinkPushes = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "exporter_sink_pushes_total",
+	Help: "Number of pushes of the metrics to an external system, by sink and result (success or failure)",
+}, []string{"sink", "result"})
+
+func init() {
+	addExporterMetrics(sinkPushes)
+}
+
+// sink pushes gathered metrics to an external system, for setups where
+// the exporter can't be scraped or Prometheus isn't used.
+type sink interface {
+	push(ctx context.Context, families []*dto.MetricFamily) error
+}
+
+// runSink gathers the metrics from g and pushes them to s every interval
+// until ctx is done.
+func runSink(ctx context.Context, wg *sync.WaitGroup, name string, interval time.Duration, g prometheus.Gatherer, s sink) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			families, err := g.Gather()
+			if err != nil {
+				// Gather returns what it could collect along with the error.
+				log.Printf("Error gathering metrics for %s: %v\n", name, err)
+			}
+			pushCtx, cancel := context.WithTimeout(ctx, interval)
+			err = s.push(pushCtx, families)
+			cancel()
+			if err != nil {
+				log.Printf("Error pushing metrics to %s: %v\n", name, err)
+				sinkPushes.WithLabelValues(name, "failure").Inc()
+				continue
+			}
+			sinkPushes.WithLabelValues(name, "success").Inc()
+		}
+	}()
+}
+
+type seriesLabel struct{ name, value string }
+
+// flattenFamilies calls add for every sample of the families, splitting
+// histograms and summaries into their _bucket, _sum and _count series the
+// same way the text format does.
+func flattenFamilies(families []*dto.MetricFamily, add func(name string, labels []seriesLabel, value float64)) {
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.Metric {
+			labels := make([]seriesLabel, 0, len(m.Label)+1)
+			for _, l := range m.Label {
+				labels = append(labels, seriesLabel{l.GetName(), l.GetValue()})
+			}
+			with := func(k, v string) []seriesLabel {
+				return append(append([]seriesLabel{}, labels...), seriesLabel{k, v})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, labels, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, labels, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, labels, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				hasInf := false
+				for _, b := range h.GetBucket() {
+					hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+					add(name+"_bucket", with("le", formatFloat(b.GetUpperBound())), float64(b.GetCumulativeCount()))
+				}
+				if !hasInf {
+					add(name+"_bucket", with("le", "+Inf"), float64(h.GetSampleCount()))
+				}
+				add(name+"_sum", labels, h.GetSampleSum())
+				add(name+"_count", labels, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				sm := m.GetSummary()
+				for _, q := range sm.GetQuantile() {
+					add(name, with("quantile", formatFloat(q.GetQuantile())), q.GetValue())
+				}
+				add(name+"_sum", labels, sm.GetSampleSum())
+				add(name+"_count", labels, float64(sm.GetSampleCount()))
+			}
+		}
+	}
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
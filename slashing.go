@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+// slashReasons are the reasons of the slash events of the slashing module:
+// downtime and double signing.
+var slashReasons = []string{"missing_signature", "double_sign"}
+
+var (
+	validatorSlashes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_validator_slashes_total",
+		Help: "Number of slash events of the validator in the walked blocks, by reason (missing_signature or double_sign)",
+	}, append(append([]string{}, validatorLabels...), "reason"))
+	validatorJailings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_validator_jailings_total",
+		Help: "Number of times the validator was seen becoming jailed",
+	}, validatorLabels)
+	validatorTombstonings = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "celestia_validator_tombstonings_total",
+		Help: "Number of times the validator was seen becoming tombstoned",
+	}, validatorLabels)
+	validatorJailedUntil = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_jailed_until_timestamp_seconds",
+		Help: "Unix time until which the validator is jailed, 0 if it was never jailed",
+	}, validatorLabels)
+)
+
+func init() {
+	addTargetMetrics(validatorSlashes, validatorJailings, validatorTombstonings, validatorJailedUntil)
+	registerCollector("slashing", newSlashingCollector)
+}
+
+// slashingCollector watches the validators of the target for jailing,
+// tombstoning and slashes. It polls their state in the staking and slashing
+// modules and walks the events of the new blocks for slashes, so with a
+// scrape interval below the block time an event is seen within a block or
+// two.
+type slashingCollector struct {
+	client *cometrpc.Client
+	target target
+
+	validators map[string]*slashingState
+	// operators maps the bech32 consensus addresses, which the slash
+	// events carry, to the operator addresses.
+	operators  map[string]string
+	lastHeight int64
+}
+
+// slashingState is the last seen state of a validator; known is false
+// until it was queried once.
+type slashingState struct {
+	consAddr   string
+	known      bool
+	jailed     bool
+	tombstoned bool
+}
+
+func newSlashingCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.ConsensusEndpoint == "" || len(t.stakingValidators()) == 0 {
+		return nil
+	}
+	return &slashingCollector{
+		client:     consensusClient(t),
+		target:     t,
+		validators: make(map[string]*slashingState),
+		operators:  make(map[string]string),
+	}
+}
+
+func (c *slashingCollector) Name() string { return "slashing" }
+
+func (c *slashingCollector) Collect(ctx context.Context) error {
+	var errs []error
+	for _, addr := range c.target.stakingValidators() {
+		if err := c.poll(ctx, addr); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// The slash events can only be matched once a consensus address is
+	// known.
+	if len(c.operators) > 0 {
+		if err := c.walkBlocks(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// poll queries the state of the validator with the operator address addr
+// and counts the transitions into jail and tombstone.
+func (c *slashingCollector) poll(ctx context.Context, addr string) error {
+	t := c.target
+	labels := []string{t.Name, t.Endpoint, t.P2PNetwork, addr}
+
+	v, err := c.client.StakingValidator(ctx, addr)
+	if err != nil {
+		return err
+	}
+	s, ok := c.validators[addr]
+	if !ok {
+		_, consAddr, err := cometrpc.ConsensusAddress(v.ConsensusPubKey, consensusPrefix(addr))
+		if err != nil {
+			return fmt.Errorf("deriving consensus address of %s: %w", addr, err)
+		}
+		s = &slashingState{consAddr: consAddr}
+		c.validators[addr] = s
+		c.operators[consAddr] = addr
+		// Export the counters from the start, so the first event shows
+		// up as an increase.
+		for _, reason := range slashReasons {
+			validatorSlashes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr, reason)
+		}
+		validatorJailings.WithLabelValues(labels...)
+		validatorTombstonings.WithLabelValues(labels...)
+	}
+	si, err := c.client.SigningInfo(ctx, s.consAddr)
+	if err != nil {
+		return err
+	}
+
+	if s.known && v.Jailed && !s.jailed {
+		log.Printf("Validator %s watched by %s was jailed\n", addr, t.Name)
+		validatorJailings.WithLabelValues(labels...).Inc()
+	}
+	if s.known && si.Tombstoned && !s.tombstoned {
+		log.Printf("Validator %s watched by %s was tombstoned\n", addr, t.Name)
+		validatorTombstonings.WithLabelValues(labels...).Inc()
+	}
+	s.known, s.jailed, s.tombstoned = true, v.Jailed, si.Tombstoned
+
+	validatorJailed.WithLabelValues(labels...).Set(boolToFloat(v.Jailed))
+	validatorTombstoned.WithLabelValues(labels...).Set(boolToFloat(si.Tombstoned))
+	jailedUntil := si.JailedUntil
+	if jailedUntil < 0 {
+		jailedUntil = 0
+	}
+	validatorJailedUntil.WithLabelValues(labels...).Set(float64(jailedUntil))
+	return nil
+}
+
+// walkBlocks counts the slash events of the watched validators in the
+// blocks since the last run.
+func (c *slashingCollector) walkBlocks(ctx context.Context) error {
+	t := c.target
+	status, err := c.client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	latest := status.SyncInfo.LatestBlockHeight
+	if c.lastHeight == 0 || latest-c.lastHeight > maxBlocksPerRun {
+		c.lastHeight = latest - 1
+	}
+
+	for h := c.lastHeight + 1; h <= latest; h++ {
+		results, err := c.client.BlockResults(ctx, h)
+		if err != nil {
+			return err
+		}
+		for _, e := range results.Events {
+			if e.Type != "slash" {
+				continue
+			}
+			consAddr, _ := e.Attribute("address")
+			addr, ok := c.operators[consAddr]
+			if !ok {
+				continue
+			}
+			reason, _ := e.Attribute("reason")
+			if reason == "" {
+				reason = "unknown"
+			}
+			log.Printf("Validator %s watched by %s was slashed at height %d for %s\n", addr, t.Name, h, reason)
+			validatorSlashes.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, addr, reason).Inc()
+		}
+		c.lastHeight = h
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/s3"
+)
+
+var (
+	snapshotLastTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_snapshot_last_timestamp_seconds",
+		Help: "Modification time of the newest snapshot of the node",
+	}, targetLabels)
+	snapshotAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_snapshot_age_seconds",
+		Help: "Age of the newest snapshot of the node",
+	}, targetLabels)
+	snapshotSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_snapshot_size_bytes",
+		Help: "Size of the newest snapshot of the node",
+	}, targetLabels)
+)
+
+func init() {
+	addTargetMetrics(snapshotLastTimestamp, snapshotAge, snapshotSize)
+	registerCollector("snapshot", newSnapshotCollector)
+}
+
+// snapshotS3 lists the snapshots of s3:// snapshot locations, configured by
+// the --snapshot.s3.* flags.
+var snapshotS3 *s3.Client
+
+// validateSnapshotLocation checks a snapshot location, a directory or an
+// s3://bucket/prefix URL.
+func validateSnapshotLocation(location string) error {
+	if strings.HasPrefix(location, "s3://") {
+		_, _, err := s3.ParseURL(location)
+		return err
+	}
+	if !strings.HasPrefix(location, "/") {
+		return fmt.Errorf("invalid snapshot location %q, must be an absolute directory or an s3://bucket/prefix URL", location)
+	}
+	return nil
+}
+
+// snapshot is a snapshot file or object.
+type snapshot struct {
+	name     string
+	modified time.Time
+	size     int64
+}
+
+// snapshotCollector exports the age and size of the newest snapshot of the
+// node, the newest file in a directory or object under an S3 prefix, so a
+// backup job that silently stopped shows up before the snapshot is needed.
+type snapshotCollector struct {
+	target target
+}
+
+func newSnapshotCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.SnapshotLocation == "" {
+		return nil
+	}
+	return &snapshotCollector{target: t}
+}
+
+func (c *snapshotCollector) Name() string { return "snapshot" }
+
+func (c *snapshotCollector) Collect(ctx context.Context) error {
+	t := c.target
+	var snapshots []snapshot
+	var err error
+	if strings.HasPrefix(t.SnapshotLocation, "s3://") {
+		snapshots, err = listS3Snapshots(ctx, t.SnapshotLocation)
+	} else {
+		snapshots, err = listDirSnapshots(t.SnapshotLocation)
+	}
+	if err != nil {
+		return err
+	}
+
+	var newest *snapshot
+	for i := range snapshots {
+		if newest == nil || snapshots[i].modified.After(newest.modified) {
+			newest = &snapshots[i]
+		}
+	}
+	if newest == nil {
+		return fmt.Errorf("no snapshots in %s", t.SnapshotLocation)
+	}
+	snapshotLastTimestamp.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(newest.modified.Unix()))
+	snapshotAge.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(time.Since(newest.modified).Seconds())
+	snapshotSize.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(newest.size))
+	return nil
+}
+
+// listDirSnapshots returns the regular files in dir, skipping hidden ones
+// like the temporary files of uploads in progress.
+func listDirSnapshots(dir string) ([]snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []snapshot
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{name: e.Name(), modified: info.ModTime(), size: info.Size()})
+	}
+	return snapshots, nil
+}
+
+func listS3Snapshots(ctx context.Context, location string) ([]snapshot, error) {
+	// The location was checked when the target was configured.
+	bucket, prefix, _ := s3.ParseURL(location)
+	objects, err := snapshotS3.List(ctx, bucket, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", location, err)
+	}
+	var snapshots []snapshot
+	for _, o := range objects {
+		// Keys ending in a slash are folder placeholders.
+		if strings.HasSuffix(o.Key, "/") {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{name: o.Key, modified: o.LastModified, size: o.Size})
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// exporterState keeps what has to survive exporter restarts: the last
+// observed heights, to tell blocks synced while the exporter was down from
+// a node rollback, and the counters that would otherwise reset. It is only
+// written to disk with --state.file.
+var exporterState = &stateStore{targets: make(map[string]*targetState)}
+
+type stateStore struct {
+	mu      sync.Mutex
+	path    string
+	targets map[string]*targetState
+}
+
+type targetState struct {
+	LocalHeight       uint64  `json:"local_height"`
+	BlocksSynced      float64 `json:"blocks_synced"`
+	Rollbacks         float64 `json:"rollbacks"`
+	CanarySuccesses   float64 `json:"canary_successes"`
+	CanaryFailures    float64 `json:"canary_failures"`
+	CanaryLastSuccess int64   `json:"canary_last_success,omitempty"`
+}
+
+// stateKey identifies a target in the state file. The endpoint is part of
+// it, so pointing a name at another node doesn't look like a rollback.
+func stateKey(t target) string {
+	return t.Name + " " + t.Endpoint
+}
+
+// load reads the state file at path, if it exists, and saves to it from
+// then on.
+func (s *stateStore) load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.targets)
+}
+
+// save writes the state file, replacing it atomically.
+func (s *stateStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		return nil
+	}
+	data, err := s.encode()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it to path, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// encode returns the state as written to the file. s.mu must be held.
+func (s *stateStore) encode() ([]byte, error) {
+	return json.MarshalIndent(s.targets, "", "  ")
+}
+
+// contents returns the state as written to the file.
+func (s *stateStore) contents() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encode()
+}
+
+// run saves the state every interval until ctx is done. The final save on
+// shutdown happens once the collections have stopped.
+func (s *stateStore) run(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.save(); err != nil {
+				log.Printf("Error saving state file: %v\n", err)
+			}
+		}
+	}()
+}
+
+func (s *stateStore) target(t target) *targetState {
+	key := stateKey(t)
+	ts, ok := s.targets[key]
+	if !ok {
+		ts = &targetState{}
+		s.targets[key] = ts
+	}
+	return ts
+}
+
+// restore sets the counters of t to their persisted values. It is called
+// whenever the series of t are created, so the counters continue where
+// they left off instead of starting at 0.
+func (s *stateStore) restore(t target) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.target(t)
+	if m, ok := headerMetricsByType[t.NodeType]; ok {
+		m.blocksSynced.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Add(ts.BlocksSynced)
+		m.rollbacks.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Add(ts.Rollbacks)
+	}
+	if t.CanaryNamespace != "" {
+		canaryRuns.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "success").Add(ts.CanarySuccesses)
+		canaryRuns.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, "failure").Add(ts.CanaryFailures)
+		if ts.CanaryLastSuccess > 0 {
+			canaryLastSuccess.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(float64(ts.CanaryLastSuccess))
+		}
+	}
+}
+
+// observeHeight counts the blocks the node synced since the last observed
+// height, which may be from before an exporter restart, and detects the
+// local height going backwards.
+func (s *stateStore) observeHeight(t target, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.target(t)
+	m := headerMetricsByType[t.NodeType]
+	switch {
+	case ts.LocalHeight == 0:
+	case height < ts.LocalHeight:
+		log.Printf("WARNING: local height of %s went back from %d to %d, the node was rolled back or its store was reset\n", t.Name, ts.LocalHeight, height)
+		ts.Rollbacks++
+		m.rollbacks.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Inc()
+	default:
+		n := float64(height - ts.LocalHeight)
+		ts.BlocksSynced += n
+		m.blocksSynced.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Add(n)
+	}
+	ts.LocalHeight = height
+}
+
+func (s *stateStore) canaryRun(t target, success bool, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.target(t)
+	if success {
+		ts.CanarySuccesses++
+		ts.CanaryLastSuccess = at.Unix()
+	} else {
+		ts.CanaryFailures++
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statusAPI serves /api/v1/status, a JSON snapshot of the monitored nodes
+// for dashboards and bots that don't speak PromQL. Like the alert engine it
+// reads the collected metrics from the registry, by their registered names,
+// and the collector outcomes from the health tracker.
+type statusAPI struct {
+	gatherer prometheus.Gatherer
+	health   *healthTracker
+	started  time.Time
+}
+
+type apiStatus struct {
+	Status        string                 `json:"status"`
+	Version       string                 `json:"version"`
+	StartedAt     time.Time              `json:"started_at"`
+	UptimeSeconds float64                `json:"uptime_seconds"`
+	Nodes         map[string]*nodeStatus `json:"nodes"`
+}
+
+type nodeStatus struct {
+	Endpoint      string             `json:"endpoint"`
+	Network       string             `json:"network"`
+	NodeType      string             `json:"node_type,omitempty"`
+	Version       string             `json:"version,omitempty"`
+	LocalHeight   *float64           `json:"local_height,omitempty"`
+	NetworkHeight *float64           `json:"network_height,omitempty"`
+	SyncLagBlocks *float64           `json:"sync_lag_blocks,omitempty"`
+	Synced        *bool              `json:"synced,omitempty"`
+	SampledHeight *float64           `json:"das_sampled_height,omitempty"`
+	Peers         *float64           `json:"peers,omitempty"`
+	BalancesUtia  map[string]float64 `json:"balances_utia,omitempty"`
+	Health        *targetStatus      `json:"health,omitempty"`
+}
+
+func (a *statusAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s, err := a.report()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s)
+}
+
+// report returns the current status of the exporter and its nodes.
+func (a *statusAPI) report() (*apiStatus, error) {
+	families, err := a.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return nil, err
+	}
+	_, _, health := a.health.status()
+
+	s := &apiStatus{
+		Status:        health.Status,
+		Version:       version,
+		StartedAt:     a.started,
+		UptimeSeconds: time.Since(a.started).Seconds(),
+		Nodes:         nodeStatuses(families),
+	}
+	for name, ts := range health.Targets {
+		ts := ts
+		ns, ok := s.Nodes[name]
+		if !ok {
+			// The node didn't answer yet, so it has no metrics.
+			ns = &nodeStatus{}
+			s.Nodes[name] = ns
+		}
+		ns.Health = &ts
+	}
+	return s, nil
+}
+
+// nodeStatuses extracts the status of every node from the metric families,
+// by node name. The sync lag of light nodes is the distance of the sampled
+// chain head to the network head, as for the check command.
+func nodeStatuses(families []*dto.MetricFamily) map[string]*nodeStatus {
+	nodes := make(map[string]*nodeStatus)
+	dasHeads := make(map[string]float64)
+	node := func(m *dto.Metric) *nodeStatus {
+		labels := metricLabels(m)
+		ns, ok := nodes[labels["node"]]
+		if !ok {
+			ns = &nodeStatus{Endpoint: labels["endpoint"], Network: labels["network"]}
+			nodes[labels["node"]] = ns
+		}
+		return ns
+	}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		if name == "celestia_wallet_balance_utia" {
+			for _, m := range mf.Metric {
+				ns := node(m)
+				if ns.BalancesUtia == nil {
+					ns.BalancesUtia = make(map[string]float64)
+				}
+				ns.BalancesUtia[metricLabels(m)["address"]] = m.GetGauge().GetValue()
+			}
+			continue
+		}
+		if name == "celestia_node_info" {
+			for _, m := range mf.Metric {
+				node(m).Version = metricLabels(m)["version"]
+			}
+			continue
+		}
+
+		nodeType, rest, ok := strings.Cut(name, "_")
+		if !ok || (nodeType != nodeTypeBridge && nodeType != nodeTypeFull && nodeType != nodeTypeLight) {
+			continue
+		}
+		for _, m := range mf.Metric {
+			value := m.GetGauge().GetValue()
+			ns := node(m)
+			ns.NodeType = nodeType
+			switch rest {
+			case "local_height":
+				ns.LocalHeight = &value
+			case "network_height":
+				ns.NetworkHeight = &value
+			case "sync_lag_blocks":
+				ns.SyncLagBlocks = &value
+			case "is_synced":
+				synced := value == 1
+				ns.Synced = &synced
+			case "das_sampled_chain_head":
+				ns.SampledHeight = &value
+			case "das_network_head_height":
+				dasHeads[metricLabels(m)["node"]] = value
+			case "p2p_peers":
+				ns.Peers = &value
+			}
+		}
+	}
+
+	for name, head := range dasHeads {
+		ns := nodes[name]
+		if ns.SyncLagBlocks == nil && ns.SampledHeight != nil {
+			lag := head - *ns.SampledHeight
+			ns.SyncLagBlocks = &lag
+		}
+	}
+	return nodes
+}
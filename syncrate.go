@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// syncRateWindow is the time span the sync rate is averaged over, long
+// enough for the few blocks a node syncs per scrape when it is at the head.
+const syncRateWindow = time.Minute
+
+// heightSample is a local and network height observed at a time.
+type heightSample struct {
+	at      time.Time
+	local   uint64
+	network uint64
+}
+
+// syncRates keeps the height samples of the last syncRateWindow per target
+// to derive the sync rate and the time until a catching up node reaches the
+// network head.
+type syncRates struct {
+	mu      sync.Mutex
+	samples map[string][]heightSample
+}
+
+var headerSyncRates = &syncRates{samples: make(map[string][]heightSample)}
+
+// observe records a sample of t and returns the rate the local head
+// advanced at and the rate the lag to the network head shrank at over the
+// window, both in blocks per second. ok is false until there are two
+// samples.
+func (r *syncRates) observe(t target, s heightSample) (rate, closing float64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := stateKey(t)
+	samples := r.samples[key]
+	// A rolled back node starts over.
+	if n := len(samples); n > 0 && s.local < samples[n-1].local {
+		samples = nil
+	}
+	samples = append(samples, s)
+	for len(samples) > 2 && s.at.Sub(samples[1].at) >= syncRateWindow {
+		samples = samples[1:]
+	}
+	r.samples[key] = samples
+
+	first := samples[0]
+	elapsed := s.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0, 0, false
+	}
+	rate = float64(s.local-first.local) / elapsed
+	closing = (lagOf(first) - lagOf(s)) / elapsed
+	return rate, closing, true
+}
+
+func lagOf(s heightSample) float64 {
+	if s.network < s.local {
+		return 0
+	}
+	return float64(s.network - s.local)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sdNotify sends state, e.g. READY=1, to the service manager through the
+// socket in $NOTIFY_SOCKET, like sd_notify(3). It does nothing unless the
+// exporter was started by systemd as a Type=notify service.
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	// A leading @ stands for the abstract socket namespace.
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval returns the watchdog timeout systemd set for the
+// exporter with WatchdogSec=, or 0 if there is none.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// runWatchdog pings the systemd watchdog at half its timeout for as long as
+// the polling of every node is live, so systemd restarts the exporter when
+// a polling loop got stuck, e.g. in a deadlock.
+func runWatchdog(ctx context.Context, wg *sync.WaitGroup, health *healthTracker) {
+	timeout := watchdogInterval()
+	if timeout == 0 {
+		return
+	}
+	log.Printf("Pinging the systemd watchdog every %s\n", timeout/2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		stuck := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if live, _, _ := health.status(); !live {
+				if !stuck {
+					log.Printf("WARNING: the polling of a node is stuck, no longer pinging the systemd watchdog\n")
+				}
+				stuck = true
+				continue
+			}
+			stuck = false
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				log.Printf("Error pinging the systemd watchdog: %v\n", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// target is a single Celestia node the exporter scrapes.
+type target struct {
+	Name          string
+	Endpoint      string
+	AuthToken     string
+	AuthTokenFile string
+	// AuthTokenEnv is the environment variable holding the token,
+	// AuthTokenCommand the template of a command printing it, and
+	// AuthTokenTTL how long a token printed by a command is kept.
+	AuthTokenEnv     string
+	AuthTokenCommand string
+	AuthTokenTTL     time.Duration
+	// AuthScope is admin, or read if the token only has read permission.
+	AuthScope  string
+	P2PNetwork string
+	NodeStore  string
+	// DataDir is the node data directory the disk collector measures, if
+	// the exporter runs on the node's host.
+	DataDir string
+	// PIDFile and ProcessName locate the node process for the process
+	// collector, PIDFile taking precedence.
+	PIDFile        string
+	ProcessName    string
+	ScrapeInterval time.Duration
+	ScrapeTimeout  time.Duration
+	// CollectorIntervals and CollectorTimeouts override ScrapeInterval and
+	// ScrapeTimeout for single collectors.
+	CollectorIntervals map[string]time.Duration
+	CollectorTimeouts  map[string]time.Duration
+	// AdaptiveInterval makes the header and das collectors run every half
+	// block time, bounded by AdaptiveMin and AdaptiveMax, instead of at
+	// their interval.
+	AdaptiveInterval bool
+	AdaptiveMin      time.Duration
+	AdaptiveMax      time.Duration
+	SyncLagThreshold int
+	// HeaderVerifyDepth is the number of most recent headers checked by the
+	// headerchain collector.
+	HeaderVerifyDepth int
+	// MissedBlocksWindow is the number of most recent blocks the blocks
+	// collector reports the missed blocks of the validator for.
+	MissedBlocksWindow int
+	Subscribe          bool
+	NodeType           string
+	P2PProtocols       []string
+	BalanceAddresses   []string
+	// CanaryNamespace enables the blob canary under this namespace ID.
+	CanaryNamespace string
+	// AuditSamples is the number of random heights within the AuditWindow
+	// most recent blocks, all if 0, the audit collector checks per run, and
+	// AuditNamespaces the namespaces whose blobs it fetches.
+	AuditSamples    int
+	AuditWindow     int
+	AuditNamespaces []string
+	Collectors      []string
+	TLS             targetTLS
+	// ProxyURL is the proxy requests to the endpoint go through instead of
+	// the one from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+	// variables.
+	ProxyURL string
+	// Fallbacks are further endpoints of the same node, queried in order
+	// while Endpoint is unreachable.
+	Fallbacks []string
+	// ConsensusEndpoint is the RPC of the consensus node paired with the
+	// node, ValidatorAddress the operator address of its validator.
+	ConsensusEndpoint string
+	ValidatorAddress  string
+	// AppGRPC is the gRPC server of celestia-app the Cosmos SDK queries
+	// are sent to instead of the consensus endpoint.
+	AppGRPC string
+	// StakingValidators are the operator addresses of further validators
+	// the app collector exports the stake and rewards of.
+	StakingValidators []string
+	// IBCChannels are the IBC channels to watch, as port/channel or, on
+	// the transfer port, channel.
+	IBCChannels []string
+	// ProbePeers are the bootstrappers and trusted peers the probe
+	// collector dials, as TCP multiaddrs or host:port.
+	ProbePeers []string
+	// ProbeMethods are the JSON-RPC methods the methods collector calls
+	// without parameters.
+	ProbeMethods []string
+	// SnapshotLocation is the directory or s3://bucket/prefix URL the
+	// snapshots of the node are stored in.
+	SnapshotLocation string
+	// ReferenceEndpoint is a trusted CometBFT RPC the reference collector
+	// compares the heights and block hashes of the node with.
+	ReferenceEndpoint string
+	// SlackWebhookURL is the Slack incoming webhook the daily digest of the
+	// node is posted to.
+	SlackWebhookURL string
+}
+
+// targetTLS configures TLS for https:// endpoints.
+type targetTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// clientConfig returns the tls.Config for connections to the target, or nil
+// if the defaults are used.
+func (c targetTLS) clientConfig() (*tls.Config, error) {
+	if c == (targetTLS{}) {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+const (
+	nodeTypeAuto   = "auto"
+	nodeTypeBridge = "bridge"
+	nodeTypeFull   = "full"
+	nodeTypeLight  = "light"
+)
+
+func validNodeType(nodeType string) bool {
+	switch nodeType {
+	case nodeTypeAuto, nodeTypeBridge, nodeTypeFull, nodeTypeLight:
+		return true
+	}
+	return false
+}
+
+// collectsHeaders reports whether header metrics are collected for the
+// target's node type. Bridge and full nodes sync headers.
+func (t target) collectsHeaders() bool {
+	return t.NodeType == nodeTypeBridge || t.NodeType == nodeTypeFull
+}
+
+// collectsDAS reports whether DAS metrics are collected for the target's node
+// type. Light and full nodes run data availability sampling.
+func (t target) collectsDAS() bool {
+	return t.NodeType == nodeTypeLight || t.NodeType == nodeTypeFull
+}
+
+func (t target) collectorEnabled(name string) bool {
+	for _, c := range t.Collectors {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t target) collectorInterval(name string) time.Duration {
+	if d, ok := t.CollectorIntervals[name]; ok {
+		return d
+	}
+	return t.ScrapeInterval
+}
+
+func (t target) collectorTimeout(name string) time.Duration {
+	if d, ok := t.CollectorTimeouts[name]; ok {
+		return d
+	}
+	return t.ScrapeTimeout
+}
+
+// parseTargetList parses a list of endpoints. Each entry is either a bare
+// URL or name=URL; bare URLs are named after their host:port.
+func parseTargetList(entries []string) ([]target, error) {
+	var targets []target
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, endpoint := "", entry
+		if i := strings.Index(entry, "="); i > 0 && !strings.Contains(entry[:i], "://") {
+			name, endpoint = entry[:i], entry[i+1:]
+		}
+
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+		}
+		if name == "" {
+			name = u.Host
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate node name %q", name)
+		}
+		seen[name] = true
+
+		targets = append(targets, target{Name: name, Endpoint: endpoint})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no endpoints configured")
+	}
+	return targets, nil
+}
+
+// validateProxyURL checks a proxy URL, which may be empty to use the proxy
+// from the environment.
+func validateProxyURL(proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q", proxyURL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	}
+	return fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", proxyURL)
+}
+
+// validateFallback checks a fallback endpoint, an http(s) URL like the
+// endpoints.
+func validateFallback(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid fallback endpoint %q, must be an http:// or https:// URL", endpoint)
+	}
+	return nil
+}
+
+// stakingValidators returns the operator addresses the app collector
+// exports the stake of and the slashing and gov collectors watch: the paired
+// validator and the further ones.
+func (t target) stakingValidators() []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, addr := range append([]string{t.ValidatorAddress}, t.StakingValidators...) {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+)
+
+const (
+	// tracingFlushInterval is how often buffered spans are sent,
+	// tracingMaxSpans how many are buffered at most; spans recorded while
+	// the buffer is full are dropped.
+	tracingFlushInterval = 5 * time.Second
+	tracingMaxSpans      = 2048
+
+	// otlpSpanKindClient is SPAN_KIND_CLIENT, the status codes are
+	// STATUS_CODE_OK and STATUS_CODE_ERROR.
+	otlpSpanKindClient = 3
+	otlpStatusOK       = 1
+	otlpStatusError    = 2
+)
+
+var tracingDroppedSpans = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "exporter_tracing_dropped_spans_total",
+	Help: "Number of RPC spans dropped because the buffer was full or sending them failed",
+})
+
+func init() {
+	addExporterMetrics(tracingDroppedSpans)
+}
+
+// rpcTracer records a span for every RPC request if tracing is enabled.
+var rpcTracer *tracer
+
+// tracer sends the spans of RPC requests to an OpenTelemetry collector
+// using OTLP over HTTP with the JSON encoding, like otlpSink does for the
+// metrics. Every request is a trace of its own; its trace ID is attached
+// to exporter_rpc_duration_seconds as exemplar.
+type tracer struct {
+	url         string
+	headers     map[string]string
+	resource    []otlpKeyValue
+	sampleRatio float64
+	httpClient  *http.Client
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+// newTracer returns a tracer for endpoint, e.g. http://collector:4318. The
+// traces path /v1/traces is appended unless endpoint has a path.
+func newTracer(endpoint string, headers, attributes map[string]string, sampleRatio float64) (*tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	if sampleRatio < 0 || sampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio must be between 0 and 1, got %g", sampleRatio)
+	}
+
+	var resource []otlpKeyValue
+	if _, ok := attributes["service.name"]; !ok {
+		resource = append(resource, otlpAttribute("service.name", otlpServiceName))
+	}
+	for _, k := range sortedKeys(attributes) {
+		resource = append(resource, otlpAttribute(k, attributes[k]))
+	}
+	return &tracer{
+		url:         u.String(),
+		headers:     headers,
+		resource:    resource,
+		sampleRatio: sampleRatio,
+		httpClient:  &http.Client{},
+	}, nil
+}
+
+// The types below mirror the JSON mapping of the OTLP trace protobuf
+// messages. Trace and span IDs are hex encoded.
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            otlpSpanStatus `json:"status"`
+}
+
+type otlpSpanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// record buffers the span of a finished request to t and returns its trace
+// ID, or "" if the request wasn't sampled.
+func (tr *tracer) record(t target, info celestiarpc.RequestInfo) string {
+	if tr.sampleRatio < 1 && mathrand.Float64() >= tr.sampleRatio {
+		return ""
+	}
+	traceID, spanID := randomHex(16), randomHex(8)
+	end := time.Now()
+	span := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		Name:              info.Method,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(end.Add(-info.Duration).UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes: []otlpKeyValue{
+			otlpAttribute("rpc.system", "jsonrpc"),
+			otlpAttribute("rpc.method", info.Method),
+			otlpAttribute("celestia.node", t.Name),
+			otlpAttribute("celestia.endpoint", t.Endpoint),
+			otlpAttribute("http.status_code", strconv.Itoa(info.StatusCode)),
+			otlpAttribute("rpc.attempts", strconv.Itoa(info.Attempts)),
+		},
+		Status: otlpSpanStatus{Code: otlpStatusOK},
+	}
+	if info.Err != nil {
+		span.Status = otlpSpanStatus{Code: otlpStatusError, Message: info.Err.Error()}
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.spans) >= tracingMaxSpans {
+		tracingDroppedSpans.Inc()
+		return ""
+	}
+	tr.spans = append(tr.spans, span)
+	return traceID
+}
+
+// run sends the buffered spans every tracingFlushInterval until ctx is
+// done, then sends the remaining ones.
+func (tr *tracer) run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(tracingFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), tracingFlushInterval)
+				tr.flush(flushCtx)
+				cancel()
+				return
+			case <-ticker.C:
+				flushCtx, cancel := context.WithTimeout(ctx, tracingFlushInterval)
+				tr.flush(flushCtx)
+				cancel()
+			}
+		}
+	}()
+}
+
+func (tr *tracer) flush(ctx context.Context) {
+	tr.mu.Lock()
+	spans := tr.spans
+	tr.spans = nil
+	tr.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+
+	if err := tr.send(ctx, spans); err != nil {
+		log.Printf("Error sending traces: %v\n", err)
+		sinkPushes.WithLabelValues("tracing", "failure").Inc()
+		tracingDroppedSpans.Add(float64(len(spans)))
+		return
+	}
+	sinkPushes.WithLabelValues("tracing", "success").Inc()
+}
+
+func (tr *tracer) send(ctx context.Context, spans []otlpSpan) error {
+	rs := otlpResourceSpans{}
+	rs.Resource.Attributes = tr.resource
+	scope := otlpScopeSpans{Spans: spans}
+	scope.Scope.Name = otlpScopeName
+	rs.ScopeSpans = []otlpScopeSpans{scope}
+	body, err := json.Marshal(otlpTraceRequest{ResourceSpans: []otlpResourceSpans{rs}})
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tr.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range tr.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := tr.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand doesn't fail on supported platforms.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
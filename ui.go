@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"html"
+	"net/http"
+	"strings"
+)
+
+//go:embed ui/index.html
+var uiPage []byte
+
+// uiHandler serves the status page, which renders /api/v1/status and
+// /api/v1/history in the browser and links the metrics under
+// telemetryPath.
+func uiHandler(telemetryPath string) http.Handler {
+	page := bytes.Replace(uiPage, []byte(`<a href="metrics">metrics</a>`),
+		[]byte(`<a href="`+html.EscapeString(strings.TrimPrefix(telemetryPath, "/"))+`">metrics</a>`), 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
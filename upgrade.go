@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var (
+	upgradePending = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_upgrade_pending",
+		Help: "1 if an upgrade of the network is scheduled, 0 otherwise",
+	}, targetLabels)
+	upgradeHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_upgrade_height",
+		Help: "Height the scheduled upgrade takes effect at, by upgrade name",
+	}, append(targetLabels, "upgrade"))
+	upgradeBlocksRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_upgrade_blocks_remaining",
+		Help: "Number of blocks until the scheduled upgrade takes effect, by upgrade name",
+	}, append(targetLabels, "upgrade"))
+)
+
+func init() {
+	addTargetMetrics(upgradePending, upgradeHeight, upgradeBlocksRemaining)
+	registerCollector("upgrade", newUpgradeCollector)
+}
+
+// upgradeCollector exports the upgrades scheduled on the network: software
+// upgrade plans of the upgrade module and, on celestia-app v2 and later,
+// the app version upgrades of the signal module.
+type upgradeCollector struct {
+	client *cometrpc.Client
+	target target
+
+	// noSignal is set once the app turned out to have no signal module.
+	noSignal bool
+}
+
+func newUpgradeCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.ConsensusEndpoint == "" {
+		return nil
+	}
+	return &upgradeCollector{client: consensusClient(t), target: t}
+}
+
+func (c *upgradeCollector) Name() string { return "upgrade" }
+
+func (c *upgradeCollector) Collect(ctx context.Context) error {
+	t := c.target
+	status, err := c.client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	latest := status.SyncInfo.LatestBlockHeight
+
+	var upgrades []*cometrpc.Upgrade
+	plan, err := c.client.UpgradePlan(ctx)
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		upgrades = append(upgrades, plan)
+	}
+	if !c.noSignal {
+		signalled, err := c.client.SignalledUpgrade(ctx)
+		switch {
+		case err != nil && isUnknownQuery(err):
+			log.Printf("%s has no signal module, only watching upgrade plans\n", t.Name)
+			c.noSignal = true
+		case err != nil:
+			return err
+		case signalled != nil:
+			upgrades = append(upgrades, signalled)
+		}
+	}
+
+	// Drop the series of upgrades that took effect or were cancelled.
+	upgradeHeight.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	upgradeBlocksRemaining.DeletePartialMatch(prometheus.Labels{"node": t.Name, "endpoint": t.Endpoint})
+	upgradePending.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork).Set(boolToFloat(len(upgrades) > 0))
+	for _, u := range upgrades {
+		upgradeHeight.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, u.Name).Set(float64(u.Height))
+		remaining := u.Height - latest
+		if remaining < 0 {
+			remaining = 0
+		}
+		upgradeBlocksRemaining.WithLabelValues(t.Name, t.Endpoint, t.P2PNetwork, u.Name).Set(float64(remaining))
+	}
+	return nil
+}
+
+// isUnknownQuery reports whether err means that the app doesn't serve the
+// query, as ABCI query or via gRPC.
+func isUnknownQuery(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unknown query path") || strings.Contains(msg, "Unimplemented") || strings.Contains(msg, "unknown service")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"my-celestia-exporter/pkg/celestiarpc"
+	"my-celestia-exporter/pkg/cometrpc"
+)
+
+var validatorLabels = append(targetLabels, "validator")
+
+var (
+	validatorVotingPower = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_voting_power",
+		Help: "Voting power of the validator in the active set, 0 if it is not in the active set",
+	}, validatorLabels)
+	validatorMissedBlocks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_missed_blocks",
+		Help: "Number of blocks the validator missed in the current signing window",
+	}, validatorLabels)
+	validatorSignedBlocksWindow = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_signed_blocks_window",
+		Help: "Length of the slashing signing window in blocks",
+	}, validatorLabels)
+	validatorJailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_jailed",
+		Help: "1 if the validator is jailed, 0 otherwise",
+	}, validatorLabels)
+	validatorTombstoned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_tombstoned",
+		Help: "1 if the validator is tombstoned, 0 otherwise",
+	}, validatorLabels)
+	validatorCommissionRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "celestia_validator_commission_rate",
+		Help: "Current commission rate of the validator as a fraction",
+	}, validatorLabels)
+)
+
+func init() {
+	addTargetMetrics(validatorVotingPower, validatorMissedBlocks, validatorSignedBlocksWindow,
+		validatorJailed, validatorTombstoned, validatorCommissionRate)
+	registerCollector("validator", newValidatorCollector)
+}
+
+// validatorCollector exports the state of the validator paired with the
+// target from the consensus node at the target's consensus endpoint.
+type validatorCollector struct {
+	client *cometrpc.Client
+	target target
+}
+
+func newValidatorCollector(_ *celestiarpc.Client, t target) Collector {
+	if t.ConsensusEndpoint == "" || t.ValidatorAddress == "" {
+		return nil
+	}
+	return &validatorCollector{client: consensusClient(t), target: t}
+}
+
+func (c *validatorCollector) Name() string { return "validator" }
+
+func (c *validatorCollector) Collect(ctx context.Context) error {
+	t, client := c.target, c.client
+	labels := []string{t.Name, t.Endpoint, t.P2PNetwork, t.ValidatorAddress}
+
+	v, err := client.StakingValidator(ctx, t.ValidatorAddress)
+	if err != nil {
+		return err
+	}
+	validatorJailed.WithLabelValues(labels...).Set(boolToFloat(v.Jailed))
+	validatorCommissionRate.WithLabelValues(labels...).Set(v.CommissionRate)
+
+	hexAddr, consAddr, err := cometrpc.ConsensusAddress(v.ConsensusPubKey, consensusPrefix(t.ValidatorAddress))
+	if err != nil {
+		return fmt.Errorf("deriving consensus address of %s: %w", t.ValidatorAddress, err)
+	}
+
+	var errs []error
+	if active, err := client.ValidatorByAddress(ctx, hexAddr); err != nil {
+		errs = append(errs, err)
+	} else if active == nil {
+		validatorVotingPower.WithLabelValues(labels...).Set(0)
+	} else {
+		validatorVotingPower.WithLabelValues(labels...).Set(float64(active.VotingPower))
+	}
+
+	if si, err := client.SigningInfo(ctx, consAddr); err != nil {
+		errs = append(errs, err)
+	} else {
+		validatorMissedBlocks.WithLabelValues(labels...).Set(float64(si.MissedBlocksCounter))
+		validatorTombstoned.WithLabelValues(labels...).Set(boolToFloat(si.Tombstoned))
+	}
+
+	if params, err := client.SlashingParams(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		validatorSignedBlocksWindow.WithLabelValues(labels...).Set(float64(params.SignedBlocksWindow))
+	}
+	return joinErrors(errs)
+}
+
+// consensusPrefix derives the bech32 prefix of consensus addresses from an
+// operator address, e.g. celestiavaloper1... gives celestiavalcons.
+func consensusPrefix(operatorAddress string) string {
+	hrp := operatorAddress
+	if i := strings.LastIndex(hrp, "1"); i >= 0 {
+		hrp = hrp[:i]
+	}
+	return strings.TrimSuffix(hrp, "valoper") + "valcons"
+}
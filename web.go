@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// webTLSConfig is the TLS setup of the metrics listener, named after the
+// tls_server_config of the Prometheus exporter-toolkit web config.
+type webTLSConfig struct {
+	CertFile       string
+	KeyFile        string
+	ClientCAFile   string
+	ClientAuthType string
+	// The settings below can only be set via --web.config.file, 0 or nil
+	// for the defaults.
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// enabled reports whether the listener should serve HTTPS.
+func (c webTLSConfig) enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// build returns the tls.Config for the listener. The key pair is re-read on
+// every handshake so renewed certificates are picked up without a restart.
+func (c webTLSConfig) build() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("both a certificate and a key file are required")
+	}
+	if _, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile); err != nil {
+		return nil, fmt.Errorf("loading key pair: %w", err)
+	}
+
+	cfg := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		MaxVersion:       c.MaxVersion,
+		CipherSuites:     c.CipherSuites,
+		CurvePreferences: c.CurvePreferences,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading key pair: %w", err)
+			}
+			return &cert, nil
+		},
+	}
+	if c.MinVersion != 0 {
+		cfg.MinVersion = c.MinVersion
+	}
+
+	authType := c.ClientAuthType
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		if authType == "" {
+			authType = "RequireAndVerifyClientCert"
+		}
+	}
+	if authType != "" {
+		t, ok := clientAuthTypes[authType]
+		if !ok {
+			return nil, fmt.Errorf("invalid client auth type %q", authType)
+		}
+		if t >= tls.VerifyClientCertIfGiven && cfg.ClientCAs == nil {
+			return nil, fmt.Errorf("client auth type %s requires a client CA", authType)
+		}
+		cfg.ClientAuth = t
+	}
+	return cfg, nil
+}
+
+// webConfigFile is the format of --web.config.file, the web configuration
+// file of the Prometheus exporter-toolkit, so the files templated for other
+// exporters work unchanged.
+type webConfigFile struct {
+	TLSServerConfig  *webTLSServerConfig `yaml:"tls_server_config"`
+	HTTPServerConfig webHTTPServerConfig `yaml:"http_server_config"`
+	BasicAuthUsers   map[string]string   `yaml:"basic_auth_users"`
+}
+
+type webTLSServerConfig struct {
+	CertFile                 string   `yaml:"cert_file"`
+	KeyFile                  string   `yaml:"key_file"`
+	ClientCAFile             string   `yaml:"client_ca_file"`
+	ClientAuthType           string   `yaml:"client_auth_type"`
+	MinVersion               string   `yaml:"min_version"`
+	MaxVersion               string   `yaml:"max_version"`
+	CipherSuites             []string `yaml:"cipher_suites"`
+	CurvePreferences         []string `yaml:"curve_preferences"`
+	PreferServerCipherSuites *bool    `yaml:"prefer_server_cipher_suites"`
+}
+
+type webHTTPServerConfig struct {
+	HTTP2   *bool             `yaml:"http2"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// webConfig is the listener setup read from --web.config.file.
+type webConfig struct {
+	tls     webTLSConfig
+	users   map[string]string
+	http2   bool
+	headers map[string]string
+}
+
+var tlsVersions = map[string]uint16{
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"CurveP256": tls.CurveP256,
+	"CurveP384": tls.CurveP384,
+	"CurveP521": tls.CurveP521,
+	"X25519":    tls.X25519,
+}
+
+// webConfigHeaders are the response headers the web config may set, as in
+// the exporter-toolkit.
+var webConfigHeaders = map[string]bool{
+	"Strict-Transport-Security": true,
+	"X-Content-Type-Options":    true,
+	"X-Frame-Options":           true,
+	"X-Xss-Protection":          true,
+	"Content-Security-Policy":   true,
+}
+
+// readWebConfig reads the web config file at path. Relative file names in
+// it are relative to the directory of the file.
+func readWebConfig(path string) (*webConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f webConfigFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && len(bytes.TrimSpace(data)) > 0 {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	c := &webConfig{users: f.BasicAuthUsers, http2: true, headers: f.HTTPServerConfig.Headers}
+	if err := checkUserHashes(c.users); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.HTTPServerConfig.HTTP2 != nil {
+		c.http2 = *f.HTTPServerConfig.HTTP2
+	}
+	for name := range c.headers {
+		if !webConfigHeaders[http.CanonicalHeaderKey(name)] {
+			return nil, fmt.Errorf("%s: header %q can't be set", path, name)
+		}
+	}
+
+	if tc := f.TLSServerConfig; tc != nil {
+		dir := filepath.Dir(path)
+		resolve := func(file string) string {
+			if file == "" || filepath.IsAbs(file) {
+				return file
+			}
+			return filepath.Join(dir, file)
+		}
+		c.tls = webTLSConfig{
+			CertFile:       resolve(tc.CertFile),
+			KeyFile:        resolve(tc.KeyFile),
+			ClientCAFile:   resolve(tc.ClientCAFile),
+			ClientAuthType: tc.ClientAuthType,
+		}
+		if c.tls.CertFile == "" || c.tls.KeyFile == "" {
+			return nil, fmt.Errorf("%s: tls_server_config requires cert_file and key_file", path)
+		}
+		if c.tls.MinVersion, err = parseTLSVersion(tc.MinVersion); err != nil {
+			return nil, fmt.Errorf("%s: min_version: %w", path, err)
+		}
+		if c.tls.MaxVersion, err = parseTLSVersion(tc.MaxVersion); err != nil {
+			return nil, fmt.Errorf("%s: max_version: %w", path, err)
+		}
+		suites := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			suites[s.Name] = s.ID
+		}
+		for _, name := range tc.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown or insecure cipher suite %q", path, name)
+			}
+			c.tls.CipherSuites = append(c.tls.CipherSuites, id)
+		}
+		for _, name := range tc.CurvePreferences {
+			curve, ok := tlsCurves[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown curve %q", path, name)
+			}
+			c.tls.CurvePreferences = append(c.tls.CurvePreferences, curve)
+		}
+	}
+	return c, nil
+}
+
+// parseTLSVersion parses a TLS version of the web config, 0 if empty.
+func parseTLSVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid TLS version %q, must be TLS10, TLS11, TLS12 or TLS13", name)
+	}
+	return version, nil
+}
+
+// withHeaders returns h adding headers to every response.
+func withHeaders(h http.Handler, headers map[string]string) http.Handler {
+	if len(headers) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveWeb serves server on listener, over HTTPS if secure. Serve sets a
+// TLS config for HTTP/2 on servers without one, so whether to use TLS is
+// decided up front rather than from server.TLSConfig.
+func serveWeb(server *http.Server, listener net.Listener, secure bool) error {
+	if secure {
+		// The certificate comes from TLSConfig.GetCertificate.
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
+
+// listenUnix listens on the Unix domain socket at path with the file mode
+// mode. A socket left behind by an exporter that didn't shut down cleanly
+// is replaced, one that still accepts connections is not.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// listenNetworks maps the values of --listen.family to the networks of
+// net.Listen. Go listens dual-stack on "tcp" and IPv6 only on "tcp6".
+var listenNetworks = map[string]string{"any": "tcp", "ipv4": "tcp4", "ipv6": "tcp6"}
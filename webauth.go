@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+var webAuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "exporter_web_auth_failures_total",
+	Help: "Number of requests to the exporter rejected for missing or invalid credentials, by reason",
+}, []string{"reason"})
+
+func init() {
+	addExporterMetrics(webAuthFailures)
+}
+
+// webAuth protects HTTP handlers with basic auth against bcrypt hashed
+// passwords and/or a static bearer token.
+type webAuth struct {
+	users       map[string]string
+	bearerToken string
+
+	// cache remembers successful bcrypt checks, which are deliberately
+	// slow, keyed by a hash of the credentials.
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]bool
+}
+
+// usersFile is the format of --web.auth.users-file, the same as the
+// basic_auth_users of the Prometheus exporter-toolkit web config.
+type usersFile struct {
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+// dummyHash is compared against for unknown users, so that they take as
+// long to reject as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+
+func newWebAuth(users map[string]string, bearerToken string) *webAuth {
+	return &webAuth{users: users, bearerToken: bearerToken, cache: make(map[[sha256.Size]byte]bool)}
+}
+
+// readUsersFile reads the basic_auth_users of --web.auth.users-file.
+func readUsersFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f usersFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(f.BasicAuthUsers) == 0 {
+		return nil, fmt.Errorf("%s: no basic_auth_users configured", path)
+	}
+	if err := checkUserHashes(f.BasicAuthUsers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f.BasicAuthUsers, nil
+}
+
+// checkUserHashes checks that the passwords of users are bcrypt hashes.
+func checkUserHashes(users map[string]string) error {
+	for user, hash := range users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash for user %q: %w", user, err)
+		}
+	}
+	return nil
+}
+
+func (a *webAuth) enabled() bool {
+	return len(a.users) > 0 || a.bearerToken != ""
+}
+
+// wrap returns h protected by a, or h itself if no credentials are
+// configured.
+func (a *webAuth) wrap(h http.Handler) http.Handler {
+	if !a.enabled() {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason := a.check(r); reason != "" {
+			webAuthFailures.WithLabelValues(reason).Inc()
+			if len(a.users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="celestia exporter"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// check returns why r is not authorized, or "" if it is.
+func (a *webAuth) check(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "missing"
+	}
+	if strings.HasPrefix(header, "Bearer ") {
+		token := strings.TrimPrefix(header, "Bearer ")
+		if a.bearerToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.bearerToken)) == 1 {
+			return ""
+		}
+		return "invalid_token"
+	}
+	if user, pass, ok := r.BasicAuth(); ok && len(a.users) > 0 {
+		if a.checkPassword(user, pass) {
+			return ""
+		}
+		return "invalid_password"
+	}
+	return "unsupported_scheme"
+}
+
+func (a *webAuth) checkPassword(user, pass string) bool {
+	hash, known := a.users[user]
+	key := sha256.Sum256([]byte(user + "\x00" + pass + "\x00" + hash))
+
+	a.mu.Lock()
+	cached := a.cache[key]
+	a.mu.Unlock()
+	if cached {
+		return true
+	}
+
+	if !known {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(pass))
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) != nil {
+		return false
+	}
+	a.mu.Lock()
+	a.cache[key] = true
+	a.mu.Unlock()
+	return true
+}